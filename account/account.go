@@ -1,8 +1,11 @@
 package account
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/mail"
+	"strings"
 
 	"github.com/ElenaGrasovskaya/gobank/services"
 	"github.com/ElenaGrasovskaya/gobank/storage"
@@ -14,7 +17,11 @@ type AccountHandlers interface {
 	HandleGetAccount(*gin.Context)
 	HandleGetAccountById(*gin.Context)
 	HandleCreateAccount(*gin.Context)
+	HandleUpdateAccount(*gin.Context)
 	HandleDeleteAccount(*gin.Context)
+	HandleSuspendAccount(*gin.Context)
+	HandleRestoreAccount(*gin.Context)
+	HandlePurgeAccount(*gin.Context)
 }
 
 type StoreHandler struct {
@@ -36,7 +43,7 @@ func (s *StoreHandler) HandleGetAccount(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, accounts)
+	c.JSON(http.StatusOK, types.NewResponceAccounts(accounts))
 
 }
 
@@ -49,22 +56,13 @@ func (s *StoreHandler) HandleGetAccountById(c *gin.Context) {
 	}
 
 	account, err := s.store.GetAccountById(stdCtx, id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Could not load the data from request": err.Error()})
+	if err != nil || !services.CanAccess(c, account.ID) {
+		services.ResourceNotFound(c, "account", id)
 		return
 	}
 
-	var responceAccount = types.ResponceAccount{
-		ID:        account.ID,
-		FirstName: account.FirstName,
-		LastName:  account.LastName,
-		Email:     account.Email,
-		Balance:   account.Balance,
-		CreatedAt: account.CreatedAt,
-	}
-
 	fmt.Println(id)
-	c.JSON(http.StatusOK, responceAccount)
+	c.JSON(http.StatusOK, types.NewResponceAccount(account))
 }
 
 func (s *StoreHandler) HandleCreateAccount(c *gin.Context) {
@@ -75,26 +73,126 @@ func (s *StoreHandler) HandleCreateAccount(c *gin.Context) {
 		return
 	}
 
-	account, err := types.NewAccount(createAccountRequest.FirstName, createAccountRequest.LastName, createAccountRequest.Email, createAccountRequest.Password)
+	account, err := types.NewAccount(createAccountRequest.FirstName, createAccountRequest.LastName, createAccountRequest.Email, createAccountRequest.Password, createAccountRequest.Currency)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"Could not load the data from request": err.Error()})
 		return
 	}
 
 	newAcc, err := s.store.CreateAccount(stdCtx, account)
+	if errors.Is(err, storage.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"Could not create an account": err.Error()})
 		return
 	}
 
-	tokenString, err := services.CreateJWT(newAcc)
+	if err := services.SendVerificationEmail(stdCtx, newAcc); err != nil {
+		fmt.Println(err)
+	}
+
+	c.JSON(http.StatusOK, types.NewResponceAccount(newAcc))
+}
+
+// maxProfileFieldLength matches the varchar(50) columns of the account table.
+const maxProfileFieldLength = 50
+
+// applyProfileUpdate validates the fields of req that are set and copies
+// the names onto account. A new email is returned rather than applied, since
+// it has to be confirmed first.
+func applyProfileUpdate(account *types.Account, req *types.UpdateAccountRequest) (string, error) {
+	if req.FirstName == nil && req.LastName == nil && req.Email == nil {
+		return "", fmt.Errorf("nothing to update")
+	}
+
+	name := func(field string, value *string, dst *string) error {
+		if value == nil {
+			return nil
+		}
+		v := strings.TrimSpace(*value)
+		if v == "" || len(v) > maxProfileFieldLength {
+			return fmt.Errorf("%s must have between 1 and %d characters", field, maxProfileFieldLength)
+		}
+		*dst = v
+		return nil
+	}
+	if err := name("first_name", req.FirstName, &account.FirstName); err != nil {
+		return "", err
+	}
+	if err := name("last_name", req.LastName, &account.LastName); err != nil {
+		return "", err
+	}
+
+	if req.Email == nil {
+		return "", nil
+	}
+	email := strings.TrimSpace(*req.Email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxProfileFieldLength {
+		return "", fmt.Errorf("invalid email %q", email)
+	}
+	if email == account.Email {
+		return "", nil
+	}
+	return email, nil
+}
+
+// HandleUpdateAccount changes the names and email of an account, for its
+// owner or an admin. A new email is mailed a link that applies it, see
+// services.HandleConfirmEmailChange; the owner also has to give the current
+// password. The link is sent before anything is saved, so a failed send
+// leaves the account as it was.
+func (s *StoreHandler) HandleUpdateAccount(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	id, err := services.GetId(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, id)
+	if err != nil || !services.CanAccess(c, account.ID) {
+		services.ResourceNotFound(c, "account", id)
+		return
+	}
+
+	req := new(types.UpdateAccountRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	newEmail, err := applyProfileUpdate(account, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Could not create token": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	fmt.Printf("JWT token: %v", tokenString)
-	c.JSON(http.StatusOK, newAcc)
+	if newEmail != "" {
+		// Admins act for the holder, who proves the new address by
+		// following the link.
+		if services.IsOwner(c, account.ID) && !services.CheckPassword(account, req.CurrentPassword) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		if other, err := s.store.GetAccountByEmail(stdCtx, newEmail); err == nil && other.ID != account.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": storage.ErrEmailTaken.Error()})
+			return
+		}
+		if err := services.SendEmailChangeConfirmation(stdCtx, s.store, account, newEmail); err != nil {
+			fmt.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+			return
+		}
+	}
+
+	if err := s.store.UpdateAccount(stdCtx, account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, &types.AccountUpdateResponse{ResponceAccount: types.NewResponceAccount(account), PendingEmail: newEmail})
 }
 
 func (s *StoreHandler) HandleDeleteAccount(c *gin.Context) {
@@ -106,18 +204,22 @@ func (s *StoreHandler) HandleDeleteAccount(c *gin.Context) {
 	}
 
 	account, err := s.store.GetAccountById(stdCtx, id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"Could not delete an account": err.Error()})
+	if err != nil || !services.CanAccess(c, account.ID) {
+		services.ResourceNotFound(c, "account", id)
 		return
 	}
 
-	if account.Status == "Deleted" {
+	if account.Status == types.StatusDeleted || account.Status == types.StatusPurged {
 		c.JSON(http.StatusBadRequest, gin.H{"This accout was already deleted": account.ID})
 		return
 	}
 	fmt.Printf("After check %v", account.Status)
 	if err := s.store.DeleteAccount(stdCtx, id); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"Could not delete an account": err.Error()})
+		return
+	}
+	if err := s.store.RevokeAccountSessions(stdCtx, id, ""); err != nil {
+		fmt.Println(err)
 	}
 
 	c.JSON(http.StatusOK, map[string]int{"deleted": id})
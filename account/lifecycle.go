@@ -0,0 +1,166 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRetentionPeriod is how long a deleted account can be restored
+// before it is purged.
+const DefaultRetentionPeriod = 30 * 24 * time.Hour
+
+// RetentionPeriod reads the grace period from ACCOUNT_RETENTION_DAYS.
+func RetentionPeriod() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return DefaultRetentionPeriod
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// loadForLifecycle reads the account named in the path, answering 400 or 404
+// itself if it cannot.
+func (s *StoreHandler) loadForLifecycle(c *gin.Context) (*types.Account, bool) {
+	id, err := services.GetId(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	account, err := s.store.GetAccountById(c.Request.Context(), id)
+	if err != nil {
+		services.ResourceNotFound(c, "account", id)
+		return nil, false
+	}
+	return account, true
+}
+
+// HandleSuspendAccount blocks an account from signing in and from the
+// ledger until an admin restores it. Its sessions end immediately.
+func (s *StoreHandler) HandleSuspendAccount(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	account, ok := s.loadForLifecycle(c)
+	if !ok {
+		return
+	}
+
+	if account.Status != types.StatusActive && account.Status != types.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot suspend a %s account", account.Status)})
+		return
+	}
+
+	if err := s.store.SuspendAccount(stdCtx, account.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.store.RevokeAccountSessions(stdCtx, account.ID, ""); err != nil {
+		fmt.Println(err)
+	}
+
+	c.JSON(http.StatusOK, map[string]int{"suspended": account.ID})
+}
+
+// HandleRestoreAccount reactivates a suspended account, or a deleted one
+// that is still within its grace period. Accounts that were still waiting
+// for email verification go back to pending.
+func (s *StoreHandler) HandleRestoreAccount(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	account, ok := s.loadForLifecycle(c)
+	if !ok {
+		return
+	}
+
+	switch account.Status {
+	case types.StatusSuspended:
+	case types.StatusDeleted:
+		if account.DeletedAt != nil && time.Since(*account.DeletedAt) > RetentionPeriod() {
+			c.JSON(http.StatusConflict, gin.H{"error": "The grace period for restoring this account has passed"})
+			return
+		}
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot restore a %s account", account.Status)})
+		return
+	}
+
+	if err := s.store.RestoreAccount(stdCtx, account.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]int{"restored": account.ID})
+}
+
+// HandlePurgeAccount anonymizes a deleted account without waiting for the
+// end of its grace period.
+func (s *StoreHandler) HandlePurgeAccount(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	account, ok := s.loadForLifecycle(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store.PurgeAccount(stdCtx, account.ID); err != nil {
+		if errors.Is(err, storage.ErrAccountNotDeleted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only deleted accounts can be purged"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]int{"purged": account.ID})
+}
+
+// PurgeExpiredAccounts purges every account deleted longer than retention
+// before now and returns how many it purged. A failure on one account does
+// not stop the others.
+func PurgeExpiredAccounts(ctx context.Context, store storage.Storage, retention time.Duration, now time.Time) (int, error) {
+	accounts, err := store.GetAccountsDeletedBefore(ctx, now.Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	purged := 0
+	for _, account := range accounts {
+		if err := store.PurgeAccount(ctx, account.ID); err != nil {
+			errs = append(errs, fmt.Errorf("account %d: %w", account.ID, err))
+			continue
+		}
+		purged++
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+// RunPurgeJob calls PurgeExpiredAccounts every interval until ctx is done.
+func RunPurgeJob(ctx context.Context, store storage.Storage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := PurgeExpiredAccounts(ctx, store, RetentionPeriod(), time.Now().UTC())
+		if err != nil {
+			fmt.Printf("Account purge failed: %v\n", err)
+		}
+		if purged > 0 {
+			fmt.Printf("Purged %d deleted accounts\n", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
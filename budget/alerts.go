@@ -0,0 +1,153 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+// thresholdCheckTimeout bounds a background threshold check, including
+// sending its notification.
+const thresholdCheckTimeout = time.Minute
+
+// thresholdChecks tracks the checks started by DispatchThresholdCheck.
+var thresholdChecks sync.WaitGroup
+
+// DispatchThresholdCheck runs CheckThresholds for exp in the background, so
+// that a slow mail server does not hold up the request that stored it.
+// Failures are logged.
+func DispatchThresholdCheck(store storage.Storage, exp *types.Expense) {
+	exp = &types.Expense{ID: exp.ID, UserId: exp.UserId, CategoryId: exp.CategoryId, CreatedAt: exp.CreatedAt}
+
+	thresholdChecks.Add(1)
+	go func() {
+		defer thresholdChecks.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), thresholdCheckTimeout)
+		defer cancel()
+		if err := CheckThresholds(ctx, store, exp); err != nil {
+			fmt.Printf("Budget check for expense %d failed: %v\n", exp.ID, err)
+		}
+	}()
+}
+
+// WaitForThresholdChecks blocks until every dispatched check has finished.
+// main calls it on shutdown.
+func WaitForThresholdChecks() {
+	thresholdChecks.Wait()
+}
+
+// CheckThresholds looks at the budgets covering the category of a newly
+// stored expense and notifies the user the first time spending in the
+// expense's month reaches one of types.BudgetThresholds. Each threshold
+// fires once per budget and month; when several are crossed at once only
+// the highest is sent. Thresholds are recorded before the notification so
+// that concurrent checks send it once, and forgotten again if it fails.
+func CheckThresholds(ctx context.Context, store storage.Storage, exp *types.Expense) error {
+	if exp.CategoryId == nil {
+		return nil
+	}
+
+	budgets, err := store.GetBudgetsForUser(ctx, exp.UserId)
+	if err != nil || len(budgets) == 0 {
+		return err
+	}
+
+	categories, err := store.GetCategoriesForUser(ctx, exp.UserId)
+	if err != nil {
+		return err
+	}
+
+	calculator := newStatusCalculator(store)
+	month := MonthOf(exp.CreatedAt)
+	for _, budget := range budgets {
+		if !slices.Contains(types.CategorySubtree(categories, budget.CategoryId), *exp.CategoryId) {
+			continue
+		}
+
+		status, err := calculator.status(ctx, budget, categories, month)
+		if err != nil {
+			return err
+		}
+
+		var recorded []int
+		for _, threshold := range types.BudgetThresholds {
+			if status.Percent < float64(threshold) {
+				break
+			}
+			ok, err := store.RecordBudgetAlert(ctx, budget.ID, status.Month, threshold)
+			if err != nil {
+				return errors.Join(err, forgetAlerts(ctx, store, budget.ID, status.Month, recorded))
+			}
+			if ok {
+				recorded = append(recorded, threshold)
+			}
+		}
+
+		if len(recorded) > 0 {
+			if err := notifyThreshold(ctx, store, status, exp.UserId, recorded[len(recorded)-1]); err != nil {
+				return errors.Join(err, forgetAlerts(ctx, store, budget.ID, status.Month, recorded))
+			}
+		}
+	}
+
+	return nil
+}
+
+// forgetAlerts deletes alerts recorded by a check that failed before the user
+// was notified, so that the next check sends them. It runs even when ctx has
+// timed out, which is a common reason for the failure.
+func forgetAlerts(ctx context.Context, store storage.Storage, budgetId int, month string, thresholds []int) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	for _, threshold := range thresholds {
+		if err := store.DeleteBudgetAlert(ctx, budgetId, month, threshold); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func notifyThreshold(ctx context.Context, store storage.Storage, status *types.BudgetStatus, userId, threshold int) error {
+	account, err := store.GetAccountById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	n, err := services.Notifier()
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("You have used %d%% of your %s budget", threshold, status.Category)
+	if threshold >= types.BudgetExceededPercent {
+		subject = fmt.Sprintf("Your %s budget is used up", status.Category)
+	}
+
+	return n.Notify(ctx, &notify.Notification{
+		Kind:      notify.KindBudgetThreshold,
+		AccountId: account.ID,
+		To:        account.Email,
+		Subject:   subject,
+		Body: fmt.Sprintf("Hi %s,\n\nYou have spent %s of the %s available for %s in %s.",
+			account.FirstName, status.Spent, status.Available, status.Category, status.Month),
+		Data: map[string]string{
+			"budget_id": strconv.Itoa(status.BudgetId),
+			"category":  status.Category,
+			"month":     status.Month,
+			"threshold": strconv.Itoa(threshold),
+			"spent":     status.Spent.String(),
+			"limit":     status.Available.String(),
+		},
+	})
+}
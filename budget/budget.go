@@ -0,0 +1,234 @@
+package budget
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/category"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrCategoryChanged = errors.New("the category of a budget cannot change")
+	ErrCurrencyChanged = errors.New("the currency of a budget cannot change")
+)
+
+type BudgetHandlers interface {
+	HandleGetBudgets(*gin.Context)
+	HandleGetBudgetById(*gin.Context)
+	HandleCreateBudget(*gin.Context)
+	HandleUpdateBudget(*gin.Context)
+	HandleDeleteBudget(*gin.Context)
+	HandleBudgetStatus(*gin.Context)
+}
+
+type StoreHandler struct {
+	store      storage.Storage
+	calculator *statusCalculator
+}
+
+func NewBudgetHandler(store storage.Storage) *StoreHandler {
+	return &StoreHandler{
+		store:      store,
+		calculator: newStatusCalculator(store),
+	}
+}
+
+func (s *StoreHandler) HandleGetBudgets(c *gin.Context) {
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	budgets, err := s.store.GetBudgetsForUser(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load budgets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+func (s *StoreHandler) HandleGetBudgetById(c *gin.Context) {
+	budget, ok := s.loadBudget(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// HandleCreateBudget sets a monthly limit on one of the caller's categories.
+// A category has at most one budget.
+func (s *StoreHandler) HandleCreateBudget(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	req := new(types.BudgetRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := category.Resolve(stdCtx, s.store, userId, &req.CategoryId, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	budget, err := types.NewBudget(userId, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := s.store.CreateBudget(stdCtx, budget)
+	if err != nil {
+		budgetStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// HandleUpdateBudget replaces the limit and rollover of a budget. The new
+// limit applies from the current month on.
+func (s *StoreHandler) HandleUpdateBudget(c *gin.Context) {
+	req := new(types.BudgetRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, ok := s.loadBudget(c)
+	if !ok {
+		return
+	}
+
+	if req.CategoryId == 0 {
+		req.CategoryId = existing.CategoryId
+	}
+	if req.CategoryId != existing.CategoryId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrCategoryChanged.Error()})
+		return
+	}
+
+	budget, err := types.NewBudget(existing.UserId, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if budget.Limit.Currency != existing.Limit.Currency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrCurrencyChanged.Error()})
+		return
+	}
+	budget.ID = existing.ID
+	budget.CreatedAt = existing.CreatedAt
+
+	if err := s.store.UpdateBudget(c.Request.Context(), budget); err != nil {
+		budgetStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+func (s *StoreHandler) HandleDeleteBudget(c *gin.Context) {
+	budget, ok := s.loadBudget(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteBudget(c.Request.Context(), budget.ID); err != nil {
+		budgetStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]int{"deleted": budget.ID})
+}
+
+// HandleBudgetStatus reports spending against every budget of the caller
+// for the month given as month=YYYY-MM, by default the current one.
+func (s *StoreHandler) HandleBudgetStatus(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	month := MonthOf(time.Now())
+	if param := c.Query("month"); param != "" {
+		parsed, err := time.Parse(monthLayout, param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month, expected YYYY-MM"})
+			return
+		}
+		month = parsed
+	}
+
+	budgets, err := s.store.GetBudgetsForUser(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load budgets"})
+		return
+	}
+
+	categories, err := s.store.GetCategoriesForUser(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+
+	response := &types.BudgetStatusResponse{
+		Month:   month.Format(monthLayout),
+		Budgets: []*types.BudgetStatus{},
+	}
+	for _, budget := range budgets {
+		status, err := s.calculator.status(stdCtx, budget, categories, month)
+		if err != nil {
+			if errors.Is(err, storage.ErrRateNotFound) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response.Budgets = append(response.Budgets, status)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// loadBudget reads the budget named in the path, answering 400 or 404
+// itself if it cannot or the caller may not see it.
+func (s *StoreHandler) loadBudget(c *gin.Context) (*types.Budget, bool) {
+	id, err := services.GetId(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	budget, err := s.store.GetBudgetById(c.Request.Context(), id)
+	if err != nil || !services.CanAccess(c, budget.UserId) {
+		services.ResourceNotFound(c, "budget", id)
+		return nil, false
+	}
+
+	return budget, true
+}
+
+func budgetStoreError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrBudgetExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,146 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/exchange"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+const (
+	monthLayout = "2006-01"
+
+	// maxRolloverMonths bounds how far back unspent amounts are carried.
+	maxRolloverMonths = 24
+)
+
+// MonthOf returns the first instant of the UTC month containing t.
+func MonthOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// statusCalculator computes budget statuses from the expense table,
+// converting expenses into the currency of each budget's limit.
+type statusCalculator struct {
+	store     storage.Storage
+	converter *exchange.Converter
+}
+
+func newStatusCalculator(store storage.Storage) *statusCalculator {
+	return &statusCalculator{
+		store:     store,
+		converter: exchange.NewConverter(store),
+	}
+}
+
+// status reports how much of budget was spent in month, counting the
+// expenses of its category and all subcategories. With rollover, what was
+// left of each month since the budget was created is carried forward;
+// overspending is not. Each month counts with the limit it had at the time,
+// so changing the limit does not change what earlier months rolled over.
+func (s *statusCalculator) status(ctx context.Context, budget *types.Budget, categories []*types.Category, month time.Time) (*types.BudgetStatus, error) {
+	currency := budget.Limit.Currency
+	start := month
+	if budget.Rollover {
+		start = MonthOf(budget.CreatedAt)
+		if earliest := month.AddDate(0, -maxRolloverMonths, 0); start.Before(earliest) {
+			start = earliest
+		}
+		if start.After(month) {
+			start = month
+		}
+	}
+	end := month.AddDate(0, 1, 0)
+
+	expenses, err := s.store.GetExpenseForUser(ctx, budget.UserId, &types.ExpenseQuery{
+		CategoryIds: types.CategorySubtree(categories, budget.CategoryId),
+		From:        &start,
+		Before:      &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := s.store.GetBudgetLimits(ctx, budget.ID)
+	if err != nil {
+		return nil, err
+	}
+	limitFor := func(month string) types.Money {
+		return types.BudgetLimitFor(limits, month, budget.Limit)
+	}
+
+	spent := map[string]types.Money{}
+	for _, exp := range expenses.Expenses {
+		value, err := s.converter.Convert(ctx, exp.ExpenseValue, currency, exp.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("expense %d: %w", exp.ID, err)
+		}
+		key := exp.CreatedAt.UTC().Format(monthLayout)
+		if spent[key], err = addMoney(spent[key], value, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	rolledOver := types.NewMoney(0, currency)
+	for m := start; m.Before(month); m = m.AddDate(0, 1, 0) {
+		key := m.Format(monthLayout)
+		left := limitFor(key).Amount + rolledOver.Amount - spent[key].Amount
+		rolledOver = types.NewMoney(max(left, 0), currency)
+	}
+
+	key := month.Format(monthLayout)
+	limit := limitFor(key)
+	available := types.NewMoney(limit.Amount+rolledOver.Amount, currency)
+	spentNow := types.NewMoney(spent[key].Amount, currency)
+
+	status := &types.BudgetStatus{
+		BudgetId:   budget.ID,
+		CategoryId: budget.CategoryId,
+		Month:      key,
+		Limit:      limit,
+		RolledOver: rolledOver,
+		Available:  available,
+		Spent:      spentNow,
+		Remaining:  types.NewMoney(available.Amount-spentNow.Amount, currency),
+		Percent:    percentOf(spentNow.Amount, available.Amount),
+	}
+	for _, category := range categories {
+		if category.ID == budget.CategoryId {
+			status.Category = category.Name
+		}
+	}
+
+	switch {
+	case status.Percent >= types.BudgetExceededPercent:
+		status.State = types.BudgetExceeded
+	case status.Percent >= types.BudgetWarningPercent:
+		status.State = types.BudgetWarning
+	default:
+		status.State = types.BudgetOk
+	}
+
+	return status, nil
+}
+
+func addMoney(total, value types.Money, currency string) (types.Money, error) {
+	if total.Currency == "" {
+		total = types.NewMoney(0, currency)
+	}
+	return total.Add(value)
+}
+
+// percentOf returns part as a percentage of whole, to one decimal place.
+func percentOf(part, whole int64) float64 {
+	if whole <= 0 {
+		if part > 0 {
+			return types.BudgetExceededPercent
+		}
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
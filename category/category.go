@@ -0,0 +1,256 @@
+package category
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrUnknownCategory = errors.New("category not found")
+	ErrParentCycle     = errors.New("a category cannot be moved below itself")
+)
+
+type CategoryHandlers interface {
+	HandleGetCategories(*gin.Context)
+	HandleGetCategoryById(*gin.Context)
+	HandleCreateCategory(*gin.Context)
+	HandleUpdateCategory(*gin.Context)
+	HandleDeleteCategory(*gin.Context)
+}
+
+type StoreHandler struct {
+	store storage.Storage
+}
+
+func NewCategoryHandler(store storage.Storage) *StoreHandler {
+	return &StoreHandler{
+		store: store,
+	}
+}
+
+// HandleGetCategories lists the caller's categories. Clients build the tree
+// from parent_id.
+func (s *StoreHandler) HandleGetCategories(c *gin.Context) {
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	categories, err := s.store.GetCategoriesForUser(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+func (s *StoreHandler) HandleGetCategoryById(c *gin.Context) {
+	category, ok := s.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+func (s *StoreHandler) HandleCreateCategory(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	req := new(types.CategoryRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := types.NewCategory(userId, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkParent(stdCtx, category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := s.store.CreateCategory(stdCtx, category)
+	if err != nil {
+		categoryStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// HandleUpdateCategory replaces the name, parent, color and icon of a
+// category. Moving it below one of its own subcategories is refused.
+func (s *StoreHandler) HandleUpdateCategory(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	req := new(types.CategoryRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, ok := s.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	category, err := types.NewCategory(existing.UserId, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	category.ID = existing.ID
+	category.CreatedAt = existing.CreatedAt
+
+	if err := s.checkParent(stdCtx, category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.store.UpdateCategory(stdCtx, category); err != nil {
+		categoryStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// HandleDeleteCategory removes a category. Its subcategories and expenses
+// move up to its parent.
+func (s *StoreHandler) HandleDeleteCategory(c *gin.Context) {
+	category, ok := s.loadCategory(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteCategory(c.Request.Context(), category.ID); err != nil {
+		categoryStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]int{"deleted": category.ID})
+}
+
+// loadCategory reads the category named in the path, answering 400 or 404
+// itself if it cannot or the caller may not see it.
+func (s *StoreHandler) loadCategory(c *gin.Context) (*types.Category, bool) {
+	id, err := services.GetId(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	category, err := s.store.GetCategoryById(c.Request.Context(), id)
+	if err != nil || !services.CanAccess(c, category.UserId) {
+		services.ResourceNotFound(c, "category", id)
+		return nil, false
+	}
+
+	return category, true
+}
+
+// checkParent makes sure the parent of category is another category of the
+// same user and not one below category itself.
+func (s *StoreHandler) checkParent(ctx context.Context, category *types.Category) error {
+	if category.ParentId == nil {
+		return nil
+	}
+
+	categories, err := s.store.GetCategoriesForUser(ctx, category.UserId)
+	if err != nil {
+		return err
+	}
+
+	found := slices.ContainsFunc(categories, func(other *types.Category) bool {
+		return other.ID == *category.ParentId
+	})
+	if !found {
+		return fmt.Errorf("parent %w", ErrUnknownCategory)
+	}
+	if category.ID != 0 && slices.Contains(types.CategorySubtree(categories, category.ID), *category.ParentId) {
+		return ErrParentCycle
+	}
+
+	return nil
+}
+
+func categoryStoreError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrCategoryExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// Resolve returns the category an expense of userId is filed under: id if
+// given, which has to be one of the user's, or else the top-level category
+// called name, created on first use. With neither the expense is
+// uncategorized.
+func Resolve(ctx context.Context, store storage.Storage, userId int, id *int, name string) (*int, error) {
+	if id != nil {
+		category, err := store.GetCategoryById(ctx, *id)
+		if err != nil || category.UserId != userId {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownCategory, *id)
+		}
+		return &category.ID, nil
+	}
+
+	if strings.TrimSpace(name) == "" {
+		return nil, nil
+	}
+	category, err := types.NewCategory(userId, &types.CategoryRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent request may create the same category first; the second
+	// lookup then finds it.
+	for attempt := 0; attempt < 2; attempt++ {
+		categories, err := store.GetCategoriesForUser(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		for _, existing := range categories {
+			if existing.ParentId == nil && strings.EqualFold(existing.Name, category.Name) {
+				return &existing.ID, nil
+			}
+		}
+
+		created, err := store.CreateCategory(ctx, category)
+		if err == nil {
+			return &created.ID, nil
+		}
+		if !errors.Is(err, storage.ErrCategoryExists) {
+			return nil, err
+		}
+	}
+
+	return nil, storage.ErrCategoryExists
+}
+
+// Subtree returns the ids of category and of all its subcategories.
+func Subtree(ctx context.Context, store storage.Storage, category *types.Category) ([]int, error) {
+	categories, err := store.GetCategoriesForUser(ctx, category.UserId)
+	if err != nil {
+		return nil, err
+	}
+	return types.CategorySubtree(categories, category.ID), nil
+}
@@ -0,0 +1,181 @@
+package exchange
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+// rateRecord is the shape of one rate in a JSON rates file. CSV files use the
+// same fields as columns: base,quote,date,rate.
+type rateRecord struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	Date  string `json:"date"`
+	Rate  string `json:"rate"`
+}
+
+// LoadFile reads exchange rates from a .csv or .json file.
+func LoadFile(path string) ([]*types.ExchangeRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ParseCSV(f)
+	case ".json":
+		return ParseJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported rates file %s, expected .csv or .json", path)
+	}
+}
+
+// ParseCSV reads rows of base,quote,date,rate. A header row is skipped.
+func ParseCSV(r io.Reader) ([]*types.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []*types.ExchangeRate
+	for i, row := range rows {
+		if i == 0 && strings.EqualFold(row[0], "base") {
+			continue
+		}
+		rate, err := newRate(rateRecord{Base: row[0], Quote: row[1], Date: row[2], Rate: row[3]})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+// ParseJSON reads an array of {"base","quote","date","rate"} objects.
+func ParseJSON(r io.Reader) ([]*types.ExchangeRate, error) {
+	var records []rateRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	rates := make([]*types.ExchangeRate, 0, len(records))
+	for i, record := range records {
+		rate, err := newRate(record)
+		if err != nil {
+			return nil, fmt.Errorf("rate %d: %v", i, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+func newRate(record rateRecord) (*types.ExchangeRate, error) {
+	base, err := types.NormalizeCurrency(record.Base)
+	if err != nil || record.Base == "" {
+		return nil, fmt.Errorf("invalid base currency %q", record.Base)
+	}
+	quote, err := types.NormalizeCurrency(record.Quote)
+	if err != nil || record.Quote == "" {
+		return nil, fmt.Errorf("invalid quote currency %q", record.Quote)
+	}
+
+	day, err := time.Parse(time.DateOnly, strings.TrimSpace(record.Date))
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", record.Date)
+	}
+
+	// big.Rat also accepts fractions and exponents, which numeric does not.
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(record.Rate))
+	if !ok || value.Sign() <= 0 || strings.ContainsAny(record.Rate, "/eE") {
+		return nil, fmt.Errorf("invalid rate %q", record.Rate)
+	}
+
+	return &types.ExchangeRate{
+		Base:      base,
+		Quote:     quote,
+		ValidFrom: day,
+		Rate:      strings.TrimSpace(record.Rate),
+	}, nil
+}
+
+type Converter struct {
+	store storage.Storage
+}
+
+func NewConverter(store storage.Storage) *Converter {
+	return &Converter{
+		store: store,
+	}
+}
+
+// Rate returns how many units of quote one unit of base buys on the given
+// day. When only the opposite pair is stored its inverse is used.
+func (c *Converter) Rate(ctx context.Context, base, quote string, on time.Time) (*big.Rat, error) {
+	if base == quote {
+		return big.NewRat(1, 1), nil
+	}
+
+	rate, err := c.store.GetExchangeRate(ctx, base, quote, on)
+	if err == nil {
+		return parseRate(rate)
+	}
+	if !errors.Is(err, storage.ErrRateNotFound) {
+		return nil, err
+	}
+
+	inverse, invErr := c.store.GetExchangeRate(ctx, quote, base, on)
+	if errors.Is(invErr, storage.ErrRateNotFound) {
+		return nil, err
+	}
+	if invErr != nil {
+		return nil, invErr
+	}
+	value, invErr := parseRate(inverse)
+	if invErr != nil {
+		return nil, invErr
+	}
+
+	return value.Inv(value), nil
+}
+
+// Convert expresses m in currency using the rate valid on the given day.
+func (c *Converter) Convert(ctx context.Context, m types.Money, currency string, on time.Time) (types.Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+
+	rate, err := c.Rate(ctx, m.Currency, currency, on)
+	if err != nil {
+		return types.Money{}, err
+	}
+
+	return m.Convert(rate, currency)
+}
+
+func parseRate(rate *types.ExchangeRate) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(rate.Rate))
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid stored rate %q for %s/%s", rate.Rate, rate.Base, rate.Quote)
+	}
+	return value, nil
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/ElenaGrasovskaya/gobank/budget"
+	"github.com/ElenaGrasovskaya/gobank/category"
 	"github.com/ElenaGrasovskaya/gobank/services"
 	"github.com/ElenaGrasovskaya/gobank/storage"
 	"github.com/ElenaGrasovskaya/gobank/types"
@@ -13,6 +15,7 @@ import (
 type ExpenseHandlers interface {
 	HandleGetAllExpense(*gin.Context)
 	HandleGetExpenseForUser(*gin.Context)
+	HandleSearchExpenses(*gin.Context)
 	HandleCreateExpense(*gin.Context)
 	HandleDeleteExpense(*gin.Context)
 	HandleUpdateExpense(*gin.Context)
@@ -30,7 +33,13 @@ func NewExpenseHandler(store storage.Storage) *StoreHandler {
 
 func (s *StoreHandler) HandleGetAllExpense(c *gin.Context) {
 	stdCtx := c.Request.Context()
-	expenses, err := s.store.GetAllExpense(stdCtx)
+	query, err := s.parseExpenseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expenses, err := s.store.GetAllExpense(stdCtx, query)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"Could not load the data": err.Error()})
@@ -47,7 +56,14 @@ func (s *StoreHandler) HandleGetExpenseForUser(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
-	expenses, err := s.store.GetExpenseForUser(stdCtx, userId)
+
+	query, err := s.parseExpenseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expenses, err := s.store.GetExpenseForUser(stdCtx, userId, query)
 
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
@@ -74,11 +90,21 @@ func (s *StoreHandler) HandleCreateExpense(c *gin.Context) {
 		return
 	}
 
-	expense, err := types.NewExpense(userId, createExpenseRequest.ExpenseName, createExpenseRequest.ExpensePurpose, createExpenseRequest.ExpenseCategory, createExpenseRequest.ExpenseValue, createExpenseRequest.CreatedAt)
+	categoryId, err := category.Resolve(stdCtx, s.store, userId, createExpenseRequest.CategoryId, createExpenseRequest.ExpenseCategory)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expense, err := types.NewExpense(userId, createExpenseRequest.ExpenseName, createExpenseRequest.ExpensePurpose, categoryId, createExpenseRequest.ExpenseValue, createExpenseRequest.CreatedAt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create new expense"})
 		return
 	}
+	if expense.Tags, err = types.NormalizeTags(createExpenseRequest.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	fmt.Printf("Prepared new expense %v \n", expense)
 
@@ -87,6 +113,7 @@ func (s *StoreHandler) HandleCreateExpense(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store new expense"})
 		return
 	}
+	budget.DispatchThresholdCheck(s.store, newExp)
 
 	c.JSON(http.StatusOK, newExp)
 }
@@ -105,22 +132,34 @@ func (s *StoreHandler) HandleUpdateExpense(c *gin.Context) {
 		return
 	}
 
-	userId, err := services.GetIdFromCookie(c)
+	existing, err := s.store.GetExpenseById(stdCtx, id)
+	if err != nil || !services.CanAccess(c, existing.UserId) {
+		services.ResourceNotFound(c, "expense", id)
+		return
+	}
+
+	categoryId, err := category.Resolve(stdCtx, s.store, existing.UserId, updateExpenseRequest.CategoryId, updateExpenseRequest.ExpenseCategory)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to retrieve user ID from cookie"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	expense, err := types.UpdatedExpense(id, userId, updateExpenseRequest.ExpenseName, updateExpenseRequest.ExpensePurpose, updateExpenseRequest.ExpenseCategory, updateExpenseRequest.ExpenseValue, updateExpenseRequest.CreatedAt)
+
+	expense, err := types.UpdatedExpense(id, existing.UserId, updateExpenseRequest.ExpenseName, updateExpenseRequest.ExpensePurpose, categoryId, updateExpenseRequest.ExpenseValue, updateExpenseRequest.CreatedAt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build an updated expense"})
 		return
 	}
+	if expense.Tags, err = types.NormalizeTags(updateExpenseRequest.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err := s.store.UpdateExpense(stdCtx, id, expense); err != nil {
 		fmt.Printf("%v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update expense"})
 		return
 	}
+	budget.DispatchThresholdCheck(s.store, expense)
 
 	c.JSON(http.StatusOK, expense)
 }
@@ -134,8 +173,8 @@ func (s *StoreHandler) HandleDeleteExpense(c *gin.Context) {
 	}
 
 	expense, err := s.store.GetExpenseById(stdCtx, id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to find the requested expense"})
+	if err != nil || !services.CanAccess(c, expense.UserId) {
+		services.ResourceNotFound(c, "expense", id)
 		return
 	}
 
@@ -0,0 +1,119 @@
+package expense
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ElenaGrasovskaya/gobank/category"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// parseExpenseQuery reads the filters, sort and page of an expense listing:
+// from and to as YYYY-MM-DD with both days included, category_id, which
+// takes in its subcategories, tags as a comma separated list matched by
+// tag_match any or all, purpose, currency, min_value and max_value in that
+// currency, sort as date, -date, value or -value, and the cursor and limit
+// of the page. Sorting by value needs a currency.
+func (s *StoreHandler) parseExpenseQuery(c *gin.Context) (*types.ExpenseQuery, error) {
+	q := &types.ExpenseQuery{
+		Purpose: c.Query("purpose"),
+	}
+
+	if value := c.Query("category_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category_id %s", value)
+		}
+		filter, err := s.store.GetCategoryById(c.Request.Context(), id)
+		if err != nil || !services.CanAccess(c, filter.UserId) {
+			return nil, fmt.Errorf("%w: %d", category.ErrUnknownCategory, id)
+		}
+		if q.CategoryIds, err = category.Subtree(c.Request.Context(), s.store, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := c.Query("tags"); value != "" {
+		tags, err := types.NormalizeTags(strings.Split(value, ","))
+		if err != nil {
+			return nil, err
+		}
+		q.Tags = tags
+	}
+	switch match := c.DefaultQuery("tag_match", "any"); match {
+	case "any":
+	case "all":
+		q.AllTags = true
+	default:
+		return nil, fmt.Errorf("invalid tag_match %s, expected any or all", match)
+	}
+
+	from, to, err := services.GetDateRange(c)
+	if err != nil {
+		return nil, err
+	}
+	q.From = from
+	if to != nil {
+		before := to.AddDate(0, 0, 1)
+		q.Before = &before
+	}
+
+	if value := c.Query("currency"); value != "" {
+		if q.Currency, err = types.NormalizeCurrency(value); err != nil {
+			return nil, err
+		}
+	}
+	if q.MinValue, err = parseValueBound(c, "min_value"); err != nil {
+		return nil, err
+	}
+	if q.MaxValue, err = parseValueBound(c, "max_value"); err != nil {
+		return nil, err
+	}
+
+	switch sort := c.DefaultQuery("sort", types.ExpenseSortDateDesc); sort {
+	case types.ExpenseSortDate, types.ExpenseSortDateDesc, types.ExpenseSortValue, types.ExpenseSortValueDesc:
+		q.Sort = sort
+	default:
+		return nil, fmt.Errorf("invalid sort %s, expected date, -date, value or -value", sort)
+	}
+	// Amounts in different currencies do not compare.
+	if field, _ := q.SortBy(); field == types.ExpenseSortValue && q.Currency == "" {
+		return nil, fmt.Errorf("sort %s needs a currency", q.Sort)
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if q.Cursor, err = types.DecodeExpenseCursor(cursor, q.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	q.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if err != nil || q.Limit < 1 || q.Limit > maxPageLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+
+	return q, nil
+}
+
+// parseValueBound reads a decimal amount in the currency query param, which
+// defaults to types.DefaultCurrency.
+func parseValueBound(c *gin.Context, param string) (*types.Money, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+
+	bound, err := types.ParseMoney(value, c.Query("currency"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", param, err)
+	}
+	return &bound, nil
+}
@@ -0,0 +1,44 @@
+package expense
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultSearchLimit = 20
+
+// HandleSearchExpenses finds the caller's expenses with a word in the name or
+// purpose starting with each word of q, best match first.
+func (s *StoreHandler) HandleSearchExpenses(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	text := c.Query("q")
+	if len(types.SearchTerms(text)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must contain a word to search for"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxPageLimit)})
+		return
+	}
+
+	results, err := s.store.SearchExpenses(stdCtx, userId, text, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search expenses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &types.ExpenseSearchResponse{Query: text, Results: results})
+}
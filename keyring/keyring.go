@@ -0,0 +1,324 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	// minRSABits is the smallest RSA modulus accepted for signing keys.
+	minRSABits = 2048
+)
+
+var (
+	ErrNoSigningKey = errors.New("no signing key is active")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Key is one signing key of a keyring. A key signs new tokens from SignFrom
+// until a newer key takes over, and is accepted for verification until
+// ExpiresAt. A zero ExpiresAt never expires.
+type Key struct {
+	ID        string
+	Algorithm string
+	SignFrom  time.Time
+	ExpiresAt time.Time
+
+	private crypto.Signer
+}
+
+// NewKey wraps an RSA or Ed25519 private key. The algorithm follows from the
+// key type.
+func NewKey(id string, private crypto.Signer, signFrom, expiresAt time.Time) (*Key, error) {
+	if id == "" {
+		return nil, fmt.Errorf("key id is required")
+	}
+
+	key := &Key{ID: id, SignFrom: signFrom, ExpiresAt: expiresAt, private: private}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("key %s: RSA keys need at least %d bits", id, minRSABits)
+		}
+		key.Algorithm = AlgRS256
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgEdDSA
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", id, private)
+	}
+
+	return key, nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Algorithm == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+func (k *Key) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// Keyring holds the keys tokens are signed and verified with. It is not
+// modified after creation and is safe for concurrent use.
+type Keyring struct {
+	keys []*Key
+}
+
+// New builds a keyring from keys with distinct ids.
+func New(keys ...*Key) (*Keyring, error) {
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].SignFrom.Before(sorted[j].SignFrom) })
+
+	return &Keyring{keys: sorted}, nil
+}
+
+// Generate returns a keyring with a single fresh Ed25519 key, for development
+// setups without configured keys. Tokens do not survive a restart.
+func Generate() (*Keyring, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return nil, err
+	}
+
+	key, err := NewKey(base64.RawURLEncoding.EncodeToString(kid), private, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return New(key)
+}
+
+// SigningKey returns the key new tokens are signed with: the most recent key
+// whose SignFrom has passed and that has not expired.
+func (r *Keyring) SigningKey(now time.Time) (*Key, error) {
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		key := r.keys[i]
+		if !key.SignFrom.After(now) && !key.expired(now) {
+			return key, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// Sign signs the claims with the current signing key and puts its id in the
+// kid header.
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := r.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Keyfunc resolves the verification key for a token from its kid header. It
+// is meant for jwt.Parse and refuses tokens whose algorithm does not match
+// the key, as well as keys that have expired.
+func (r *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.ID != kid {
+			continue
+		}
+		if key.expired(now) {
+			return nil, fmt.Errorf("key %s: %w", kid, ErrUnknownKey)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.private.Public(), nil
+	}
+
+	return nil, fmt.Errorf("key %q: %w", kid, ErrUnknownKey)
+}
+
+// JWK is the public part of a key as described in RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes every key that has not expired, including keys that only
+// start signing later, so that verifiers can fetch them ahead of a rotation.
+func (r *Keyring) JWKS() JWKS {
+	now := time.Now()
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range r.keys {
+		if key.expired(now) {
+			continue
+		}
+
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// keyRecord is the shape of one key in a keyring file. File is the path of a
+// PEM private key, relative to the keyring file.
+type keyRecord struct {
+	Kid       string     `json:"kid"`
+	File      string     `json:"file"`
+	SignFrom  time.Time  `json:"sign_from"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// Load reads a JSON keyring file of the form
+//
+//	{"keys": [{"kid": "2024-01", "file": "2024-01.pem",
+//	           "sign_from": "2024-01-01T00:00:00Z", "expires_at": null}]}
+//
+// To rotate, add the new key with a future sign_from and give the old key an
+// expires_at at least one access token lifetime after that.
+func Load(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Keys []keyRecord `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("keyring %s: %v", path, err)
+	}
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("keyring %s has no keys", path)
+	}
+
+	keys := make([]*Key, 0, len(file.Keys))
+	for _, record := range file.Keys {
+		keyPath := record.File
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+
+		pemData, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		private, err := ParsePrivateKeyPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", record.Kid, err)
+		}
+
+		var expiresAt time.Time
+		if record.ExpiresAt != nil {
+			expiresAt = *record.ExpiresAt
+		}
+		key, err := NewKey(record.Kid, private, record.SignFrom, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return New(keys...)
+}
+
+// ParsePrivateKeyPEM reads a PKCS #8 RSA or Ed25519 key, or a PKCS #1 RSA key.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+
+	return signer, nil
+}
+
+// GeneratePEM creates a new private key for the algorithm and encodes it as
+// PKCS #8 PEM.
+func GeneratePEM(alg string) ([]byte, error) {
+	var private crypto.Signer
+	var err error
+	switch alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 3072)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s, expected %s or %s", alg, AlgRS256, AlgEdDSA)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
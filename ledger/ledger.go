@@ -0,0 +1,184 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type LedgerHandlers interface {
+	HandleTransfer(*gin.Context)
+	HandleDeposit(*gin.Context)
+	HandleWithdraw(*gin.Context)
+	HandleGetTransactions(*gin.Context)
+}
+
+type StoreHandler struct {
+	store storage.Storage
+}
+
+func NewLedgerHandler(store storage.Storage) *StoreHandler {
+	return &StoreHandler{
+		store: store,
+	}
+}
+
+func (s *StoreHandler) HandleTransfer(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	transferRequest := new(types.TransferRequest)
+	if err := c.ShouldBindJSON(transferRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !transferRequest.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": storage.ErrInvalidAmount.Error()})
+		return
+	}
+
+	for _, number := range []string{transferRequest.FromAccount, transferRequest.ToAccount} {
+		if _, err := types.ParseAccountNumber(number); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	from, err := s.store.GetAccountByNumber(stdCtx, transferRequest.FromAccount)
+	if err != nil || !services.IsOwner(c, from.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Source account not found"})
+		return
+	}
+
+	to, err := s.store.GetAccountByNumber(stdCtx, transferRequest.ToAccount)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Destination account not found"})
+		return
+	}
+
+	transfer := types.NewTransfer(from.ID, to.ID, transferRequest.Amount, transferRequest.Description)
+	posted, err := s.store.PostLedgerTransaction(stdCtx, transfer)
+	if err != nil {
+		c.JSON(ledgerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, posted)
+}
+
+func (s *StoreHandler) HandleDeposit(c *gin.Context) {
+	s.handleExternalMovement(c, types.NewDeposit)
+}
+
+func (s *StoreHandler) HandleWithdraw(c *gin.Context) {
+	s.handleExternalMovement(c, types.NewWithdrawal)
+}
+
+// handleExternalMovement posts money into or out of the caller's own account.
+func (s *StoreHandler) handleExternalMovement(c *gin.Context, build func(int, types.Money, string) *types.LedgerTransaction) {
+	stdCtx := c.Request.Context()
+	amountRequest := new(types.AmountRequest)
+	if err := c.ShouldBindJSON(amountRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !amountRequest.Amount.IsPositive() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": storage.ErrInvalidAmount.Error()})
+		return
+	}
+
+	account, ok := s.loadAccount(c, services.IsOwner)
+	if !ok {
+		return
+	}
+
+	posted, err := s.store.PostLedgerTransaction(stdCtx, build(account.ID, amountRequest.Amount, amountRequest.Description))
+	if err != nil {
+		c.JSON(ledgerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, posted)
+}
+
+func (s *StoreHandler) HandleGetTransactions(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	account, ok := s.loadAccount(c, services.CanAccess)
+	if !ok {
+		return
+	}
+
+	page, limit, err := getPagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, err := s.store.GetStatement(stdCtx, account.ID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
+
+// loadAccount loads the account from the :id param and checks that allowed
+// lets the caller at it: services.IsOwner to move money, services.CanAccess
+// to read. Accounts the caller may not use are reported as not found.
+func (s *StoreHandler) loadAccount(c *gin.Context, allowed func(*gin.Context, int) bool) (*types.Account, bool) {
+	id, err := services.GetId(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	account, err := s.store.GetAccountById(c.Request.Context(), id)
+	if err != nil || !allowed(c, account.ID) {
+		services.ResourceNotFound(c, "account", id)
+		return nil, false
+	}
+
+	return account, true
+}
+
+func getPagination(c *gin.Context) (int, int, error) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, fmt.Errorf("invalid page %s", c.Query("page"))
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageLimit)))
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+
+	return page, limit, nil
+}
+
+func ledgerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrInsufficientFunds):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrAccountDeleted),
+		errors.Is(err, storage.ErrAccountSuspended),
+		errors.Is(err, storage.ErrInvalidAmount),
+		errors.Is(err, storage.ErrSameAccount),
+		errors.Is(err, storage.ErrUnbalanced),
+		errors.Is(err, types.ErrCurrencyMismatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,95 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. Implementations must be safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// LogSender prints messages to stdout instead of sending them, for local
+// development only: the bodies hold live verification and reset links.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, msg *Message) error {
+	fmt.Printf("Mail to %s: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender writes every message to its own file in Dir, so that local
+// setups and tests can read what would have been sent.
+type FileSender struct {
+	Dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func (s *FileSender) Send(ctx context.Context, msg *Message) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	s.mu.Unlock()
+
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().UTC().Format("20060102T150405"), seq, sanitize(msg.To))
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte(content), 0600)
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+var ErrNoSender = errors.New("MAIL_SENDER is not set, expected smtp, or file or log for local development")
+
+// FromEnv picks a sender from MAIL_SENDER: "smtp" sends through the server
+// described by SMTPFromEnv, "file" writes to MAIL_DIR (default ./mail-out)
+// and "log" prints. There is no default, so that a server without a mailer
+// fails instead of printing links that grant access to accounts.
+func FromEnv() (Sender, error) {
+	switch os.Getenv("MAIL_SENDER") {
+	case "smtp":
+		sender, err := SMTPFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return sender, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail-out"
+		}
+		return &FileSender{Dir: dir}, nil
+	case "log":
+		return LogSender{}, nil
+	case "":
+		return nil, ErrNoSender
+	default:
+		return nil, fmt.Errorf("unknown MAIL_SENDER %q, expected log, file or smtp", os.Getenv("MAIL_SENDER"))
+	}
+}
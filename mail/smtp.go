@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTPSender delivers messages through an SMTP server. Username may be empty
+// for relays that do not require authentication; otherwise PLAIN auth is
+// used, which net/smtp only allows over TLS or to localhost.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPFromEnv configures an SMTPSender from SMTP_HOST, SMTP_PORT (default
+// 587), SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM.
+func SMTPFromEnv() (*SMTPSender, error) {
+	s := &SMTPSender{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+	if s.Host == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required for the smtp sender")
+	}
+	if s.Port == "" {
+		s.Port = "587"
+	}
+	if s.From == "" {
+		s.From = "no-reply@" + s.Host
+	}
+	return s, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid header in message to %q", msg.To)
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		s.From, msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	// net/smtp takes no context, so the deadline is not enforced mid-send.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{msg.To}, []byte(content))
+}
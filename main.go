@@ -1,27 +1,250 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/ElenaGrasovskaya/gobank/account"
+	"github.com/ElenaGrasovskaya/gobank/budget"
+	"github.com/ElenaGrasovskaya/gobank/exchange"
+	"github.com/ElenaGrasovskaya/gobank/keyring"
 	"github.com/ElenaGrasovskaya/gobank/router"
+	"github.com/ElenaGrasovskaya/gobank/services"
 	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
 )
 
 func main() {
-	store, err := storage.NewPostgresStore()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "rates" {
+		store, err := newStore()
+		if err != nil {
+			log.Fatalf("Failed to initialize the store: %v", err)
+		}
+		if err := loadRates(store, os.Args[2]); err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 3 && os.Args[1] == "role" {
+		store, err := newStore()
+		if err != nil {
+			log.Fatalf("Failed to initialize the store: %v", err)
+		}
+		if err := setRole(store, os.Args[2], os.Args[3]); err != nil {
+			log.Fatalf("Failed to set role: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		store, err := newStore()
+		if err != nil {
+			log.Fatalf("Failed to initialize the store: %v", err)
+		}
+		purged, err := account.PurgeExpiredAccounts(context.Background(), store, account.RetentionPeriod(), time.Now().UTC())
+		fmt.Printf("Purged %d deleted accounts\n", purged)
+		if err != nil {
+			log.Fatalf("Failed to purge accounts: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 3 && os.Args[1] == "keygen" {
+		if err := generateKey(os.Args[2], os.Args[3]); err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		return
+	}
+
+	store, err := newStore()
 	if err != nil {
 		log.Fatalf("Failed to initialize the store: %v", err)
 	}
 
-	if err := store.Init(); err != nil {
-		log.Fatalf("Failed to initialize the store: %v", err)
+	if _, err := services.Keyring(); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
+	if _, err := services.Notifier(); err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); path != "" {
+		if err := loadRates(store, path); err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+	}
+
+	// Deleted accounts past their grace period are purged here, or by
+	// `gobank purge` from cron when PURGE_JOB=off.
+	if os.Getenv("PURGE_JOB") != "off" {
+		go account.RunPurgeJob(context.Background(), store, time.Hour)
 	}
 
 	r := router.SetupRouter(store)
+	if err := serve(r); err != nil {
+		log.Fatalf("Failed to run server: %v", err)
+	}
+}
+
+// shutdownTimeout bounds how long requests in flight may take to finish
+// once the server is asked to stop.
+const shutdownTimeout = 30 * time.Second
+
+// serve runs the API until SIGINT or SIGTERM, then lets requests in flight
+// finish and waits for the budget checks and mails they started, so that
+// nothing is lost on a restart.
+func serve(handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":3000", Handler: handler}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
 	fmt.Println("JSON API server is running on port: 3000")
 
-	if err := r.Run(":3000"); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	budget.WaitForThresholdChecks()
+	services.WaitForBackgroundMail()
+	return err
+}
+
+// newStore uses Postgres unless APP_STORAGE=memory is set for local development.
+func newStore() (storage.Storage, error) {
+	if os.Getenv("APP_STORAGE") == "memory" {
+		fmt.Println("Using in-memory store, data will not be persisted")
+		return storage.NewMemoryStore(), nil
+	}
+
+	store, err := storage.NewPostgresStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Init(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// loadRates imports a CSV or JSON exchange rate file, as done by
+// `gobank rates <file>` or at startup through EXCHANGE_RATES_FILE.
+func loadRates(store storage.Storage, path string) error {
+	rates, err := exchange.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := store.SaveExchangeRates(context.Background(), rates); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d exchange rates from %s\n", len(rates), path)
+	return nil
+}
+
+// setRole handles `gobank role <email> <user|admin>`, which is how the
+// first admin gets promoted.
+func setRole(store storage.Storage, email, role string) error {
+	if role != types.RoleUser && role != types.RoleAdmin {
+		return fmt.Errorf("unknown role %s, expected %s or %s", role, types.RoleUser, types.RoleAdmin)
+	}
+
+	ctx := context.Background()
+	account, err := store.GetAccountByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if err := store.SetAccountRole(ctx, account.ID, role); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account %s is now %s\n", email, role)
+	return nil
+}
+
+// runMigrate handles `gobank migrate`. Without flags it applies every
+// pending migration.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := flags.Int("to", -1, "migrate up or down to this schema version")
+	rollback := flags.Int("rollback", 0, "revert this many of the latest migrations")
+	if err := flags.Parse(args); err != nil {
+		return err
 	}
+
+	store, err := storage.NewPostgresStore()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch {
+	case *rollback > 0:
+		err = store.Rollback(ctx, *rollback)
+	case *to >= 0:
+		err = store.MigrateTo(ctx, *to)
+	default:
+		err = store.MigrateUp(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema is at version %d\n", version)
+	return nil
+}
+
+// generateKey writes a new PEM private key for the keyring, refusing to
+// overwrite an existing file.
+func generateKey(alg, path string) error {
+	data, err := keyring.GeneratePEM(alg)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s key to %s\n", alg, path)
+	return nil
 }
@@ -0,0 +1,106 @@
+// Package notify delivers messages to account holders, such as verification
+// links and password resets, independently of how they are transported.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/mail"
+)
+
+// Kinds of notification.
+const (
+	KindVerifyEmail        = "verify_email"
+	KindPasswordReset      = "password_reset"
+	KindConfirmEmailChange = "confirm_email_change"
+	KindBudgetThreshold    = "budget_threshold"
+)
+
+// Notification is one message to one account holder. Data carries the
+// values the body was built from, for transports that want them.
+type Notification struct {
+	Kind      string            `json:"kind"`
+	AccountId int               `json:"account_id"`
+	To        string            `json:"to"`
+	Subject   string            `json:"subject"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Notifier delivers notifications. Implementations must be safe for
+// concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// MailNotifier sends notifications as plain-text email.
+type MailNotifier struct {
+	Sender mail.Sender
+}
+
+func (m *MailNotifier) Notify(ctx context.Context, n *Notification) error {
+	return m.Sender.Send(ctx, &mail.Message{To: n.To, Subject: n.Subject, Body: n.Body})
+}
+
+// FileNotifier appends every notification to Path as a line of JSON, for
+// local setups that have no mail server.
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (f *FileNotifier) Notify(ctx context.Context, n *Notification) error {
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now().UTC()
+	}
+	line, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// FromEnv picks a notifier from NOTIFIER: "file" appends to NOTIFY_FILE
+// (default ./mail-out/notifications.jsonl), and "mail" or unset sends email
+// through the sender chosen by mail.FromEnv, which has to be configured.
+func FromEnv() (Notifier, error) {
+	switch os.Getenv("NOTIFIER") {
+	case "file":
+		path := os.Getenv("NOTIFY_FILE")
+		if path == "" {
+			path = filepath.Join("mail-out", "notifications.jsonl")
+		}
+		return &FileNotifier{Path: path}, nil
+	case "", "mail":
+		sender, err := mail.FromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return &MailNotifier{Sender: sender}, nil
+	default:
+		return nil, fmt.Errorf("unknown NOTIFIER %q, expected mail or file", os.Getenv("NOTIFIER"))
+	}
+}
@@ -0,0 +1,192 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/exchange"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandlers interface {
+	HandleExpensesByCategory(*gin.Context)
+	HandleExpensesByMonth(*gin.Context)
+}
+
+type StoreHandler struct {
+	store     storage.Storage
+	converter *exchange.Converter
+}
+
+func NewReportHandler(store storage.Storage) *StoreHandler {
+	return &StoreHandler{
+		store:     store,
+		converter: exchange.NewConverter(store),
+	}
+}
+
+func (s *StoreHandler) HandleExpensesByCategory(c *gin.Context) {
+	s.handleReport(c, func(report *types.ExpenseReport, exp *types.Expense, value types.Money) error {
+		for _, total := range report.Categories {
+			if sameCategory(total.CategoryId, exp.CategoryId) {
+				return addTo(&total.Total, &total.Count, value)
+			}
+		}
+		report.Categories = append(report.Categories, &types.CategoryTotal{
+			CategoryId: exp.CategoryId,
+			Count:      1,
+			Total:      value,
+		})
+		return nil
+	})
+}
+
+func (s *StoreHandler) HandleExpensesByMonth(c *gin.Context) {
+	s.handleReport(c, func(report *types.ExpenseReport, exp *types.Expense, value types.Money) error {
+		month := exp.CreatedAt.UTC().Format("2006-01")
+		for _, total := range report.Months {
+			if total.Month == month {
+				return addTo(&total.Total, &total.Count, value)
+			}
+		}
+		report.Months = append(report.Months, &types.MonthTotal{
+			Month: month,
+			Count: 1,
+			Total: value,
+		})
+		return nil
+	})
+}
+
+// handleReport converts each of the caller's expenses in the requested range
+// into the account's base currency and hands it to group.
+func (s *StoreHandler) handleReport(c *gin.Context, group func(*types.ExpenseReport, *types.Expense, types.Money) error) {
+	stdCtx := c.Request.Context()
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := services.GetDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := &types.ExpenseQuery{From: from}
+	if to != nil {
+		before := to.AddDate(0, 0, 1)
+		query.Before = &before
+	}
+	expenses, err := s.store.GetExpenseForUser(stdCtx, userId, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load expenses"})
+		return
+	}
+
+	categories, err := s.store.GetCategoriesForUser(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+
+	report, err := s.buildReport(stdCtx, account.Balance.Currency, expenses.Expenses, from, to, group)
+	if err != nil {
+		if errors.Is(err, storage.ErrRateNotFound) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	names := map[int]string{}
+	for _, category := range categories {
+		names[category.ID] = category.Name
+	}
+	for _, total := range report.Categories {
+		if total.CategoryId != nil {
+			total.Category = names[*total.CategoryId]
+		}
+	}
+	sort.SliceStable(report.Categories, func(i, j int) bool {
+		return report.Categories[i].Category < report.Categories[j].Category
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *StoreHandler) buildReport(ctx context.Context, currency string, expenses []*types.Expense, from, to *time.Time, group func(*types.ExpenseReport, *types.Expense, types.Money) error) (*types.ExpenseReport, error) {
+	report := &types.ExpenseReport{
+		Currency: currency,
+		From:     from,
+		To:       to,
+		Total:    types.NewMoney(0, currency),
+	}
+
+	for _, exp := range expenses {
+		if from != nil && exp.CreatedAt.Before(*from) {
+			continue
+		}
+		if to != nil && !exp.CreatedAt.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+
+		value, err := s.converter.Convert(ctx, exp.ExpenseValue, currency, exp.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("expense %d: %w", exp.ID, err)
+		}
+
+		if err := addTo(&report.Total, &report.Count, value); err != nil {
+			return nil, err
+		}
+		if err := group(report, exp, value); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(report.Categories, func(i, j int) bool {
+		return categoryOrder(report.Categories[i].CategoryId) < categoryOrder(report.Categories[j].CategoryId)
+	})
+	sort.Slice(report.Months, func(i, j int) bool {
+		return report.Months[i].Month < report.Months[j].Month
+	})
+
+	return report, nil
+}
+
+func sameCategory(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// categoryOrder sorts uncategorized expenses first.
+func categoryOrder(id *int) int {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func addTo(total *types.Money, count *int, value types.Money) error {
+	sum, err := total.Add(value)
+	if err != nil {
+		return err
+	}
+	*total = sum
+	*count++
+	return nil
+}
@@ -4,9 +4,15 @@ import (
 	"net/http"
 
 	"github.com/ElenaGrasovskaya/gobank/account"
+	"github.com/ElenaGrasovskaya/gobank/budget"
+	"github.com/ElenaGrasovskaya/gobank/category"
 	"github.com/ElenaGrasovskaya/gobank/expense"
+	"github.com/ElenaGrasovskaya/gobank/ledger"
+	"github.com/ElenaGrasovskaya/gobank/report"
 	"github.com/ElenaGrasovskaya/gobank/services"
 	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/tag"
+	"github.com/ElenaGrasovskaya/gobank/types"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,6 +20,11 @@ func SetupRouter(store storage.Storage) *gin.Engine {
 	e := expense.NewExpenseHandler(store)
 	a := account.NewAccountHandler(store)
 	s := services.NewServiceHandler(store)
+	l := ledger.NewLedgerHandler(store)
+	rp := report.NewReportHandler(store)
+	cg := category.NewCategoryHandler(store)
+	tg := tag.NewTagHandler(store)
+	b := budget.NewBudgetHandler(store)
 
 	r := gin.Default()
 	r.Use(services.CorsMiddleware())
@@ -23,25 +34,84 @@ func SetupRouter(store storage.Storage) *gin.Engine {
 	})
 
 	authMiddleware := services.WithJWTAuthMiddleware(store)
+	adminOnly := services.RequireRole(types.RoleAdmin)
 
 	r.GET("/", s.HandleHealth)
 	r.POST("/login", s.HandleLogin)
+	r.POST("/login/2fa", s.HandleLoginTwoFactor)
 	r.POST("/register", s.HandleRegister)
 	r.POST("/logout", s.HandleLogout)
+	r.POST("/token/refresh", s.HandleRefreshToken)
+	r.POST("/password/forgot", s.HandleForgotPassword)
+	r.POST("/password/reset", s.HandleResetPassword)
+	r.GET("/verify", s.HandleVerifyEmail)
+	r.POST("/verify/resend", s.HandleResendVerification)
+	r.GET("/.well-known/jwks.json", s.HandleJWKS)
+
+	// Every authenticated route either names the scope an API key needs for
+	// it or is reserved for signed-in sessions.
+	scope := services.RequireScope
+	sessionOnly := services.RequireSession()
 
 	authGroup := r.Group("/")
 	authGroup.Use(authMiddleware)
 	{
-		authGroup.POST("/expense", e.HandleCreateExpense)
-		authGroup.POST("/expense/:id", e.HandleUpdateExpense)
-		authGroup.GET("/expense", e.HandleGetExpenseForUser)
-		authGroup.DELETE("/expense/:id", e.HandleDeleteExpense)
-		authGroup.GET("/expenses", e.HandleGetAllExpense)
-
-		authGroup.GET("/accounts", a.HandleGetAccount)
-		authGroup.POST("/account", a.HandleCreateAccount)
-		authGroup.DELETE("/account/:id", a.HandleDeleteAccount)
-		authGroup.GET("/account/:id", a.HandleGetAccountById)
+		authGroup.POST("/expense", scope(types.ScopeExpensesWrite), e.HandleCreateExpense)
+		authGroup.POST("/expense/:id", scope(types.ScopeExpensesWrite), e.HandleUpdateExpense)
+		authGroup.GET("/expense", scope(types.ScopeExpensesRead), e.HandleGetExpenseForUser)
+		authGroup.GET("/expense/search", scope(types.ScopeExpensesRead), e.HandleSearchExpenses)
+		authGroup.DELETE("/expense/:id", scope(types.ScopeExpensesWrite), e.HandleDeleteExpense)
+		authGroup.GET("/expenses", adminOnly, scope(types.ScopeExpensesRead), e.HandleGetAllExpense)
+
+		authGroup.GET("/categories", scope(types.ScopeExpensesRead), cg.HandleGetCategories)
+		authGroup.POST("/categories", scope(types.ScopeExpensesWrite), cg.HandleCreateCategory)
+		authGroup.GET("/categories/:id", scope(types.ScopeExpensesRead), cg.HandleGetCategoryById)
+		authGroup.PUT("/categories/:id", scope(types.ScopeExpensesWrite), cg.HandleUpdateCategory)
+		authGroup.DELETE("/categories/:id", scope(types.ScopeExpensesWrite), cg.HandleDeleteCategory)
+
+		authGroup.GET("/tags", scope(types.ScopeExpensesRead), tg.HandleGetTags)
+		authGroup.POST("/tags/merge", scope(types.ScopeExpensesWrite), tg.HandleMergeTags)
+		authGroup.PATCH("/tags/:name", scope(types.ScopeExpensesWrite), tg.HandleRenameTag)
+
+		authGroup.GET("/budgets", scope(types.ScopeExpensesRead), b.HandleGetBudgets)
+		authGroup.POST("/budgets", scope(types.ScopeExpensesWrite), b.HandleCreateBudget)
+		authGroup.GET("/budgets/status", scope(types.ScopeExpensesRead), b.HandleBudgetStatus)
+		authGroup.GET("/budgets/:id", scope(types.ScopeExpensesRead), b.HandleGetBudgetById)
+		authGroup.PUT("/budgets/:id", scope(types.ScopeExpensesWrite), b.HandleUpdateBudget)
+		authGroup.DELETE("/budgets/:id", scope(types.ScopeExpensesWrite), b.HandleDeleteBudget)
+
+		authGroup.GET("/accounts", adminOnly, scope(types.ScopeAccountsRead), a.HandleGetAccount)
+		authGroup.POST("/account", adminOnly, scope(types.ScopeAccountsWrite), a.HandleCreateAccount)
+		authGroup.DELETE("/account/:id", adminOnly, scope(types.ScopeAccountsWrite), a.HandleDeleteAccount)
+		authGroup.POST("/account/:id/suspend", adminOnly, scope(types.ScopeAccountsWrite), a.HandleSuspendAccount)
+		authGroup.POST("/account/:id/restore", adminOnly, scope(types.ScopeAccountsWrite), a.HandleRestoreAccount)
+		authGroup.POST("/account/:id/purge", adminOnly, scope(types.ScopeAccountsWrite), a.HandlePurgeAccount)
+		authGroup.GET("/account/:id", scope(types.ScopeAccountsRead), a.HandleGetAccountById)
+		authGroup.PATCH("/account/:id", sessionOnly, a.HandleUpdateAccount)
+
+		authGroup.POST("/transfer", scope(types.ScopeLedgerWrite), l.HandleTransfer)
+		authGroup.POST("/account/:id/deposit", scope(types.ScopeLedgerWrite), l.HandleDeposit)
+		authGroup.POST("/account/:id/withdraw", scope(types.ScopeLedgerWrite), l.HandleWithdraw)
+		authGroup.GET("/account/:id/transactions", scope(types.ScopeLedgerRead), l.HandleGetTransactions)
+
+		authGroup.GET("/reports/expenses/categories", scope(types.ScopeReportsRead), rp.HandleExpensesByCategory)
+		authGroup.GET("/reports/expenses/monthly", scope(types.ScopeReportsRead), rp.HandleExpensesByMonth)
+
+		authGroup.GET("/sessions", sessionOnly, s.HandleGetSessions)
+		authGroup.DELETE("/sessions", sessionOnly, s.HandleRevokeAllSessions)
+		authGroup.DELETE("/sessions/:id", sessionOnly, s.HandleRevokeSession)
+
+		authGroup.POST("/apikeys", sessionOnly, s.HandleCreateApiKey)
+		authGroup.GET("/apikeys", sessionOnly, s.HandleGetApiKeys)
+		authGroup.DELETE("/apikeys/:id", sessionOnly, s.HandleRevokeApiKey)
+
+		authGroup.PUT("/account/password", sessionOnly, s.HandleChangePassword)
+		authGroup.GET("/account/email/confirm", sessionOnly, s.HandleConfirmEmailChange)
+
+		authGroup.POST("/account/2fa", sessionOnly, s.HandleEnrollTwoFactor)
+		authGroup.POST("/account/2fa/confirm", sessionOnly, s.HandleConfirmTwoFactor)
+		authGroup.POST("/account/2fa/disable", sessionOnly, s.HandleDisableTwoFactor)
+		authGroup.POST("/account/2fa/recovery-codes", sessionOnly, s.HandleRegenerateRecoveryCodes)
 	}
 
 	return r
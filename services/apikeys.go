@@ -0,0 +1,199 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ApiKeyPrefix starts every API key, which tells them apart from JWTs in
+	// the Authorization header.
+	ApiKeyPrefix = "gbk_"
+
+	// apiKeyDisplayLength is how much of a key is kept in clear for listings.
+	apiKeyDisplayLength = 12
+
+	maxApiKeyNameLength = 100
+)
+
+// authenticateApiKey finishes WithJWTAuthMiddleware for callers that present
+// an API key instead of a session token.
+func authenticateApiKey(c *gin.Context, s storage.Storage, credential string) {
+	stdCtx := c.Request.Context()
+
+	key, err := s.GetApiKeyByHash(stdCtx, hashToken(credential))
+	if err != nil {
+		permissionDenied(c)
+		return
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		permissionDenied(c)
+		return
+	}
+
+	account, err := s.GetAccountById(stdCtx, key.AccountId)
+	if err != nil || !account.IsActive() {
+		permissionDenied(c)
+		return
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > sessionTouchInterval {
+		if err := s.TouchApiKey(stdCtx, key.ID, now); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	c.Set(ContextUserId, account.ID)
+	c.Set(ContextRole, account.Role)
+	c.Set(ContextApiKey, key)
+	c.Next()
+}
+
+// RequireScope lets API key callers through only if their key was granted
+// the scope. Sessions carry every scope. It must run after
+// WithJWTAuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := c.Get(ContextApiKey); ok && !value.(*types.ApiKey).HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("API key lacks the %s scope", scope)})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession refuses API key callers, for endpoints that manage the
+// credentials themselves.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(ContextApiKey); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a signed-in session"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func validateApiKeyRequest(req *types.CreateApiKeyRequest) error {
+	if req.Name == "" || len(req.Name) > maxApiKeyNameLength {
+		return fmt.Errorf("name is required and may have at most %d characters", maxApiKeyNameLength)
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		known := false
+		for _, s := range types.ApiKeyScopes {
+			known = known || s == scope
+		}
+		if !known {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+
+	return nil
+}
+
+// HandleCreateApiKey issues a new API key for the caller. The key is only
+// ever returned in this response.
+func (s *StoreHandler) HandleCreateApiKey(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	req := new(types.CreateApiKeyRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateApiKeyRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	plain := ApiKeyPrefix + secret
+
+	var scopes []string
+	seen := map[string]bool{}
+	for _, scope := range req.Scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	key := &types.ApiKey{
+		AccountId: c.GetInt(ContextUserId),
+		Name:      req.Name,
+		Prefix:    plain[:apiKeyDisplayLength],
+		KeyHash:   hashToken(plain),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.store.CreateApiKey(stdCtx, key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, &types.CreateApiKeyResponse{ApiKey: key, Key: plain})
+}
+
+func (s *StoreHandler) HandleGetApiKeys(c *gin.Context) {
+	stdCtx := c.Request.Context()
+
+	keys, err := s.store.GetApiKeysForAccount(stdCtx, c.GetInt(ContextUserId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+func (s *StoreHandler) HandleRevokeApiKey(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid id given %s", c.Param("id"))})
+		return
+	}
+
+	keys, err := s.store.GetApiKeysForAccount(stdCtx, c.GetInt(ContextUserId))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, key := range keys {
+		if key.ID == id {
+			if err := s.store.RevokeApiKey(stdCtx, id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, "API key revoked")
+			return
+		}
+	}
+
+	ResourceNotFound(c, "api key", id)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+// Keys under which WithJWTAuthMiddleware stores the caller in the gin context.
+const (
+	ContextUserId    = "userId"
+	ContextRole      = "role"
+	ContextSessionId = "sessionId"
+	ContextApiKey    = "apiKey"
+)
+
+// CanAccess reports whether the authenticated caller may act on a resource
+// belonging to ownerId: either they own it or they are an admin.
+func CanAccess(c *gin.Context, ownerId int) bool {
+	if c.GetString(ContextRole) == types.RoleAdmin {
+		return true
+	}
+
+	userId, err := GetIdFromCookie(c)
+	if err != nil {
+		return false
+	}
+
+	return userId == ownerId
+}
+
+// IsOwner reports whether the authenticated caller owns the resource of
+// ownerId. Unlike CanAccess it makes no exception for admins, for actions
+// such as moving money that only the owner may take.
+func IsOwner(c *gin.Context, ownerId int) bool {
+	userId, err := GetIdFromCookie(c)
+	if err != nil {
+		return false
+	}
+
+	return userId == ownerId
+}
+
+// RequireRole lets the request through only if the caller has one of the
+// given roles. It must run after WithJWTAuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString(ContextRole)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		c.Abort()
+	}
+}
+
+// ResourceNotFound answers with 404 both for missing resources and for ones
+// the caller may not see, so that ids of other users cannot be probed.
+func ResourceNotFound(c *gin.Context, resource string, id int) {
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s %d not found", resource, id)})
+}
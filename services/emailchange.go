@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailChangeTTL is how long the link confirming a new address stays valid.
+const EmailChangeTTL = 24 * time.Hour
+
+// CheckPassword reports whether password is the current password of account.
+func CheckPassword(account *types.Account, password string) bool {
+	ok, _ := encrPassword(password, account.Password)
+	return ok
+}
+
+// SendEmailChangeConfirmation mails newEmail the link that moves account to
+// it. The address does not change until the link is followed, and only the
+// most recent link works.
+func SendEmailChangeConfirmation(ctx context.Context, store storage.Storage, account *types.Account, newEmail string) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	err = store.CreateEmailChangeToken(ctx, &types.EmailChangeToken{
+		AccountId: account.ID,
+		Email:     newEmail,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(EmailChangeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := Notifier()
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/account/email/confirm?token=%s", apiURL(), url.QueryEscape(token))
+	return n.Notify(ctx, &notify.Notification{
+		Kind:      notify.KindConfirmEmailChange,
+		AccountId: account.ID,
+		To:        newEmail,
+		Subject:   "Confirm your new GoBank email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen this link within %v while signed in to use this address for your account:\n\n%s\n\nIf you did not ask for this, ignore this email.",
+			account.FirstName, EmailChangeTTL, link),
+		Data: map[string]string{"link": link, "email": newEmail},
+	})
+}
+
+// HandleConfirmEmailChange moves the caller's account to the address named
+// by a token from SendEmailChangeConfirmation. Each token works once. The
+// caller gets a new access token carrying the address, as a cookie and in
+// the body.
+func (s *StoreHandler) HandleConfirmEmailChange(c *gin.Context) {
+	stdCtx := c.Request.Context()
+
+	change, err := s.store.ConsumeEmailChangeToken(stdCtx, c.GetInt(ContextUserId), hashToken(c.Query("token")), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailChangeTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, change.AccountId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if account.Email != change.Email {
+		account.Email = change.Email
+		if err := s.store.UpdateAccount(stdCtx, account); err != nil {
+			if errors.Is(err, storage.ErrEmailTaken) {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	token, err := ReissueAccessToken(c, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &types.AccountUpdateResponse{ResponceAccount: types.NewResponceAccount(account), Token: token})
+}
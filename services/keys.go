@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/ElenaGrasovskaya/gobank/keyring"
+	"github.com/gin-gonic/gin"
+)
+
+// keysMu guards keys and keysErr, which SetKeyring may swap while requests
+// are being served.
+var (
+	keysMu   sync.RWMutex
+	keysOnce sync.Once
+	keys     *keyring.Keyring
+	keysErr  error
+)
+
+// Keyring returns the keyring access tokens are signed with. It is loaded
+// from the file in JWT_KEYRING_FILE on first use; without one a throwaway key
+// is generated.
+func Keyring() (*keyring.Keyring, error) {
+	keysOnce.Do(func() {
+		keysMu.Lock()
+		defer keysMu.Unlock()
+		if keys != nil {
+			return
+		}
+
+		path := os.Getenv("JWT_KEYRING_FILE")
+		if path == "" {
+			fmt.Println("JWT_KEYRING_FILE is not set, using a generated signing key; tokens will not survive a restart")
+			keys, keysErr = keyring.Generate()
+			return
+		}
+		keys, keysErr = keyring.Load(path)
+	})
+
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keys, keysErr
+}
+
+// SetKeyring replaces the keyring, for tests and for callers that build
+// their own.
+func SetKeyring(k *keyring.Keyring) {
+	keysOnce.Do(func() {})
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	keys, keysErr = k, nil
+}
+
+// HandleJWKS publishes the public signing keys so that other services can
+// verify access tokens.
+func (s *StoreHandler) HandleJWKS(c *gin.Context) {
+	k, err := Keyring()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Signing keys are not available"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, k.JWKS())
+}
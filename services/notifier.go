@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/notify"
+)
+
+var (
+	notifierOnce sync.Once
+	notifier     notify.Notifier
+	notifierErr  error
+)
+
+// Notifier returns the notifier used for account messages, configured from
+// NOTIFIER and MAIL_SENDER on first use.
+func Notifier() (notify.Notifier, error) {
+	notifierOnce.Do(func() {
+		if notifier != nil {
+			return
+		}
+		notifier, notifierErr = notify.FromEnv()
+	})
+
+	return notifier, notifierErr
+}
+
+// SetNotifier replaces the notifier, for tests and for callers that build
+// their own.
+func SetNotifier(n notify.Notifier) {
+	notifierOnce.Do(func() {})
+	notifier, notifierErr = n, nil
+}
+
+// backgroundMailTimeout bounds one mail sent by sendInBackground, including
+// the lookups before it.
+const backgroundMailTimeout = time.Minute
+
+// backgroundMail tracks the mails started by sendInBackground.
+var backgroundMail sync.WaitGroup
+
+// sendInBackground runs send after the request has been answered, so that
+// the response takes as long whether or not anything is sent. Failures are
+// logged under what.
+func sendInBackground(what string, send func(ctx context.Context) error) {
+	backgroundMail.Add(1)
+	go func() {
+		defer backgroundMail.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundMailTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			fmt.Printf("%s failed: %v\n", what, err)
+		}
+	}()
+}
+
+// WaitForBackgroundMail blocks until every mail started in the background
+// has been sent or has failed. main calls it on shutdown.
+func WaitForBackgroundMail() {
+	backgroundMail.Wait()
+}
+
+// appURL is the base URL of the frontend that links in emails point to.
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:5173"
+}
+
+// apiURL is the base URL of this server, for links that call it directly.
+func apiURL() string {
+	if url := os.Getenv("API_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// PasswordResetTTL is how long a mailed reset link stays valid.
+	PasswordResetTTL = time.Hour
+
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+)
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return fmt.Errorf("password must have between %d and %d characters", minPasswordLength, maxPasswordLength)
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// HandleChangePassword sets a new password for the caller. It takes the
+// current password, signs out every other session and voids mailed reset
+// links.
+func (s *StoreHandler) HandleChangePassword(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+	req := new(types.ChangePasswordRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if ok, _ := encrPassword(req.CurrentPassword, account.Password); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if err := s.store.SetAccountPassword(stdCtx, userId, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.store.RevokeAccountSessions(stdCtx, userId, c.GetString(ContextSessionId)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.store.RevokePasswordResetTokens(stdCtx, userId, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, "Password changed")
+}
+
+// HandleForgotPassword mails a reset link if the email belongs to an
+// account. The answer is the same either way: the link is sent in the
+// background, so neither the response nor its timing tells which emails
+// have accounts. Requests are throttled per email and IP.
+func (s *StoreHandler) HandleForgotPassword(c *gin.Context) {
+	req := new(types.ForgotPasswordRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if throttleMail(c, s.store, types.LoginResetRequested, req.Email) {
+		return
+	}
+
+	sendInBackground("Password reset", func(ctx context.Context) error {
+		account, err := s.store.GetAccountByEmail(ctx, req.Email)
+		if err != nil {
+			return nil // unknown emails get nothing
+		}
+		return s.sendPasswordReset(ctx, account)
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset link has been sent"})
+}
+
+// sendPasswordReset stores a new reset token for account and mails it the
+// link that uses it.
+func (s *StoreHandler) sendPasswordReset(ctx context.Context, account *types.Account) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	err = s.store.CreatePasswordResetToken(ctx, &types.PasswordResetToken{
+		AccountId: account.ID,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(PasswordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := Notifier()
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", appURL(), url.QueryEscape(token))
+	return n.Notify(ctx, &notify.Notification{
+		Kind:      notify.KindPasswordReset,
+		AccountId: account.ID,
+		To:        account.Email,
+		Subject:   "Reset your GoBank password",
+		Body: fmt.Sprintf("Use this link within %v to choose a new password:\n\n%s\n\nIf you did not ask for this, ignore this email.",
+			PasswordResetTTL, link),
+		Data: map[string]string{"link": link},
+	})
+}
+
+// HandleResetPassword sets a new password with a mailed reset token, signs
+// out every session of the account and voids its other reset links.
+func (s *StoreHandler) HandleResetPassword(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	req := new(types.ResetPasswordRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := s.store.ConsumePasswordResetToken(stdCtx, hashToken(req.Token), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, storage.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := hashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	if err := s.store.SetAccountPassword(stdCtx, token.AccountId, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.store.RevokeAccountSessions(stdCtx, token.AccountId, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.store.RevokePasswordResetTokens(stdCtx, token.AccountId, time.Now().UTC()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	clearSession(c)
+	c.JSON(http.StatusOK, "Password reset")
+}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ElenaGrasovskaya/gobank/storage"
 	"github.com/ElenaGrasovskaya/gobank/types"
@@ -17,6 +20,24 @@ type ServiceHandlers interface {
 	HandleHealth(*gin.Context)
 	HandleRegister(*gin.Context)
 	HandleLogout(*gin.Context)
+	HandleRefreshToken(*gin.Context)
+	HandleGetSessions(*gin.Context)
+	HandleRevokeSession(*gin.Context)
+	HandleRevokeAllSessions(*gin.Context)
+	HandleJWKS(*gin.Context)
+	HandleCreateApiKey(*gin.Context)
+	HandleGetApiKeys(*gin.Context)
+	HandleRevokeApiKey(*gin.Context)
+	HandleLoginTwoFactor(*gin.Context)
+	HandleEnrollTwoFactor(*gin.Context)
+	HandleConfirmTwoFactor(*gin.Context)
+	HandleDisableTwoFactor(*gin.Context)
+	HandleRegenerateRecoveryCodes(*gin.Context)
+	HandleChangePassword(*gin.Context)
+	HandleForgotPassword(*gin.Context)
+	HandleResetPassword(*gin.Context)
+	HandleVerifyEmail(*gin.Context)
+	HandleResendVerification(*gin.Context)
 	HandleDeleteExpense(*gin.Context)
 	HandleUpdateExpense(*gin.Context)
 }
@@ -35,6 +56,8 @@ func (s *StoreHandler) HandleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, "Welcome to GoBank API")
 }
 
+// HandleLogin checks email and password. Failed attempts are throttled per
+// email and per IP, and every outcome is recorded in login_attempts.
 func (s *StoreHandler) HandleLogin(c *gin.Context) {
 	var req types.LoginRequest
 	stdCtx := c.Request.Context()
@@ -43,34 +66,61 @@ func (s *StoreHandler) HandleLogin(c *gin.Context) {
 		return
 	}
 
+	email := normalizeLoginEmail(req.Email)
+	ip := c.ClientIP()
+	if throttleLogin(c, s.store, email) {
+		return
+	}
+
 	account, err := s.store.GetAccountByEmail(stdCtx, req.Email)
 	if err != nil {
-		clearSession(c)
-		c.JSON(http.StatusExpectationFailed, gin.H{"error": err.Error()})
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		recordLoginAttempt(stdCtx, s.store, email, ip, types.LoginFailure, "unknown_email")
+		invalidCredentials(c)
 		return
 	}
 
 	comparePass, err := encrPassword(req.Password, account.Password)
-	if err != nil {
-		clearSession(c)
-		c.JSON(http.StatusResetContent, gin.H{"error": err.Error()})
+	if err != nil || !comparePass {
+		recordLoginAttempt(stdCtx, s.store, email, ip, types.LoginFailure, "wrong_password")
+		invalidCredentials(c)
+		return
+	}
+	if refuseInactive(c, s.store, account, email) {
 		return
 	}
 
-	if comparePass {
-
-		setSession(c, account.ID, req.Email)
-		userResponse := types.LoginResponse{
-			ID:        account.ID,
-			FirstName: account.FirstName,
-			LastName:  account.LastName,
-			Email:     account.Email,
+	required, err := twoFactorRequired(stdCtx, s.store, account.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if required {
+		challenge, err := createPendingToken(account)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pending token"})
+			return
 		}
+		// Not a success yet: failed codes keep counting against the email.
+		recordLoginAttempt(stdCtx, s.store, email, ip, types.LoginChallenge, "")
+		c.JSON(http.StatusAccepted, challenge)
+		return
+	}
 
-		c.JSON(http.StatusOK, userResponse)
-	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credentials"})
+	if err := setSession(c, s.store, account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session token"})
+		return
 	}
+	recordLoginAttempt(stdCtx, s.store, email, ip, types.LoginSuccess, "")
+
+	userResponse := types.LoginResponse{
+		ID:        account.ID,
+		FirstName: account.FirstName,
+		LastName:  account.LastName,
+		Email:     account.Email,
+	}
+
+	c.JSON(http.StatusOK, userResponse)
 }
 
 func (s *StoreHandler) HandleRegister(c *gin.Context) {
@@ -81,9 +131,9 @@ func (s *StoreHandler) HandleRegister(c *gin.Context) {
 		return
 	}
 
-	account, err := types.NewAccount(createAccountRequest.FirstName, createAccountRequest.LastName, createAccountRequest.Email, createAccountRequest.Password)
+	account, err := types.NewAccount(createAccountRequest.FirstName, createAccountRequest.LastName, createAccountRequest.Email, createAccountRequest.Password, createAccountRequest.Currency)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create account"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -94,59 +144,221 @@ func (s *StoreHandler) HandleRegister(c *gin.Context) {
 	}
 
 	newAcc, err := s.store.CreateAccount(stdCtx, account)
+	if errors.Is(err, storage.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Account " + account.Email + " already exists"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store new account"})
 		return
 	}
 
-	c.JSON(http.StatusAccepted, newAcc)
+	// The account exists either way; a failed send can be retried through
+	// the resend endpoint.
+	if err := SendVerificationEmail(stdCtx, newAcc); err != nil {
+		fmt.Println(err)
+	}
+
+	c.JSON(http.StatusAccepted, types.NewResponceAccount(newAcc))
 }
 
+// HandleLogout revokes the current session on the server, identified by the
+// access token or, once that has expired, by the refresh token.
 func (s *StoreHandler) HandleLogout(c *gin.Context) {
 	fmt.Printf("Logging out")
+	stdCtx := c.Request.Context()
+
+	var sessionIds []string
+	if credential, err := accessCredential(c); err == nil {
+		if sessionId, err := sessionIdFromToken(credential); err == nil {
+			sessionIds = append(sessionIds, sessionId)
+		}
+	}
+	if refreshToken, err := c.Cookie(refreshCookie); err == nil {
+		if record, err := s.store.GetRefreshToken(stdCtx, hashToken(refreshToken)); err == nil {
+			sessionIds = append(sessionIds, record.FamilyId)
+		}
+	}
+
+	for _, sessionId := range sessionIds {
+		if err := s.store.RevokeSession(stdCtx, sessionId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+			return
+		}
+	}
+
 	clearSession(c)
 	c.JSON(http.StatusOK, "User logged out")
 }
 
-func CreateJWT(account *types.Account) (string, error) {
+// CreateJWT signs a short-lived access token for the account. Every token
+// carries iat, exp, a random jti and the session id as sid.
+func CreateJWT(account *types.Account, sessionId string) (string, error) {
+	tokenString, _, err := createAccessToken(account.ID, account.Email, sessionId)
+	return tokenString, err
+}
+
+func createAccessToken(userId int, userEmail, sessionId string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL)
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
 	claims := &jwt.MapClaims{
-		"id":    account.ID,
-		"email": account.Email,
+		"id":    userId,
+		"email": userEmail,
+		"iat":   now.Unix(),
+		"exp":   expiresAt.Unix(),
+		"jti":   jti,
+		"sid":   sessionId,
+	}
+	k, err := Keyring()
+	if err != nil {
+		return "", time.Time{}, err
 	}
-	secrtet := os.Getenv("JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	return token.SignedString([]byte(secrtet))
+	tokenString, err := k.Sign(claims)
+	return tokenString, expiresAt, err
 }
 
 func validateJWT(tokenString string) (*jwt.Token, error) {
-	secret := os.Getenv("JWT_SECRET")
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+	k, err := Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(tokenString, k.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	// jwt only checks exp when it is present; tokens without one never expire.
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token has no valid expiry")
+	}
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("not an access token")
+	}
+
+	return token, nil
 }
-func setSession(c *gin.Context, userId int, userEmail string) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":    userId,
-		"email": userEmail,
-	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+
+func sessionIdFromToken(tokenString string) (string, error) {
+	token, err := validateJWT(tokenString)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session token"})
-		return
-	} else {
-		fmt.Println("token created")
-		c.SetSameSite(http.SameSiteNoneMode)
-		c.SetCookie("token", tokenString, 30*24*60*60, "/", "", true, true)
+		return "", err
+	}
 
+	claims, _ := token.Claims.(jwt.MapClaims)
+	sessionId, ok := claims["sid"].(string)
+	if !ok || sessionId == "" {
+		return "", fmt.Errorf("token has no session")
 	}
+
+	return sessionId, nil
+}
+
+// StartSession records a new login of the account.
+func StartSession(ctx context.Context, store storage.Storage, account *types.Account, userAgent, ip string) (*types.Session, error) {
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	session := &types.Session{
+		ID:         id,
+		AccountId:  account.ID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	if err := store.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// setSession starts a new session for the account, with a refresh token
+// family of the same id, and sets both the access and the refresh token
+// cookies.
+func setSession(c *gin.Context, store storage.Storage, account *types.Account) error {
+	stdCtx := c.Request.Context()
+	session, err := StartSession(stdCtx, store, account, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return err
+	}
+
+	refreshToken, record, err := newRefreshToken(account.ID, session.ID)
+	if err != nil {
+		return err
+	}
+	if err := store.CreateRefreshToken(stdCtx, record); err != nil {
+		return err
+	}
+
+	_, err = setTokenCookies(c, account.ID, account.Email, session.ID, refreshToken)
+	return err
+}
+
+// setTokenCookies signs a new access token for the session and sets it
+// together with the given refresh token. It returns when the access token
+// expires.
+func setTokenCookies(c *gin.Context, userId int, userEmail, sessionId, refreshToken string) (time.Time, error) {
+	expiresAt, err := setAccessCookie(c, userId, userEmail, sessionId)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	c.SetCookie(refreshCookie, refreshToken, int(RefreshTokenTTL.Seconds()), "/", "", true, true)
+
+	return expiresAt, nil
+}
+
+func setAccessCookie(c *gin.Context, userId int, userEmail, sessionId string) (time.Time, error) {
+	tokenString, expiresAt, err := createAccessToken(userId, userEmail, sessionId)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	fmt.Println("token created")
+	setAccessTokenCookie(c, tokenString)
+
+	return expiresAt, nil
+}
+
+func setAccessTokenCookie(c *gin.Context, tokenString string) {
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie("token", tokenString, int(AccessTokenTTL.Seconds()), "/", "", true, true)
+}
+
+// ReissueAccessToken gives the caller a fresh access token cookie for its
+// current session if it belongs to account, so that the email claim follows
+// a changed address, and returns the token for clients that send it as a
+// bearer token. It returns "" for callers acting on another account. Other
+// sessions of the account are rejected by WithJWTAuthMiddleware until they
+// refresh, which reads the stored email.
+func ReissueAccessToken(c *gin.Context, account *types.Account) (string, error) {
+	sessionId := c.GetString(ContextSessionId)
+	if sessionId == "" || c.GetInt(ContextUserId) != account.ID {
+		return "", nil
+	}
+
+	tokenString, _, err := createAccessToken(account.ID, account.Email, sessionId)
+	if err != nil {
+		return "", err
+	}
+	setAccessTokenCookie(c, tokenString)
+	return tokenString, nil
 }
 
 func clearSession(c *gin.Context) {
 	c.SetCookie("token", "", -1, "/", "", true, true)
+	c.SetCookie(refreshCookie, "", -1, "/", "", true, true)
 }
 
 func encrPassword(reqPassword string, dbPassword string) (bool, error) {
@@ -177,13 +389,44 @@ func GetId(c *gin.Context) (int, error) {
 	return id, nil
 }
 
+// GetDateRange reads the optional from and to query params as YYYY-MM-DD.
+// Both days are included in the range.
+func GetDateRange(c *gin.Context) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+
+	if value := c.Query("from"); value != "" {
+		day, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid from date %s, expected YYYY-MM-DD", value)
+		}
+		from = &day
+	}
+
+	if value := c.Query("to"); value != "" {
+		day, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid to date %s, expected YYYY-MM-DD", value)
+		}
+		to = &day
+	}
+
+	return from, to, nil
+}
+
+// GetIdFromCookie returns the id of the caller. Behind WithJWTAuthMiddleware
+// it is read from the context, which also covers bearer tokens and API keys;
+// otherwise the access token is parsed.
 func GetIdFromCookie(c *gin.Context) (int, error) {
-	cookie, err := c.Cookie("token")
+	if userId, ok := c.Get(ContextUserId); ok {
+		return userId.(int), nil
+	}
+
+	credential, err := accessCredential(c)
 	if err != nil {
 		fmt.Println(err)
 		return 0, fmt.Errorf("failed to retrieve cookie: %v", err)
 	}
-	token, err := validateJWT(cookie)
+	token, err := validateJWT(credential)
 	if err != nil {
 
 		return 0, fmt.Errorf("permission denied: %v", err)
@@ -203,6 +446,23 @@ func GetIdFromCookie(c *gin.Context) (int, error) {
 	}
 }
 
+// accessCredential returns the access token or API key the caller presented.
+// An "Authorization: Bearer" header takes precedence over the token cookie.
+func accessCredential(c *gin.Context) (string, error) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return c.Cookie("token")
+	}
+
+	scheme, credential, _ := strings.Cut(header, " ")
+	credential = strings.TrimSpace(credential)
+	if !strings.EqualFold(scheme, "Bearer") || credential == "" {
+		return "", fmt.Errorf("malformed Authorization header")
+	}
+
+	return credential, nil
+}
+
 type ApiError struct {
 	Error string `json:"error"`
 }
@@ -220,7 +480,7 @@ func CorsMiddleware() gin.HandlerFunc {
 		if allowedOrigins[origin] {
 			c.Header("Access-Control-Allow-Origin", origin)
 			c.Header("Access-Control-Allow-Credentials", "true")
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
 			c.Header("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 		}
 		// Set CORS headers
@@ -241,14 +501,19 @@ func WithJWTAuthMiddleware(s storage.Storage) gin.HandlerFunc {
 		fmt.Println("calling JWT auth middleware")
 		stdCtx := c.Request.Context()
 
-		cookie, err := c.Cookie("token")
+		credential, err := accessCredential(c)
 		if err != nil {
 			fmt.Println(err)
 			permissionDenied(c)
 			return
 		}
 
-		token, err := validateJWT(cookie)
+		if strings.HasPrefix(credential, ApiKeyPrefix) {
+			authenticateApiKey(c, s, credential)
+			return
+		}
+
+		token, err := validateJWT(credential)
 		if err != nil {
 			permissionDenied(c)
 			return
@@ -264,7 +529,7 @@ func WithJWTAuthMiddleware(s storage.Storage) gin.HandlerFunc {
 			email := claims["email"].(string)
 
 			account, err := s.GetAccountById(stdCtx, int(id))
-			if err != nil {
+			if err != nil || !account.IsActive() {
 				permissionDenied(c)
 				return
 			}
@@ -274,6 +539,24 @@ func WithJWTAuthMiddleware(s storage.Storage) gin.HandlerFunc {
 				return
 			}
 
+			sessionId, _ := claims["sid"].(string)
+			session, err := s.GetSession(stdCtx, sessionId)
+			if err != nil || session.RevokedAt != nil || session.AccountId != account.ID {
+				permissionDenied(c)
+				return
+			}
+			if time.Since(session.LastSeenAt) > sessionTouchInterval {
+				if err := s.TouchSession(stdCtx, session.ID, time.Now().UTC()); err != nil {
+					fmt.Println(err)
+				}
+			}
+
+			// The role is read from the database rather than the token so
+			// that a demotion takes effect immediately.
+			c.Set(ContextUserId, account.ID)
+			c.Set(ContextRole, account.Role)
+			c.Set(ContextSessionId, session.ID)
+
 			fmt.Printf("%s %v", email, id)
 			// If authentication is successful, proceed with the request
 			c.Next()
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetSessions lists the caller's active sessions, most recently used
+// first.
+func (s *StoreHandler) HandleGetSessions(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+	currentId := c.GetString(ContextSessionId)
+
+	sessions, err := s.store.GetSessionsForAccount(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]*types.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, &types.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentId,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleRevokeSession ends one of the caller's sessions. Revoking the current
+// session also clears the cookies.
+func (s *StoreHandler) HandleRevokeSession(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+	id := c.Param("id")
+
+	session, err := s.store.GetSession(stdCtx, id)
+	if err != nil || session.AccountId != userId || session.RevokedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session " + id + " not found"})
+		return
+	}
+
+	if err := s.store.RevokeSession(stdCtx, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if session.ID == c.GetString(ContextSessionId) {
+		clearSession(c)
+	}
+	c.JSON(http.StatusOK, "Session revoked")
+}
+
+// HandleRevokeAllSessions logs the caller out everywhere, including the
+// current session.
+func (s *StoreHandler) HandleRevokeAllSessions(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+
+	if err := s.store.RevokeAccountSessions(stdCtx, userId, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	clearSession(c)
+	c.JSON(http.StatusOK, "All sessions revoked")
+}
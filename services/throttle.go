@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginPolicy limits failed logins for one key, an email or an IP address.
+// The first Free failures cost nothing; after that each failure doubles the
+// wait before the next attempt, starting at BaseDelay. At Lockout failures
+// the key is locked for LockoutDuration after the last one.
+type loginPolicy struct {
+	Free            int
+	Lockout         int
+	BaseDelay       time.Duration
+	LockoutDuration time.Duration
+}
+
+var (
+	emailLoginPolicy = loginPolicy{Free: 3, Lockout: 10, BaseDelay: time.Second, LockoutDuration: 15 * time.Minute}
+
+	// IPs get more room since several users may share one.
+	ipLoginPolicy = loginPolicy{Free: 10, Lockout: 50, BaseDelay: time.Second, LockoutDuration: 15 * time.Minute}
+
+	// loginFailureWindow is how far back failures are counted.
+	loginFailureWindow = 15 * time.Minute
+
+	// Mails that can be asked for without logging in, such as reset links,
+	// are limited the same way, counting every request.
+	emailMailPolicy = loginPolicy{Free: 3, Lockout: 5, BaseDelay: time.Minute, LockoutDuration: time.Hour}
+	ipMailPolicy    = loginPolicy{Free: 10, Lockout: 30, BaseDelay: time.Minute, LockoutDuration: time.Hour}
+	mailWindow      = time.Hour
+)
+
+// dummyPasswordHash is compared against for unknown emails, so that they take
+// as long to reject as a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// wait returns how long after the last failure the next attempt is allowed.
+func (p loginPolicy) wait(failures int) time.Duration {
+	if failures >= p.Lockout {
+		return p.LockoutDuration
+	}
+	if failures <= p.Free {
+		return 0
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(failures-p.Free-1))
+	if delay > float64(p.LockoutDuration) {
+		return p.LockoutDuration
+	}
+	return time.Duration(delay)
+}
+
+func retryAfter(p loginPolicy, failures int, last, now time.Time) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+	if remaining := last.Add(p.wait(failures)).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginRetryAfter returns how long the email and IP must wait before the
+// next login attempt, or zero if they may try now.
+func loginRetryAfter(ctx context.Context, store storage.Storage, email, ip string) (time.Duration, error) {
+	now := time.Now().UTC()
+	failures, err := store.GetLoginFailures(ctx, email, ip, now.Add(-loginFailureWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	wait := retryAfter(emailLoginPolicy, failures.Email, failures.LastByEmail, now)
+	if byIP := retryAfter(ipLoginPolicy, failures.IP, failures.LastByIP, now); byIP > wait {
+		wait = byIP
+	}
+	return wait, nil
+}
+
+func recordLoginAttempt(ctx context.Context, store storage.Storage, email, ip, outcome, reason string) {
+	err := store.RecordLoginAttempt(ctx, &types.LoginAttempt{
+		Email:     email,
+		IP:        ip,
+		Outcome:   outcome,
+		Reason:    reason,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// throttleLogin answers with 429 and returns true if the caller has to wait
+// before trying to log in as email again.
+func throttleLogin(c *gin.Context, store storage.Storage, email string) bool {
+	stdCtx := c.Request.Context()
+
+	wait, err := loginRetryAfter(stdCtx, store, email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	if wait == 0 {
+		return false
+	}
+
+	recordLoginAttempt(stdCtx, store, email, c.ClientIP(), types.LoginThrottled, "")
+	c.Header("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login attempts, try again later"})
+	return true
+}
+
+// throttleMail answers with 429 and returns true if email or the caller's IP
+// asked for too many mails of one kind lately, recorded as outcome.
+// Otherwise it records the request. Whether the email has an account plays
+// no part, so the answer reveals nothing about it.
+func throttleMail(c *gin.Context, store storage.Storage, outcome, email string) bool {
+	stdCtx := c.Request.Context()
+	now := time.Now().UTC()
+	email = normalizeLoginEmail(email)
+
+	counts, err := store.CountLoginAttempts(stdCtx, outcome, email, c.ClientIP(), now.Add(-mailWindow))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+
+	wait := retryAfter(emailMailPolicy, counts.Email, counts.LastByEmail, now)
+	if byIP := retryAfter(ipMailPolicy, counts.IP, counts.LastByIP, now); byIP > wait {
+		wait = byIP
+	}
+	if wait > 0 {
+		c.Header("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+		return true
+	}
+
+	recordLoginAttempt(stdCtx, store, email, c.ClientIP(), outcome, "")
+	return false
+}
+
+// invalidCredentials is the single answer to a wrong email, password or
+// code, so that responses do not reveal which accounts exist.
+func invalidCredentials(c *gin.Context) {
+	clearSession(c)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// inactiveReasons explains, per status, why an account with the right
+// password may not sign in.
+var inactiveReasons = map[string]string{
+	types.StatusPending:   "Email address is not verified",
+	types.StatusSuspended: "Account is suspended",
+	types.StatusDeleted:   "Account is deleted",
+}
+
+// refuseInactive answers 403 and returns true unless the account is active.
+// Only called once the password is known to be right, so it reveals nothing
+// to someone guessing.
+func refuseInactive(c *gin.Context, store storage.Storage, account *types.Account, email string) bool {
+	if account.IsActive() {
+		return false
+	}
+
+	message, ok := inactiveReasons[account.Status]
+	if !ok {
+		message = "Account is not active"
+	}
+
+	recordLoginAttempt(c.Request.Context(), store, email, c.ClientIP(), types.LoginRefused, strings.ToLower(account.Status))
+	clearSession(c)
+	c.JSON(http.StatusForbidden, gin.H{"error": message})
+	return true
+}
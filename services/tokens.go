@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	refreshCookie = "refresh_token"
+
+	// sessionTouchInterval limits how often a session's last-seen time is
+	// written back.
+	sessionTouchInterval = time.Minute
+)
+
+// randomToken returns n random bytes encoded for use in URLs and cookies.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signPurposeToken signs a short-lived token for one step of a flow, such as
+// the second login factor. The purpose claim keeps it from being accepted as
+// an access token or for any other purpose.
+func signPurposeToken(account *types.Account, purpose string, expiresAt time.Time) (string, error) {
+	k, err := Keyring()
+	if err != nil {
+		return "", err
+	}
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	return k.Sign(jwt.MapClaims{
+		"id":      account.ID,
+		"email":   account.Email,
+		"purpose": purpose,
+		"iat":     time.Now().Unix(),
+		"exp":     expiresAt.Unix(),
+		"jti":     jti,
+	})
+}
+
+// parsePurposeToken checks a token from signPurposeToken and returns the
+// account id and email it was issued for.
+func parsePurposeToken(tokenString, purpose string) (int, string, error) {
+	k, err := Keyring()
+	if err != nil {
+		return 0, "", err
+	}
+
+	token, err := jwt.Parse(tokenString, k.Keyfunc)
+	if err != nil {
+		return 0, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyExpiresAt(time.Now().Unix(), true) || claims["purpose"] != purpose {
+		return 0, "", fmt.Errorf("invalid %s token", purpose)
+	}
+	id, _ := claims["id"].(float64)
+	email, _ := claims["email"].(string)
+
+	return int(id), email, nil
+}
+
+// newRefreshToken creates a refresh token and the record to store for it.
+// The family is the session the token belongs to; RotateRefreshToken fills it
+// in for rotated tokens.
+func newRefreshToken(accountId int, familyId string) (string, *types.RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
+	return token, &types.RefreshToken{
+		AccountId: accountId,
+		FamilyId:  familyId,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}, nil
+}
+
+// HandleRefreshToken exchanges a refresh token, from the cookie or the JSON
+// body, for a new access token and a new refresh token. Each refresh token
+// works once; replaying one revokes every token of its login.
+func (s *StoreHandler) HandleRefreshToken(c *gin.Context) {
+	stdCtx := c.Request.Context()
+
+	refreshToken, err := c.Cookie(refreshCookie)
+	if err != nil || refreshToken == "" {
+		var req types.RefreshTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing refresh token"})
+			return
+		}
+		refreshToken = req.RefreshToken
+	}
+
+	nextToken, next, err := newRefreshToken(0, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create refresh token"})
+		return
+	}
+
+	next, err = s.store.RotateRefreshToken(stdCtx, hashToken(refreshToken), next)
+	if err != nil {
+		clearSession(c)
+		if errors.Is(err, storage.ErrRefreshTokenInvalid) || errors.Is(err, storage.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, next.AccountId)
+	if err != nil || !account.IsActive() {
+		if err := s.store.RevokeRefreshFamily(stdCtx, next.FamilyId); err != nil {
+			fmt.Println(err)
+		}
+		clearSession(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	expiresAt, err := setTokenCookies(c, account.ID, account.Email, next.FamilyId, nextToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, types.TokenResponse{ExpiresAt: expiresAt})
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/totp"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// PendingTokenTTL is how long a user has to enter the second factor
+	// after a correct password.
+	PendingTokenTTL = 5 * time.Minute
+
+	pendingPurpose     = "2fa"
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	recoveryAlphabet   = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+var errInvalidCode = errors.New("invalid code")
+
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "GoBank"
+}
+
+// twoFactorRequired reports whether the account has confirmed two-factor
+// login.
+func twoFactorRequired(ctx context.Context, store storage.Storage, accountId int) (bool, error) {
+	tf, err := store.GetTwoFactor(ctx, accountId)
+	if errors.Is(err, storage.ErrTwoFactorNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.EnabledAt != nil, nil
+}
+
+// createPendingToken signs the token that stands between the password check
+// and the code check. It carries a purpose claim so it is never accepted as
+// an access token.
+func createPendingToken(account *types.Account) (*types.TwoFactorChallenge, error) {
+	expiresAt := time.Now().Add(PendingTokenTTL)
+	tokenString, err := signPurposeToken(account, pendingPurpose, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TwoFactorChallenge{TwoFactorRequired: true, PendingToken: tokenString, ExpiresAt: expiresAt}, nil
+}
+
+func validatePendingToken(tokenString string) (int, string, error) {
+	return parsePurposeToken(tokenString, pendingPurpose)
+}
+
+// verifySecondFactor accepts either a TOTP code, which may only be used once,
+// or an unused recovery code.
+func verifySecondFactor(ctx context.Context, store storage.Storage, tf *types.TwoFactor, req *types.TwoFactorCodeRequest) error {
+	switch {
+	case req.Code != "":
+		step, ok := totp.Validate(tf.Secret, req.Code, time.Now())
+		if !ok {
+			return errInvalidCode
+		}
+		if err := store.UseTotpStep(ctx, tf.AccountId, step); err != nil {
+			if errors.Is(err, storage.ErrTotpCodeReused) {
+				return errInvalidCode
+			}
+			return err
+		}
+		return nil
+	case req.RecoveryCode != "":
+		err := store.UseRecoveryCode(ctx, tf.AccountId, hashToken(normalizeRecoveryCode(req.RecoveryCode)))
+		if errors.Is(err, storage.ErrRecoveryCodeInvalid) {
+			return errInvalidCode
+		}
+		return err
+	default:
+		return errInvalidCode
+	}
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// newRecoveryCodes creates a fresh set of recovery codes, formatted as
+// xxxxx-xxxxx, together with the records to store for them.
+func newRecoveryCodes(accountId int) ([]string, []*types.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]*types.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = recoveryAlphabet[int(b[j])%len(recoveryAlphabet)]
+		}
+
+		code := string(b[:recoveryCodeLength/2]) + "-" + string(b[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		records = append(records, &types.RecoveryCode{AccountId: accountId, CodeHash: hashToken(string(b))})
+	}
+
+	return codes, records, nil
+}
+
+// secondFactorError answers a failed code check.
+func secondFactorError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidCode) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// HandleLoginTwoFactor is the second step of a login with two-factor
+// authentication: it trades the pending token and a code for a session.
+func (s *StoreHandler) HandleLoginTwoFactor(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	req := new(types.TwoFactorLoginRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, email, err := validatePendingToken(req.PendingToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired pending token"})
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, id)
+	if err != nil || account.Email != email || !account.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired pending token"})
+		return
+	}
+
+	tf, err := s.store.GetTwoFactor(stdCtx, account.ID)
+	if err != nil || tf.EnabledAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired pending token"})
+		return
+	}
+
+	loginEmail := normalizeLoginEmail(account.Email)
+	if throttleLogin(c, s.store, loginEmail) {
+		return
+	}
+
+	if err := verifySecondFactor(stdCtx, s.store, tf, &req.TwoFactorCodeRequest); err != nil {
+		if errors.Is(err, errInvalidCode) {
+			recordLoginAttempt(stdCtx, s.store, loginEmail, c.ClientIP(), types.LoginFailure, "invalid_code")
+		}
+		secondFactorError(c, err)
+		return
+	}
+
+	if err := setSession(c, s.store, account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session token"})
+		return
+	}
+	recordLoginAttempt(stdCtx, s.store, loginEmail, c.ClientIP(), types.LoginSuccess, "")
+
+	c.JSON(http.StatusOK, types.LoginResponse{
+		ID:        account.ID,
+		FirstName: account.FirstName,
+		LastName:  account.LastName,
+		Email:     account.Email,
+	})
+}
+
+// HandleEnrollTwoFactor starts two-factor enrollment. It returns the secret
+// for the authenticator app and recovery codes; login only requires a code
+// after HandleConfirmTwoFactor.
+func (s *StoreHandler) HandleEnrollTwoFactor(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+
+	account, err := s.store.GetAccountById(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	enabled, err := twoFactorRequired(stdCtx, s.store, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create secret"})
+		return
+	}
+	codes, records, err := newRecoveryCodes(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+
+	tf := &types.TwoFactor{AccountId: userId, Secret: secret, CreatedAt: time.Now().UTC()}
+	if err := s.store.SaveTwoFactor(stdCtx, tf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.store.ReplaceRecoveryCodes(stdCtx, userId, records); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, &types.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(totpIssuer(), account.Email, secret),
+		RecoveryCodes:   codes,
+	})
+}
+
+// HandleConfirmTwoFactor enables two-factor login once the user proves that
+// their authenticator produces valid codes.
+func (s *StoreHandler) HandleConfirmTwoFactor(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+	req := new(types.TwoFactorCodeRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tf, err := s.store.GetTwoFactor(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if tf.EnabledAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	// Only an authenticator code proves the enrollment worked.
+	if err := verifySecondFactor(stdCtx, s.store, tf, &types.TwoFactorCodeRequest{Code: req.Code}); err != nil {
+		secondFactorError(c, err)
+		return
+	}
+
+	tf, err = s.store.GetTwoFactor(stdCtx, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	tf.EnabledAt = &now
+	if err := s.store.SaveTwoFactor(stdCtx, tf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tf)
+}
+
+// enabledTwoFactor loads the caller's two-factor settings and checks the code
+// in the request, answering the request itself when either fails.
+func (s *StoreHandler) enabledTwoFactor(c *gin.Context) (*types.TwoFactor, bool) {
+	stdCtx := c.Request.Context()
+	userId := c.GetInt(ContextUserId)
+	req := new(types.TwoFactorCodeRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	tf, err := s.store.GetTwoFactor(stdCtx, userId)
+	if err != nil || tf.EnabledAt == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": storage.ErrTwoFactorNotEnrolled.Error()})
+		return nil, false
+	}
+
+	if err := verifySecondFactor(stdCtx, s.store, tf, req); err != nil {
+		secondFactorError(c, err)
+		return nil, false
+	}
+
+	return tf, true
+}
+
+// HandleDisableTwoFactor turns two-factor login off. It takes a code or a
+// recovery code so that a stolen session alone cannot do it.
+func (s *StoreHandler) HandleDisableTwoFactor(c *gin.Context) {
+	tf, ok := s.enabledTwoFactor(c)
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteTwoFactor(c.Request.Context(), tf.AccountId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, "Two-factor authentication disabled")
+}
+
+// HandleRegenerateRecoveryCodes replaces all recovery codes of the caller,
+// used or not.
+func (s *StoreHandler) HandleRegenerateRecoveryCodes(c *gin.Context) {
+	tf, ok := s.enabledTwoFactor(c)
+	if !ok {
+		return
+	}
+
+	codes, records, err := newRecoveryCodes(tf.AccountId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	if err := s.store.ReplaceRecoveryCodes(c.Request.Context(), tf.AccountId, records); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, &types.RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// EmailVerificationTTL is how long a verification link stays valid.
+	EmailVerificationTTL = 24 * time.Hour
+
+	verifyPurpose = "verify_email"
+)
+
+// SendVerificationEmail sends a pending account the link that activates it.
+// The token is signed rather than stored and names the email address, so it
+// stops working if the address changes.
+func SendVerificationEmail(ctx context.Context, account *types.Account) error {
+	token, err := signPurposeToken(account, verifyPurpose, time.Now().Add(EmailVerificationTTL))
+	if err != nil {
+		return err
+	}
+
+	n, err := Notifier()
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify?token=%s", apiURL(), url.QueryEscape(token))
+	return n.Notify(ctx, &notify.Notification{
+		Kind:      notify.KindVerifyEmail,
+		AccountId: account.ID,
+		To:        account.Email,
+		Subject:   "Verify your GoBank email address",
+		Body: fmt.Sprintf("Welcome to GoBank, %s!\n\nOpen this link within %v to activate your account:\n\n%s\n\nIf you did not sign up, ignore this email.",
+			account.FirstName, EmailVerificationTTL, link),
+		Data: map[string]string{"link": link},
+	})
+}
+
+// HandleVerifyEmail activates the account named by a verification token.
+// Following the link again after that is harmless.
+func (s *StoreHandler) HandleVerifyEmail(c *gin.Context) {
+	stdCtx := c.Request.Context()
+	invalid := gin.H{"error": "Invalid or expired verification link"}
+
+	id, email, err := parsePurposeToken(c.Query("token"), verifyPurpose)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+
+	account, err := s.store.GetAccountById(stdCtx, id)
+	if err != nil || account.Email != email {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+
+	err = s.store.ActivateAccount(stdCtx, account.ID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, "Email verified")
+	case errors.Is(err, storage.ErrAccountNotPending) && account.Status == types.StatusActive:
+		c.JSON(http.StatusOK, "Email already verified")
+	case errors.Is(err, storage.ErrAccountNotPending):
+		c.JSON(http.StatusBadRequest, invalid)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// HandleResendVerification sends a fresh link to a pending account. Like the
+// password reset, the link is sent in the background and requests are
+// throttled, so the answer does not reveal whether the account exists.
+func (s *StoreHandler) HandleResendVerification(c *gin.Context) {
+	req := new(types.ResendVerificationRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if throttleMail(c, s.store, types.LoginVerificationResent, req.Email) {
+		return
+	}
+
+	sendInBackground("Verification email", func(ctx context.Context) error {
+		account, err := s.store.GetAccountByEmail(ctx, req.Email)
+		if err != nil || account.Status != types.StatusPending {
+			return nil
+		}
+		return SendVerificationEmail(ctx, account)
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account is awaiting verification, a new link has been sent"})
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func (s *PostgresStore) CreateApiKey(ctx context.Context, key *types.ApiKey) error {
+	_, err := s.Db.NewInsert().Model(key).Exec(ctx)
+	return err
+}
+
+func (s *PostgresStore) GetApiKeyByHash(ctx context.Context, hash string) (*types.ApiKey, error) {
+	key := new(types.ApiKey)
+
+	err := s.Db.NewSelect().Model(key).Where("key_hash = ?", hash).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("api key not found")
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GetApiKeysForAccount returns the keys of an account that were not revoked,
+// newest first.
+func (s *PostgresStore) GetApiKeysForAccount(ctx context.Context, accountId int) ([]*types.ApiKey, error) {
+	var keys []*types.ApiKey
+	err := s.Db.NewSelect().
+		Model(&keys).
+		Where("account_id = ?", accountId).
+		Where("revoked_at IS NULL").
+		Order("created_at DESC", "id DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *PostgresStore) TouchApiKey(ctx context.Context, id int, usedAt time.Time) error {
+	_, err := s.Db.NewUpdate().
+		Model((*types.ApiKey)(nil)).
+		Set("last_used_at = ?", usedAt).
+		Where("id = ?", id).
+		Exec(ctx)
+
+	return err
+}
+
+func (s *PostgresStore) RevokeApiKey(ctx context.Context, id int) error {
+	_, err := s.Db.NewUpdate().
+		Model((*types.ApiKey)(nil)).
+		Set("revoked_at = ?", time.Now().UTC()).
+		Where("id = ?", id).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+
+	return err
+}
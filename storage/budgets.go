@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+const budgetCategoryConstraint = "budget_category_key"
+
+// BudgetAlert records that a budget crossed a threshold in a month, so that
+// the user hears about it once.
+type BudgetAlert struct {
+	bun.BaseModel `bun:"table:budget_alerts,alias:ba"`
+	BudgetId      int    `bun:"budget_id,pk"`
+	Month         string `bun:"month,pk"`
+	Threshold     int    `bun:"threshold,pk"`
+}
+
+// CreateBudget stores budget together with its limit from the month it was
+// created in.
+func (s *PostgresStore) CreateBudget(ctx context.Context, budget *types.Budget) (*types.Budget, error) {
+	err := s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(budget).Exec(ctx); err != nil {
+			return err
+		}
+		return saveBudgetLimit(ctx, tx, types.NewBudgetLimit(budget, budget.CreatedAt))
+	})
+	if err != nil {
+		if constraint, ok := uniqueViolation(err); ok && constraint == budgetCategoryConstraint {
+			return nil, ErrBudgetExists
+		}
+		return nil, err
+	}
+	return budget, nil
+}
+
+// UpdateBudget saves the limit and rollover of budget. The limit applies
+// from the month of budget.UpdatedAt on; earlier months keep theirs.
+func (s *PostgresStore) UpdateBudget(ctx context.Context, budget *types.Budget) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewUpdate().
+			Model(budget).
+			Column("limit_amount", "limit_currency", "rollover", "updated_at").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("budget %d not found", budget.ID)
+		}
+
+		return saveBudgetLimit(ctx, tx, types.NewBudgetLimit(budget, budget.UpdatedAt))
+	})
+}
+
+func saveBudgetLimit(ctx context.Context, tx bun.Tx, limit *types.BudgetLimit) error {
+	_, err := tx.NewInsert().
+		Model(limit).
+		On("CONFLICT (budget_id, month) DO UPDATE").
+		Set("limit_amount = EXCLUDED.limit_amount").
+		Set("limit_currency = EXCLUDED.limit_currency").
+		Exec(ctx)
+	return err
+}
+
+// GetBudgetLimits returns the limit history of a budget, oldest first.
+func (s *PostgresStore) GetBudgetLimits(ctx context.Context, budgetId int) ([]*types.BudgetLimit, error) {
+	limits := []*types.BudgetLimit{}
+	err := s.Db.NewSelect().Model(&limits).Where("budget_id = ?", budgetId).Order("month ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+func (s *PostgresStore) DeleteBudget(ctx context.Context, id int) error {
+	_, err := s.Db.NewDelete().Model((*types.Budget)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+func (s *PostgresStore) GetBudgetById(ctx context.Context, id int) (*types.Budget, error) {
+	budget := new(types.Budget)
+	err := s.Db.NewSelect().Model(budget).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("budget %d not found", id)
+		}
+		return nil, err
+	}
+
+	return budget, nil
+}
+
+func (s *PostgresStore) GetBudgetsForUser(ctx context.Context, userId int) ([]*types.Budget, error) {
+	budgets := []*types.Budget{}
+	err := s.Db.NewSelect().Model(&budgets).Where("user_id = ?", userId).Order("id ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+// RecordBudgetAlert notes that the budget reached threshold percent in month
+// and reports whether that is news.
+func (s *PostgresStore) RecordBudgetAlert(ctx context.Context, budgetId int, month string, threshold int) (bool, error) {
+	res, err := s.Db.NewInsert().
+		Model(&BudgetAlert{BudgetId: budgetId, Month: month, Threshold: threshold}).
+		On("CONFLICT DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DeleteBudgetAlert forgets a recorded alert whose notification could not be
+// sent, so that the next check tries again.
+func (s *PostgresStore) DeleteBudgetAlert(ctx context.Context, budgetId int, month string, threshold int) error {
+	_, err := s.Db.NewDelete().
+		Model(&BudgetAlert{BudgetId: budgetId, Month: month, Threshold: threshold}).
+		WherePK().
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+const categoryNameConstraint = "category_name_key"
+
+func categoryError(err error) error {
+	if constraint, ok := uniqueViolation(err); ok && constraint == categoryNameConstraint {
+		return ErrCategoryExists
+	}
+	return err
+}
+
+func (s *PostgresStore) CreateCategory(ctx context.Context, category *types.Category) (*types.Category, error) {
+	if _, err := s.Db.NewInsert().Model(category).Exec(ctx); err != nil {
+		return nil, categoryError(err)
+	}
+	return category, nil
+}
+
+// UpdateCategory saves the name, parent, color and icon of category.
+func (s *PostgresStore) UpdateCategory(ctx context.Context, category *types.Category) error {
+	res, err := s.Db.NewUpdate().
+		Model(category).
+		Column("parent_id", "name", "color", "icon", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return categoryError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("category %d not found", category.ID)
+	}
+
+	return nil
+}
+
+// DeleteCategory removes a category. Its subcategories and expenses move up
+// to its parent, or become top-level and uncategorized.
+func (s *PostgresStore) DeleteCategory(ctx context.Context, id int) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		category := new(types.Category)
+		err := tx.NewSelect().Model(category).Where("id = ?", id).For("UPDATE").Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("category %d not found", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*types.Category)(nil)).
+			Set("parent_id = ?", category.ParentId).
+			Set("updated_at = ?", time.Now().UTC()).
+			Where("parent_id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return categoryError(err)
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*types.Expense)(nil)).
+			Set("category_id = ?", category.ParentId).
+			Where("category_id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().Model(category).WherePK().Exec(ctx)
+		return err
+	})
+}
+
+func (s *PostgresStore) GetCategoryById(ctx context.Context, id int) (*types.Category, error) {
+	category := new(types.Category)
+	err := s.Db.NewSelect().Model(category).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("category %d not found", id)
+		}
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (s *PostgresStore) GetCategoriesForUser(ctx context.Context, userId int) ([]*types.Category, error) {
+	categories := []*types.Category{}
+	err := s.Db.NewSelect().Model(&categories).Where("user_id = ?", userId).Order("id ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
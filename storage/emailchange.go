@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+// CreateEmailChangeToken stores a new token and marks the account's earlier
+// unused ones used, so that only the latest link can change the email.
+func (s *PostgresStore) CreateEmailChangeToken(ctx context.Context, token *types.EmailChangeToken) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model((*types.EmailChangeToken)(nil)).
+			Set("used_at = ?", token.CreatedAt).
+			Where("account_id = ?", token.AccountId).
+			Where("used_at IS NULL").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewInsert().Model(token).Exec(ctx)
+		return err
+	})
+}
+
+// ConsumeEmailChangeToken marks an unused, unexpired token of the account as
+// used and returns it. Like ConsumePasswordResetToken, the update is the
+// check.
+func (s *PostgresStore) ConsumeEmailChangeToken(ctx context.Context, accountId int, hash string, now time.Time) (*types.EmailChangeToken, error) {
+	token := new(types.EmailChangeToken)
+	err := s.Db.NewUpdate().
+		Model(token).
+		Set("used_at = ?", now).
+		Where("account_id = ?", accountId).
+		Where("token_hash = ?", hash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", now).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEmailChangeTokenInvalid
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import "errors"
+
+var (
+	ErrInvalidAmount     = errors.New("amount must be positive")
+	ErrSameAccount       = errors.New("source and destination accounts must differ")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrAccountDeleted    = errors.New("account is deleted")
+	ErrAccountSuspended  = errors.New("account is suspended")
+	ErrUnbalanced        = errors.New("ledger entries do not balance")
+	ErrRateNotFound      = errors.New("exchange rate not found")
+
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not set up")
+	ErrTotpCodeReused       = errors.New("code was already used")
+	ErrRecoveryCodeInvalid  = errors.New("recovery code is invalid or used")
+
+	ErrResetTokenInvalid       = errors.New("reset token is invalid or expired")
+	ErrEmailChangeTokenInvalid = errors.New("email change token is invalid or expired")
+
+	ErrAccountNotPending  = errors.New("account is not pending verification")
+	ErrAccountNotDeleted  = errors.New("account is not deleted")
+	ErrEmailTaken         = errors.New("email is already in use")
+	ErrAccountNumberTaken = errors.New("could not find a free account number")
+	ErrAccountNumberInUse = errors.New("account number is already in use")
+
+	ErrCategoryExists = errors.New("a category of this name already exists at this level")
+	ErrTagExists      = errors.New("a tag of this name already exists")
+	ErrTagNotFound    = errors.New("tag not found")
+	ErrBudgetExists   = errors.New("the category already has a budget")
+)
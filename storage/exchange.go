@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+// SaveExchangeRates inserts rates, replacing any existing rate for the same
+// pair and day.
+func (s *PostgresStore) SaveExchangeRates(ctx context.Context, rates []*types.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	_, err := s.Db.NewInsert().
+		Model(&rates).
+		On("CONFLICT (base, quote, valid_from) DO UPDATE").
+		Set("rate = EXCLUDED.rate").
+		Exec(ctx)
+
+	return err
+}
+
+// GetExchangeRate returns the most recent base/quote rate that is valid on
+// the given day.
+func (s *PostgresStore) GetExchangeRate(ctx context.Context, base, quote string, on time.Time) (*types.ExchangeRate, error) {
+	rate := new(types.ExchangeRate)
+
+	err := s.Db.NewSelect().
+		Model(rate).
+		Where("base = ?", base).
+		Where("quote = ?", quote).
+		Where("valid_from <= ?", on.UTC().Format(time.DateOnly)).
+		Order("valid_from DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, rateNotFound(base, quote, on)
+		}
+		return nil, err
+	}
+
+	return rate, nil
+}
+
+func rateNotFound(base, quote string, on time.Time) error {
+	return fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, base, quote, on.UTC().Format(time.DateOnly))
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+func (s *PostgresStore) GetExpenseForUser(ctx context.Context, userId int, q *types.ExpenseQuery) (*types.ExpensePage, error) {
+	return s.queryExpenses(ctx, q, func(sel *bun.SelectQuery) *bun.SelectQuery {
+		return sel.Where("user_id = ?", userId)
+	})
+}
+
+func (s *PostgresStore) GetAllExpense(ctx context.Context, q *types.ExpenseQuery) (*types.ExpensePage, error) {
+	return s.queryExpenses(ctx, q, nil)
+}
+
+// queryExpenses runs q over the expenses selected by scope. Pages are read
+// with a keyset on the sort column and id, fetching one row more than the
+// limit to learn whether another page follows.
+func (s *PostgresStore) queryExpenses(ctx context.Context, q *types.ExpenseQuery, scope func(*bun.SelectQuery) *bun.SelectQuery) (*types.ExpensePage, error) {
+	q = expenseQueryOrDefault(q)
+
+	var expenses []*types.Expense
+	sel := s.Db.NewSelect().Model(&expenses)
+	if scope != nil {
+		sel = scope(sel)
+	}
+
+	if q.From != nil {
+		sel = sel.Where("created_at >= ?", *q.From)
+	}
+	if q.Before != nil {
+		sel = sel.Where("created_at < ?", *q.Before)
+	}
+	if q.CategoryIds != nil {
+		sel = sel.Where("category_id IN (?)", bun.In(q.CategoryIds))
+	}
+	if len(q.Tags) > 0 {
+		sel = sel.Where("e.id IN (?)", taggedExpenses(s.Db, q.Tags, q.AllTags))
+	}
+	if q.Purpose != "" {
+		sel = sel.Where("expense_purpose = ?", q.Purpose)
+	}
+	if q.Currency != "" {
+		sel = sel.Where("expense_value_currency = ?", q.Currency)
+	}
+	if q.MinValue != nil {
+		sel = sel.Where("expense_value_currency = ?", q.MinValue.Currency).
+			Where("expense_value_amount >= ?", q.MinValue.Amount)
+	}
+	if q.MaxValue != nil {
+		sel = sel.Where("expense_value_currency = ?", q.MaxValue.Currency).
+			Where("expense_value_amount <= ?", q.MaxValue.Amount)
+	}
+
+	field, descending := q.SortBy()
+	column := bun.Ident("created_at")
+	if field == types.ExpenseSortValue {
+		column = bun.Ident("expense_value_amount")
+	}
+	direction, after := "ASC", ">"
+	if descending {
+		direction, after = "DESC", "<"
+	}
+
+	if q.Cursor != nil {
+		var key interface{} = q.Cursor.CreatedAt
+		if field == types.ExpenseSortValue {
+			key = q.Cursor.Value
+		}
+		sel = sel.Where("(?, id) "+after+" (?, ?)", column, key, q.Cursor.ID)
+	}
+
+	sel = sel.OrderExpr("? "+direction+", id "+direction, column)
+	if q.Limit > 0 {
+		sel = sel.Limit(q.Limit + 1)
+	}
+
+	if err := sel.Scan(ctx); err != nil {
+		return nil, err
+	}
+	if err := loadExpenseTags(ctx, s.Db, expenses); err != nil {
+		return nil, err
+	}
+
+	return newExpensePage(expenses, q), nil
+}
+
+func expenseQueryOrDefault(q *types.ExpenseQuery) *types.ExpenseQuery {
+	if q == nil {
+		return &types.ExpenseQuery{}
+	}
+	return q
+}
+
+// newExpensePage cuts the sorted matches of q down to its limit and points
+// the next cursor at the last expense kept.
+func newExpensePage(expenses []*types.Expense, q *types.ExpenseQuery) *types.ExpensePage {
+	if expenses == nil {
+		expenses = []*types.Expense{}
+	}
+
+	page := &types.ExpensePage{Expenses: expenses, Limit: q.Limit}
+	if q.Limit > 0 && len(expenses) > q.Limit {
+		page.Expenses = expenses[:q.Limit]
+		page.HasMore = true
+		page.NextCursor = types.NewExpenseCursor(q.SortOrder(), page.Expenses[q.Limit-1]).Encode()
+	}
+
+	return page
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+// validateLedgerTransaction checks the parts of a ledger transaction that do
+// not depend on stored state: positive movements, distinct accounts and
+// entries that sum to zero.
+func validateLedgerTransaction(ltx *types.LedgerTransaction) error {
+	if len(ltx.Entries) < 2 {
+		return ErrUnbalanced
+	}
+
+	sum := types.NewMoney(0, ltx.Entries[0].Amount.Currency)
+	seen := map[int]bool{}
+	for _, entry := range ltx.Entries {
+		if entry.Amount.IsZero() {
+			return ErrInvalidAmount
+		}
+		if seen[entry.AccountId] {
+			return ErrSameAccount
+		}
+		seen[entry.AccountId] = true
+
+		var err error
+		if sum, err = sum.Add(entry.Amount); err != nil {
+			return err
+		}
+	}
+
+	if !sum.IsZero() {
+		return ErrUnbalanced
+	}
+
+	return nil
+}
+
+// applyEntry checks that an account may take part in a movement and returns
+// its balance afterwards.
+func applyEntry(acc *types.Account, entry *types.LedgerEntry) (types.Money, error) {
+	switch acc.Status {
+	case types.StatusDeleted, types.StatusPurged:
+		return types.Money{}, fmt.Errorf("account %d: %w", acc.ID, ErrAccountDeleted)
+	case types.StatusSuspended:
+		return types.Money{}, fmt.Errorf("account %d: %w", acc.ID, ErrAccountSuspended)
+	}
+
+	balance, err := acc.Balance.Add(entry.Amount)
+	if err != nil {
+		return types.Money{}, fmt.Errorf("account %d: %w", acc.ID, err)
+	}
+	if entry.Amount.IsNegative() && balance.IsNegative() {
+		return types.Money{}, fmt.Errorf("account %d: %w", acc.ID, ErrInsufficientFunds)
+	}
+
+	return balance, nil
+}
+
+// ledgerAccountIds returns the accounts touched by a transaction in id order,
+// which is the order rows are locked in to avoid deadlocks.
+func ledgerAccountIds(ltx *types.LedgerTransaction) []int {
+	ids := make([]int, 0, len(ltx.Entries))
+	for _, entry := range ltx.Entries {
+		if entry.AccountId != 0 {
+			ids = append(ids, entry.AccountId)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// PostLedgerTransaction writes a balanced transaction and updates the cached
+// balance of every account it touches inside one database transaction.
+func (s *PostgresStore) PostLedgerTransaction(ctx context.Context, ltx *types.LedgerTransaction) (*types.LedgerTransaction, error) {
+	if err := validateLedgerTransaction(ltx); err != nil {
+		return nil, err
+	}
+
+	err := s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		accounts := map[int]*types.Account{}
+		for _, id := range ledgerAccountIds(ltx) {
+			acc := new(types.Account)
+			err := tx.NewSelect().Model(acc).Where("id = ?", id).For("UPDATE").Scan(ctx)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("account %d not found", id)
+				}
+				return err
+			}
+			accounts[id] = acc
+		}
+
+		for _, entry := range ltx.Entries {
+			acc, ok := accounts[entry.AccountId]
+			if !ok {
+				continue
+			}
+			balance, err := applyEntry(acc, entry)
+			if err != nil {
+				return err
+			}
+			acc.Balance = balance
+		}
+
+		if _, err := tx.NewInsert().Model(ltx).Exec(ctx); err != nil {
+			return err
+		}
+
+		for _, entry := range ltx.Entries {
+			entry.TransactionId = ltx.ID
+		}
+		if _, err := tx.NewInsert().Model(&ltx.Entries).Exec(ctx); err != nil {
+			return err
+		}
+
+		for _, acc := range accounts {
+			_, err := tx.NewUpdate().
+				Model((*types.Account)(nil)).
+				Set("balance_amount = ?", acc.Balance.Amount).
+				Where("id = ?", acc.ID).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ltx, nil
+}
+
+type statementRow struct {
+	TransactionId      int            `bun:"transaction_id"`
+	Kind               string         `bun:"kind"`
+	Amount             int64          `bun:"amount"`
+	Currency           string         `bun:"currency"`
+	CounterpartyNumber sql.NullString `bun:"counterparty_number"`
+	Description        string         `bun:"description"`
+	Balance            int64          `bun:"balance"`
+	CreatedAt          time.Time      `bun:"created_at"`
+}
+
+// GetStatement returns one page of an account's ledger entries, newest first,
+// with the running balance computed over the full history.
+func (s *PostgresStore) GetStatement(ctx context.Context, accountId, page, limit int) (*types.StatementPage, error) {
+	total, err := s.Db.NewSelect().
+		Model((*types.LedgerEntry)(nil)).
+		Where("account_id = ?", accountId).
+		Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		with entries as (
+			select le.id, le.transaction_id, lt.kind, le.amount, le.currency, lt.description, le.created_at,
+				sum(le.amount) over (order by le.created_at, le.id) as balance,
+				(select a.number from ledger_entry o
+					left join account a on a.id = o.account_id
+					where o.transaction_id = le.transaction_id and o.id <> le.id
+					limit 1) as counterparty_number
+			from ledger_entry le
+			join ledger_transaction lt on lt.id = le.transaction_id
+			where le.account_id = ?
+		)
+		select * from entries
+		order by created_at desc, id desc
+		limit ? offset ?`
+
+	var rows []statementRow
+	if err := s.Db.NewRaw(query, accountId, limit, (page-1)*limit).Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	statement := &types.StatementPage{
+		Transactions: make([]*types.StatementEntry, 0, len(rows)),
+		Page:         page,
+		Limit:        limit,
+		Total:        total,
+	}
+	for _, row := range rows {
+		counterparty := types.ExternalCounterparty
+		if row.CounterpartyNumber.Valid {
+			counterparty = row.CounterpartyNumber.String
+		}
+		statement.Transactions = append(statement.Transactions, &types.StatementEntry{
+			TransactionId: row.TransactionId,
+			Type:          row.Kind,
+			Amount:        types.NewMoney(row.Amount, row.Currency),
+			Counterparty:  counterparty,
+			Description:   row.Description,
+			Balance:       types.NewMoney(row.Balance, row.Currency),
+			CreatedAt:     row.CreatedAt,
+		})
+	}
+
+	return statement, nil
+}
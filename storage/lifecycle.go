@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+// purgedEmail replaces the address of a purged account. It stays unique and
+// can never receive mail.
+func purgedEmail(id int) string {
+	return fmt.Sprintf("purged-%d@invalid", id)
+}
+
+// rememberStatus records the status of an account being suspended or
+// deleted, unless it is already suspended, in which case the status from
+// before the suspension is kept. See types.Account.PreviousStatus.
+func rememberStatus(q *bun.UpdateQuery) *bun.UpdateQuery {
+	return q.Set("previous_status = case when status in (?, ?) then status else previous_status end", types.StatusActive, types.StatusPending)
+}
+
+func (s *PostgresStore) SuspendAccount(ctx context.Context, id int) error {
+	_, err := s.Db.NewUpdate().
+		Model((*types.Account)(nil)).
+		Apply(rememberStatus).
+		Set("status = ?", types.StatusSuspended).
+		Where("id = ?", id).
+		Exec(ctx)
+
+	return err
+}
+
+// PurgeAccount anonymizes a deleted account. Names, email and password are
+// cleared and everything the account owned is removed, except the ledger,
+// which has to keep balancing.
+func (s *PostgresStore) PurgeAccount(ctx context.Context, id int) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		acc := new(types.Account)
+		err := tx.NewSelect().Model(acc).Where("id = ?", id).For("UPDATE").Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("account %d not found", id)
+		}
+		if err != nil {
+			return err
+		}
+		if acc.Status != types.StatusDeleted {
+			return ErrAccountNotDeleted
+		}
+
+		owned := []interface{}{
+			(*types.Session)(nil),
+			(*types.RefreshToken)(nil),
+			(*types.ApiKey)(nil),
+			(*types.TwoFactor)(nil),
+			(*types.RecoveryCode)(nil),
+			(*types.PasswordResetToken)(nil),
+			(*types.EmailChangeToken)(nil),
+		}
+		for _, model := range owned {
+			if _, err := tx.NewDelete().Model(model).Where("account_id = ?", id).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewDelete().Model((*types.Expense)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*types.Budget)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*types.Category)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*types.Tag)(nil)).Where("user_id = ?", id).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*types.LoginAttempt)(nil)).Where("email = ?", strings.ToLower(acc.Email)).Exec(ctx); err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*types.Account)(nil)).
+			Set("first_name = ''").
+			Set("last_name = ''").
+			Set("email = ?", purgedEmail(id)).
+			Set("password = ''").
+			Set("status = ?", types.StatusPurged).
+			Where("id = ?", id).
+			Exec(ctx)
+		return err
+	})
+}
+
+// GetAccountsDeletedBefore returns the deleted accounts whose grace period
+// started before cutoff.
+func (s *PostgresStore) GetAccountsDeletedBefore(ctx context.Context, cutoff time.Time) ([]*types.Account, error) {
+	var accounts []*types.Account
+	err := s.Db.NewSelect().
+		Model(&accounts).
+		Where("status = ?", types.StatusDeleted).
+		Where("deleted_at < ?", cutoff).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
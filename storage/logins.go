@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func (s *PostgresStore) RecordLoginAttempt(ctx context.Context, attempt *types.LoginAttempt) error {
+	_, err := s.Db.NewInsert().Model(attempt).Exec(ctx)
+	return err
+}
+
+type failureRow struct {
+	Count int          `bun:"count"`
+	Last  sql.NullTime `bun:"last"`
+}
+
+// GetLoginFailures counts failed attempts after since: for the email only
+// those after its last successful login, for the IP all of them.
+func (s *PostgresStore) GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (*types.LoginFailures, error) {
+	var byEmail, byIP failureRow
+
+	lastSuccess := s.Db.NewSelect().
+		Model((*types.LoginAttempt)(nil)).
+		ColumnExpr("max(created_at)").
+		Where("email = ?", email).
+		Where("outcome = ?", types.LoginSuccess)
+
+	err := s.Db.NewSelect().
+		Model((*types.LoginAttempt)(nil)).
+		ColumnExpr("count(*) as count, max(created_at) as last").
+		Where("email = ?", email).
+		Where("outcome = ?", types.LoginFailure).
+		Where("created_at > ?", since).
+		Where("created_at > coalesce((?), '-infinity')", lastSuccess).
+		Scan(ctx, &byEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Db.NewSelect().
+		Model((*types.LoginAttempt)(nil)).
+		ColumnExpr("count(*) as count, max(created_at) as last").
+		Where("ip = ?", ip).
+		Where("outcome = ?", types.LoginFailure).
+		Where("created_at > ?", since).
+		Scan(ctx, &byIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.LoginFailures{
+		Email:       byEmail.Count,
+		LastByEmail: byEmail.Last.Time,
+		IP:          byIP.Count,
+		LastByIP:    byIP.Last.Time,
+	}, nil
+}
+
+// CountLoginAttempts counts the attempts with the given outcome after since,
+// for the email and for the IP.
+func (s *PostgresStore) CountLoginAttempts(ctx context.Context, outcome, email, ip string, since time.Time) (*types.LoginFailures, error) {
+	var byEmail, byIP failureRow
+
+	err := s.Db.NewSelect().
+		Model((*types.LoginAttempt)(nil)).
+		ColumnExpr("count(*) as count, max(created_at) as last").
+		Where("email = ?", email).
+		Where("outcome = ?", outcome).
+		Where("created_at > ?", since).
+		Scan(ctx, &byEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Db.NewSelect().
+		Model((*types.LoginAttempt)(nil)).
+		ColumnExpr("count(*) as count, max(created_at) as last").
+		Where("ip = ?", ip).
+		Where("outcome = ?", outcome).
+		Where("created_at > ?", since).
+		Scan(ctx, &byIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.LoginFailures{
+		Email:       byEmail.Count,
+		LastByEmail: byEmail.Last.Time,
+		IP:          byIP.Count,
+		LastByIP:    byIP.Last.Time,
+	}, nil
+}
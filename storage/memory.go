@@ -0,0 +1,1570 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+// MemoryStore is a thread-safe in-memory implementation of Storage.
+// It is meant for tests and local development and mirrors the error
+// semantics of PostgresStore.
+type MemoryStore struct {
+	mu             sync.RWMutex
+	accounts       map[int]*types.Account
+	expenses       map[int]*types.Expense
+	categories     map[int]*types.Category
+	tags           map[int]*types.Tag
+	budgets        map[int]*types.Budget
+	budgetAlerts   map[BudgetAlert]bool
+	budgetLimits   map[int]map[string]types.Money
+	ledger         []*types.LedgerTransaction
+	rates          []*types.ExchangeRate
+	refreshTokens  map[string]*types.RefreshToken
+	sessions       map[string]*types.Session
+	apiKeys        map[int]*types.ApiKey
+	twoFactor      map[int]*types.TwoFactor
+	recoveryCodes  []*types.RecoveryCode
+	loginAttempts  []*types.LoginAttempt
+	resetTokens    []*types.PasswordResetToken
+	emailTokens    []*types.EmailChangeToken
+	nextAccountId  int
+	nextExpenseId  int
+	nextCategoryId int
+	nextTagId      int
+	nextBudgetId   int
+	nextLedgerId   int
+	nextEntryId    int
+	nextTokenId    int
+	nextApiKeyId   int
+	nextCodeId     int
+}
+
+var _ Storage = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		accounts:       make(map[int]*types.Account),
+		expenses:       make(map[int]*types.Expense),
+		categories:     make(map[int]*types.Category),
+		tags:           make(map[int]*types.Tag),
+		budgets:        make(map[int]*types.Budget),
+		budgetAlerts:   make(map[BudgetAlert]bool),
+		budgetLimits:   make(map[int]map[string]types.Money),
+		refreshTokens:  make(map[string]*types.RefreshToken),
+		sessions:       make(map[string]*types.Session),
+		apiKeys:        make(map[int]*types.ApiKey),
+		twoFactor:      make(map[int]*types.TwoFactor),
+		nextAccountId:  1,
+		nextExpenseId:  1,
+		nextCategoryId: 1,
+		nextTagId:      1,
+		nextBudgetId:   1,
+		nextLedgerId:   1,
+		nextEntryId:    1,
+		nextTokenId:    1,
+		nextApiKeyId:   1,
+		nextCodeId:     1,
+	}
+}
+
+func (s *MemoryStore) Init() error {
+	return nil
+}
+
+func (s *MemoryStore) CreateAccount(ctx context.Context, acc *types.Account) (*types.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if acc.ID == 0 {
+		acc.ID = s.nextAccountId
+	}
+	if _, ok := s.accounts[acc.ID]; ok {
+		return nil, fmt.Errorf("account %d already exists", acc.ID)
+	}
+	if acc.Number != "" && s.numberTaken(acc.Number) {
+		return nil, ErrAccountNumberInUse
+	}
+	for attempt := 1; acc.Number == "" || s.numberTaken(acc.Number); attempt++ {
+		if attempt > accountNumberAttempts {
+			acc.Number = ""
+			return nil, ErrAccountNumberTaken
+		}
+		number, err := types.NewAccountNumber()
+		if err != nil {
+			return nil, err
+		}
+		acc.Number = number
+	}
+	for _, other := range s.accounts {
+		if strings.EqualFold(other.Email, acc.Email) {
+			return nil, ErrEmailTaken
+		}
+	}
+	if acc.ID >= s.nextAccountId {
+		s.nextAccountId = acc.ID + 1
+	}
+
+	s.accounts[acc.ID] = copyAccount(acc)
+	return acc, nil
+}
+
+func (s *MemoryStore) numberTaken(number string) bool {
+	for _, acc := range s.accounts {
+		if acc.Number == number {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) CreateExpense(ctx context.Context, exp *types.Expense) (*types.Expense, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if exp.ID == 0 {
+		exp.ID = s.nextExpenseId
+	}
+	if _, ok := s.expenses[exp.ID]; ok {
+		return nil, fmt.Errorf("expense %d already exists", exp.ID)
+	}
+	if exp.ID >= s.nextExpenseId {
+		s.nextExpenseId = exp.ID + 1
+	}
+
+	s.ensureTags(exp.UserId, exp.Tags)
+	s.expenses[exp.ID] = copyExpense(exp)
+	if exp.Tags == nil {
+		exp.Tags = []string{}
+	}
+	return exp, nil
+}
+
+func (s *MemoryStore) UpdateAccount(ctx context.Context, acc *types.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.accounts[acc.ID]
+	if !ok {
+		return fmt.Errorf("account %d not found", acc.ID)
+	}
+	for id, other := range s.accounts {
+		if id != acc.ID && strings.EqualFold(other.Email, acc.Email) {
+			return ErrEmailTaken
+		}
+	}
+
+	stored.FirstName = acc.FirstName
+	stored.LastName = acc.LastName
+	stored.Email = acc.Email
+	return nil
+}
+
+func (s *MemoryStore) DeleteAccount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if acc, ok := s.accounts[id]; ok {
+		now := time.Now().UTC()
+		rememberAccountStatus(acc)
+		acc.Status = types.StatusDeleted
+		acc.DeletedAt = &now
+	}
+	return nil
+}
+
+func (s *MemoryStore) RestoreAccount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if acc, ok := s.accounts[id]; ok {
+		acc.Status = acc.RestoredStatus()
+		acc.PreviousStatus = ""
+		acc.DeletedAt = nil
+	}
+	return nil
+}
+
+func (s *MemoryStore) SuspendAccount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if acc, ok := s.accounts[id]; ok {
+		rememberAccountStatus(acc)
+		acc.Status = types.StatusSuspended
+	}
+	return nil
+}
+
+// rememberAccountStatus records the status of acc before it is suspended or
+// deleted, as rememberStatus does in Postgres.
+func rememberAccountStatus(acc *types.Account) {
+	if acc.Status == types.StatusActive || acc.Status == types.StatusPending {
+		acc.PreviousStatus = acc.Status
+	}
+}
+
+func (s *MemoryStore) PurgeAccount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account %d not found", id)
+	}
+	if acc.Status != types.StatusDeleted {
+		return ErrAccountNotDeleted
+	}
+
+	for sessionId, session := range s.sessions {
+		if session.AccountId == id {
+			delete(s.sessions, sessionId)
+		}
+	}
+	for hash, token := range s.refreshTokens {
+		if token.AccountId == id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for keyId, key := range s.apiKeys {
+		if key.AccountId == id {
+			delete(s.apiKeys, keyId)
+		}
+	}
+	delete(s.twoFactor, id)
+	s.deleteRecoveryCodes(id)
+
+	resetTokens := s.resetTokens[:0]
+	for _, token := range s.resetTokens {
+		if token.AccountId != id {
+			resetTokens = append(resetTokens, token)
+		}
+	}
+	s.resetTokens = resetTokens
+
+	emailTokens := s.emailTokens[:0]
+	for _, token := range s.emailTokens {
+		if token.AccountId != id {
+			emailTokens = append(emailTokens, token)
+		}
+	}
+	s.emailTokens = emailTokens
+
+	for expenseId, exp := range s.expenses {
+		if exp.UserId == id {
+			delete(s.expenses, expenseId)
+		}
+	}
+	for budgetId, budget := range s.budgets {
+		if budget.UserId == id {
+			s.deleteBudget(budgetId)
+		}
+	}
+	for categoryId, category := range s.categories {
+		if category.UserId == id {
+			delete(s.categories, categoryId)
+		}
+	}
+	for tagId, tag := range s.tags {
+		if tag.UserId == id {
+			delete(s.tags, tagId)
+		}
+	}
+
+	email := strings.ToLower(acc.Email)
+	attempts := s.loginAttempts[:0]
+	for _, attempt := range s.loginAttempts {
+		if attempt.Email != email {
+			attempts = append(attempts, attempt)
+		}
+	}
+	s.loginAttempts = attempts
+
+	acc.FirstName = ""
+	acc.LastName = ""
+	acc.Email = purgedEmail(id)
+	acc.Password = ""
+	acc.Status = types.StatusPurged
+	return nil
+}
+
+func (s *MemoryStore) GetAccountsDeletedBefore(ctx context.Context, cutoff time.Time) ([]*types.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var accounts []*types.Account
+	for _, id := range sortedKeys(s.accounts) {
+		acc := s.accounts[id]
+		if acc.Status == types.StatusDeleted && acc.DeletedAt != nil && acc.DeletedAt.Before(cutoff) {
+			accounts = append(accounts, copyAccount(acc))
+		}
+	}
+	return accounts, nil
+}
+
+func (s *MemoryStore) ActivateAccount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[id]
+	if !ok || acc.Status != types.StatusPending {
+		return ErrAccountNotPending
+	}
+
+	acc.Status = types.StatusActive
+	return nil
+}
+
+func (s *MemoryStore) SetAccountRole(ctx context.Context, id int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account %d not found", id)
+	}
+
+	acc.Role = role
+	return nil
+}
+
+func (s *MemoryStore) SetAccountPassword(ctx context.Context, id int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[id]
+	if !ok {
+		return fmt.Errorf("account %d not found", id)
+	}
+
+	acc.Password = passwordHash
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpense(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.expenses, id)
+	return nil
+}
+
+func (s *MemoryStore) UpdateExpense(ctx context.Context, id int, newExp *types.Expense) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	newExp.ID = id
+	if _, ok := s.expenses[id]; !ok {
+		return fmt.Errorf("no rows affected")
+	}
+
+	s.ensureTags(newExp.UserId, newExp.Tags)
+	s.expenses[id] = copyExpense(newExp)
+	return nil
+}
+
+func (s *MemoryStore) GetAccountById(ctx context.Context, id int) (*types.Account, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("account %d not found", id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acc, ok := s.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("account %d not found", id)
+	}
+
+	return copyAccount(acc), nil
+}
+
+func (s *MemoryStore) GetExpenseById(ctx context.Context, id int) (*types.Expense, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("expense %d not found", id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	exp, ok := s.expenses[id]
+	if !ok {
+		return nil, fmt.Errorf("expense %d not found", id)
+	}
+
+	return copyExpense(exp), nil
+}
+
+func (s *MemoryStore) GetExpenseForUser(ctx context.Context, userId int, q *types.ExpenseQuery) (*types.ExpensePage, error) {
+	return s.queryExpenses(q, func(exp *types.Expense) bool {
+		return exp.UserId == userId
+	}), nil
+}
+
+func (s *MemoryStore) GetAllExpense(ctx context.Context, q *types.ExpenseQuery) (*types.ExpensePage, error) {
+	return s.queryExpenses(q, nil), nil
+}
+
+func (s *MemoryStore) CreateCategory(ctx context.Context, category *types.Category) (*types.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.categoryNameTaken(category.UserId, category.ParentId, category.Name, 0) {
+		return nil, ErrCategoryExists
+	}
+
+	category.ID = s.nextCategoryId
+	s.nextCategoryId++
+	s.categories[category.ID] = copyCategory(category)
+
+	return category, nil
+}
+
+func (s *MemoryStore) UpdateCategory(ctx context.Context, category *types.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.categories[category.ID]
+	if !ok {
+		return fmt.Errorf("category %d not found", category.ID)
+	}
+	if s.categoryNameTaken(existing.UserId, category.ParentId, category.Name, category.ID) {
+		return ErrCategoryExists
+	}
+
+	updated := copyCategory(existing)
+	updated.ParentId = category.ParentId
+	updated.Name = category.Name
+	updated.Color = category.Color
+	updated.Icon = category.Icon
+	updated.UpdatedAt = category.UpdatedAt
+	s.categories[category.ID] = copyCategory(updated)
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteCategory(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, ok := s.categories[id]
+	if !ok {
+		return fmt.Errorf("category %d not found", id)
+	}
+
+	var children []*types.Category
+	for _, child := range s.categories {
+		if child.ParentId != nil && *child.ParentId == id {
+			if s.categoryNameTaken(child.UserId, category.ParentId, child.Name, child.ID) {
+				return ErrCategoryExists
+			}
+			children = append(children, child)
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, child := range children {
+		child.ParentId = copyIntPtr(category.ParentId)
+		child.UpdatedAt = now
+	}
+	for _, exp := range s.expenses {
+		if exp.CategoryId != nil && *exp.CategoryId == id {
+			exp.CategoryId = copyIntPtr(category.ParentId)
+		}
+	}
+	for budgetId, budget := range s.budgets {
+		if budget.CategoryId == id {
+			s.deleteBudget(budgetId)
+		}
+	}
+	delete(s.categories, id)
+
+	return nil
+}
+
+func (s *MemoryStore) GetCategoryById(ctx context.Context, id int) (*types.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	category, ok := s.categories[id]
+	if !ok {
+		return nil, fmt.Errorf("category %d not found", id)
+	}
+
+	return copyCategory(category), nil
+}
+
+func (s *MemoryStore) GetCategoriesForUser(ctx context.Context, userId int) ([]*types.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := []*types.Category{}
+	for _, id := range sortedKeys(s.categories) {
+		if category := s.categories[id]; category.UserId == userId {
+			categories = append(categories, copyCategory(category))
+		}
+	}
+
+	return categories, nil
+}
+
+// categoryNameTaken mirrors category_name_key: names are unique among the
+// siblings under parentId, ignoring case. The caller holds the lock.
+func (s *MemoryStore) categoryNameTaken(userId int, parentId *int, name string, exceptId int) bool {
+	for id, category := range s.categories {
+		if id != exceptId && category.UserId == userId &&
+			equalIntPtr(category.ParentId, parentId) && strings.EqualFold(category.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) GetTagsForUser(ctx context.Context, userId int) ([]*types.TagUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, exp := range s.expenses {
+		if exp.UserId == userId {
+			for _, tag := range exp.Tags {
+				counts[tag]++
+			}
+		}
+	}
+
+	tags := []*types.TagUsage{}
+	for _, tag := range s.tags {
+		if tag.UserId == userId {
+			tags = append(tags, &types.TagUsage{Name: tag.Name, Count: counts[tag.Name]})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (s *MemoryStore) RenameTag(ctx context.Context, userId int, from, to string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag := s.findTag(userId, from)
+	if tag == nil {
+		return ErrTagNotFound
+	}
+	if from == to {
+		return nil
+	}
+	if s.findTag(userId, to) != nil {
+		return ErrTagExists
+	}
+
+	tag.Name = to
+	s.retagExpenses(userId, []string{from}, to)
+	return nil
+}
+
+func (s *MemoryStore) MergeTags(ctx context.Context, userId int, from []string, into string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources := withoutTag(from, into)
+	for _, name := range sources {
+		if s.findTag(userId, name) == nil {
+			return ErrTagNotFound
+		}
+	}
+
+	s.ensureTags(userId, []string{into})
+	for _, name := range sources {
+		delete(s.tags, s.findTag(userId, name).ID)
+	}
+	s.retagExpenses(userId, sources, into)
+	return nil
+}
+
+// ensureTags creates the named tags the user does not have yet. The caller
+// holds the lock.
+func (s *MemoryStore) ensureTags(userId int, names []string) {
+	for _, name := range names {
+		if s.findTag(userId, name) == nil {
+			s.tags[s.nextTagId] = &types.Tag{ID: s.nextTagId, UserId: userId, Name: name, CreatedAt: time.Now().UTC()}
+			s.nextTagId++
+		}
+	}
+}
+
+func (s *MemoryStore) findTag(userId int, name string) *types.Tag {
+	for _, tag := range s.tags {
+		if tag.UserId == userId && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// retagExpenses replaces the tags in from with to on the user's expenses.
+// The caller holds the lock.
+func (s *MemoryStore) retagExpenses(userId int, from []string, to string) {
+	for _, exp := range s.expenses {
+		if exp.UserId != userId {
+			continue
+		}
+
+		tags := []string{}
+		for _, tag := range exp.Tags {
+			if slices.Contains(from, tag) {
+				tag = to
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		exp.Tags = tags
+	}
+}
+
+func (s *MemoryStore) CreateBudget(ctx context.Context, budget *types.Budget) (*types.Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.budgets {
+		if existing.UserId == budget.UserId && existing.CategoryId == budget.CategoryId {
+			return nil, ErrBudgetExists
+		}
+	}
+
+	budget.ID = s.nextBudgetId
+	s.nextBudgetId++
+	c := *budget
+	s.budgets[budget.ID] = &c
+	s.budgetLimits[budget.ID] = map[string]types.Money{}
+	s.saveBudgetLimit(types.NewBudgetLimit(budget, budget.CreatedAt))
+
+	return budget, nil
+}
+
+func (s *MemoryStore) UpdateBudget(ctx context.Context, budget *types.Budget) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.budgets[budget.ID]
+	if !ok {
+		return fmt.Errorf("budget %d not found", budget.ID)
+	}
+
+	existing.Limit = budget.Limit
+	existing.Rollover = budget.Rollover
+	existing.UpdatedAt = budget.UpdatedAt
+	s.saveBudgetLimit(types.NewBudgetLimit(budget, budget.UpdatedAt))
+	return nil
+}
+
+// saveBudgetLimit records a limit, replacing one from the same month. The
+// caller holds the lock.
+func (s *MemoryStore) saveBudgetLimit(limit *types.BudgetLimit) {
+	s.budgetLimits[limit.BudgetId][limit.Month] = limit.Limit
+}
+
+func (s *MemoryStore) DeleteBudget(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteBudget(id)
+	return nil
+}
+
+// deleteBudget removes a budget, its limits and its alerts. The caller holds the lock.
+func (s *MemoryStore) deleteBudget(id int) {
+	delete(s.budgets, id)
+	delete(s.budgetLimits, id)
+	for alert := range s.budgetAlerts {
+		if alert.BudgetId == id {
+			delete(s.budgetAlerts, alert)
+		}
+	}
+}
+
+func (s *MemoryStore) GetBudgetById(ctx context.Context, id int) (*types.Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	budget, ok := s.budgets[id]
+	if !ok {
+		return nil, fmt.Errorf("budget %d not found", id)
+	}
+
+	c := *budget
+	return &c, nil
+}
+
+func (s *MemoryStore) GetBudgetsForUser(ctx context.Context, userId int) ([]*types.Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	budgets := []*types.Budget{}
+	for _, id := range sortedKeys(s.budgets) {
+		if budget := s.budgets[id]; budget.UserId == userId {
+			c := *budget
+			budgets = append(budgets, &c)
+		}
+	}
+
+	return budgets, nil
+}
+
+func (s *MemoryStore) GetBudgetLimits(ctx context.Context, budgetId int) ([]*types.BudgetLimit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	limits := []*types.BudgetLimit{}
+	for month, limit := range s.budgetLimits[budgetId] {
+		limits = append(limits, &types.BudgetLimit{BudgetId: budgetId, Month: month, Limit: limit})
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Month < limits[j].Month })
+
+	return limits, nil
+}
+
+func (s *MemoryStore) RecordBudgetAlert(ctx context.Context, budgetId int, month string, threshold int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert := BudgetAlert{BudgetId: budgetId, Month: month, Threshold: threshold}
+	if s.budgetAlerts[alert] {
+		return false, nil
+	}
+	s.budgetAlerts[alert] = true
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteBudgetAlert(ctx context.Context, budgetId int, month string, threshold int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.budgetAlerts, BudgetAlert{BudgetId: budgetId, Month: month, Threshold: threshold})
+	return nil
+}
+
+func (s *MemoryStore) SearchExpenses(ctx context.Context, userId int, text string, limit int) ([]*types.ExpenseSearchResult, error) {
+	terms := types.SearchTerms(text)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := []*types.ExpenseSearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+	for _, id := range sortedKeys(s.expenses) {
+		if exp := s.expenses[id]; exp.UserId == userId {
+			if result, ok := searchExpense(exp, terms); ok {
+				results = append(results, result)
+			}
+		}
+	}
+
+	sortSearchResults(results)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) queryExpenses(q *types.ExpenseQuery, scope func(*types.Expense) bool) *types.ExpensePage {
+	q = expenseQueryOrDefault(q)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expenses := []*types.Expense{}
+	for _, id := range sortedKeys(s.expenses) {
+		if exp := s.expenses[id]; (scope == nil || scope(exp)) && matchesExpenseQuery(q, exp) {
+			expenses = append(expenses, copyExpense(exp))
+		}
+	}
+	sort.SliceStable(expenses, func(i, j int) bool {
+		return expenseSortsBefore(q, expenses[i], expenses[j])
+	})
+
+	return newExpensePage(expenses, q)
+}
+
+// matchesExpenseQuery applies the filters of q, and its cursor, to exp.
+func matchesExpenseQuery(q *types.ExpenseQuery, exp *types.Expense) bool {
+	switch {
+	case q.From != nil && exp.CreatedAt.Before(*q.From),
+		q.Before != nil && !exp.CreatedAt.Before(*q.Before),
+		q.CategoryIds != nil && !inCategories(exp, q.CategoryIds),
+		len(q.Tags) > 0 && !hasTags(exp, q.Tags, q.AllTags),
+		q.Purpose != "" && exp.ExpensePurpose != q.Purpose,
+		q.Currency != "" && exp.ExpenseValue.Currency != q.Currency,
+		q.MinValue != nil && (exp.ExpenseValue.Currency != q.MinValue.Currency || exp.ExpenseValue.Amount < q.MinValue.Amount),
+		q.MaxValue != nil && (exp.ExpenseValue.Currency != q.MaxValue.Currency || exp.ExpenseValue.Amount > q.MaxValue.Amount):
+		return false
+	}
+
+	if q.Cursor != nil {
+		last := &types.Expense{ID: q.Cursor.ID, CreatedAt: q.Cursor.CreatedAt, ExpenseValue: types.Money{Amount: q.Cursor.Value}}
+		return expenseSortsBefore(q, last, exp)
+	}
+	return true
+}
+
+func hasTags(exp *types.Expense, tags []string, all bool) bool {
+	matched := 0
+	for _, tag := range tags {
+		if slices.Contains(exp.Tags, tag) {
+			matched++
+		}
+	}
+	if all {
+		return matched == len(tags)
+	}
+	return matched > 0
+}
+
+func inCategories(exp *types.Expense, ids []int) bool {
+	for _, id := range ids {
+		if exp.CategoryId != nil && *exp.CategoryId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// expenseSortsBefore orders a and b by the sort key of q, then by id.
+func expenseSortsBefore(q *types.ExpenseQuery, a, b *types.Expense) bool {
+	field, descending := q.SortBy()
+
+	cmp := a.CreatedAt.Compare(b.CreatedAt)
+	if field == types.ExpenseSortValue {
+		cmp = compareInts(a.ExpenseValue.Amount, b.ExpenseValue.Amount)
+	}
+	if cmp == 0 {
+		cmp = compareInts(int64(a.ID), int64(b.ID))
+	}
+	if descending {
+		cmp = -cmp
+	}
+
+	return cmp < 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (s *MemoryStore) GetAccountByEmail(ctx context.Context, email string) (*types.Account, error) {
+	if email == "" {
+		return nil, fmt.Errorf("account %v not found", email)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range sortedKeys(s.accounts) {
+		if acc := s.accounts[id]; strings.EqualFold(acc.Email, email) {
+			return copyAccount(acc), nil
+		}
+	}
+
+	return nil, fmt.Errorf("account for %v not found", email)
+}
+
+func (s *MemoryStore) GetAccountByNumber(ctx context.Context, number string) (*types.Account, error) {
+	number, err := types.ParseAccountNumber(number)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range sortedKeys(s.accounts) {
+		if acc := s.accounts[id]; acc.Number == number {
+			return copyAccount(acc), nil
+		}
+	}
+
+	return nil, fmt.Errorf("account number %s not found", number)
+}
+
+func (s *MemoryStore) GetAccounts(ctx context.Context) ([]*types.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := []*types.Account{}
+	for _, id := range sortedKeys(s.accounts) {
+		accounts = append(accounts, copyAccount(s.accounts[id]))
+	}
+
+	return accounts, nil
+}
+
+func (s *MemoryStore) PostLedgerTransaction(ctx context.Context, ltx *types.LedgerTransaction) (*types.LedgerTransaction, error) {
+	if err := validateLedgerTransaction(ltx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balances := map[int]types.Money{}
+	for _, entry := range ltx.Entries {
+		if entry.AccountId == 0 {
+			continue
+		}
+		acc, ok := s.accounts[entry.AccountId]
+		if !ok {
+			return nil, fmt.Errorf("account %d not found", entry.AccountId)
+		}
+		balance, err := applyEntry(acc, entry)
+		if err != nil {
+			return nil, err
+		}
+		balances[acc.ID] = balance
+	}
+
+	ltx.ID = s.nextLedgerId
+	s.nextLedgerId++
+	for _, entry := range ltx.Entries {
+		entry.ID = s.nextEntryId
+		entry.TransactionId = ltx.ID
+		s.nextEntryId++
+	}
+	for id, balance := range balances {
+		s.accounts[id].Balance = balance
+	}
+
+	s.ledger = append(s.ledger, copyLedgerTransaction(ltx))
+	return ltx, nil
+}
+
+func (s *MemoryStore) GetStatement(ctx context.Context, accountId, page, limit int) (*types.StatementPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// s.ledger is in posting order, so walking it yields the running balance.
+	var entries []*types.StatementEntry
+	var balance types.Money
+	for _, ltx := range s.ledger {
+		for _, entry := range ltx.Entries {
+			if entry.AccountId != accountId {
+				continue
+			}
+			if len(entries) == 0 {
+				balance.Currency = entry.Amount.Currency
+			}
+
+			var err error
+			if balance, err = balance.Add(entry.Amount); err != nil {
+				return nil, err
+			}
+			entries = append(entries, &types.StatementEntry{
+				TransactionId: ltx.ID,
+				Type:          ltx.Kind,
+				Amount:        entry.Amount,
+				Counterparty:  s.counterparty(ltx, entry),
+				Description:   ltx.Description,
+				Balance:       balance,
+				CreatedAt:     entry.CreatedAt,
+			})
+		}
+	}
+
+	statement := &types.StatementPage{
+		Transactions: []*types.StatementEntry{},
+		Page:         page,
+		Limit:        limit,
+		Total:        len(entries),
+	}
+	for i := len(entries) - 1 - (page-1)*limit; i >= 0 && len(statement.Transactions) < limit; i-- {
+		statement.Transactions = append(statement.Transactions, entries[i])
+	}
+
+	return statement, nil
+}
+
+func (s *MemoryStore) counterparty(ltx *types.LedgerTransaction, entry *types.LedgerEntry) string {
+	for _, other := range ltx.Entries {
+		if other.ID == entry.ID {
+			continue
+		}
+		if acc, ok := s.accounts[other.AccountId]; ok {
+			return acc.Number
+		}
+		return types.ExternalCounterparty
+	}
+	return types.ExternalCounterparty
+}
+
+func (s *MemoryStore) SaveExchangeRates(ctx context.Context, rates []*types.ExchangeRate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rate := range rates {
+		c := *rate
+		c.ValidFrom = truncateDay(rate.ValidFrom)
+
+		replaced := false
+		for i, existing := range s.rates {
+			if existing.Base == c.Base && existing.Quote == c.Quote && existing.ValidFrom.Equal(c.ValidFrom) {
+				s.rates[i] = &c
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.rates = append(s.rates, &c)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) GetExchangeRate(ctx context.Context, base, quote string, on time.Time) (*types.ExchangeRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	day := truncateDay(on)
+	var found *types.ExchangeRate
+	for _, rate := range s.rates {
+		if rate.Base != base || rate.Quote != quote || rate.ValidFrom.After(day) {
+			continue
+		}
+		if found == nil || rate.ValidFrom.After(found.ValidFrom) {
+			found = rate
+		}
+	}
+
+	if found == nil {
+		return nil, rateNotFound(base, quote, on)
+	}
+
+	c := *found
+	return &c, nil
+}
+
+func (s *MemoryStore) CreateRefreshToken(ctx context.Context, token *types.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertRefreshToken(token)
+	return nil
+}
+
+func (s *MemoryStore) insertRefreshToken(token *types.RefreshToken) {
+	token.ID = s.nextTokenId
+	s.nextTokenId++
+
+	c := *token
+	s.refreshTokens[token.TokenHash] = &c
+}
+
+func (s *MemoryStore) GetRefreshToken(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	c := *token
+	return &c, nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, hash string, next *types.RefreshToken) (*types.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	now := time.Now().UTC()
+	if err := checkRotation(current, now); err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			s.revokeFamily(current.FamilyId, now)
+		}
+		return nil, err
+	}
+
+	current.UsedAt = &now
+	next.AccountId = current.AccountId
+	next.FamilyId = current.FamilyId
+	s.insertRefreshToken(next)
+
+	return next, nil
+}
+
+func (s *MemoryStore) RevokeRefreshFamily(ctx context.Context, familyId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeFamily(familyId, time.Now().UTC())
+	return nil
+}
+
+func (s *MemoryStore) revokeFamily(familyId string, now time.Time) {
+	for _, token := range s.refreshTokens {
+		if token.FamilyId == familyId && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+
+	if session, ok := s.sessions[familyId]; ok && session.RevokedAt == nil {
+		revokedAt := now
+		session.RevokedAt = &revokedAt
+	}
+}
+
+func (s *MemoryStore) CreateSession(ctx context.Context, session *types.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.ID]; ok {
+		return fmt.Errorf("session %s already exists", session.ID)
+	}
+
+	c := *session
+	s.sessions[session.ID] = &c
+	return nil
+}
+
+func (s *MemoryStore) GetSession(ctx context.Context, id string) (*types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("session %s not found", id)
+	}
+
+	c := *session
+	return &c, nil
+}
+
+func (s *MemoryStore) GetSessionsForAccount(ctx context.Context, accountId int) ([]*types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sessions := []*types.Session{}
+	for _, session := range s.sessions {
+		if session.AccountId == accountId && session.RevokedAt == nil {
+			c := *session
+			sessions = append(sessions, &c)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+func (s *MemoryStore) TouchSession(ctx context.Context, id string, seenAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.LastSeenAt = seenAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeFamily(id, time.Now().UTC())
+	return nil
+}
+
+func (s *MemoryStore) RevokeAccountSessions(ctx context.Context, accountId int, exceptId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, session := range s.sessions {
+		if session.AccountId == accountId && id != exceptId {
+			s.revokeFamily(id, now)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateApiKey(ctx context.Context, key *types.ApiKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.KeyHash == key.KeyHash {
+			return fmt.Errorf("api key already exists")
+		}
+	}
+
+	key.ID = s.nextApiKeyId
+	s.nextApiKeyId++
+	s.apiKeys[key.ID] = copyApiKey(key)
+	return nil
+}
+
+func (s *MemoryStore) GetApiKeyByHash(ctx context.Context, hash string) (*types.ApiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.KeyHash == hash {
+			return copyApiKey(key), nil
+		}
+	}
+	return nil, fmt.Errorf("api key not found")
+}
+
+func (s *MemoryStore) GetApiKeysForAccount(ctx context.Context, accountId int) ([]*types.ApiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []*types.ApiKey{}
+	for _, key := range s.apiKeys {
+		if key.AccountId == accountId && key.RevokedAt == nil {
+			keys = append(keys, copyApiKey(key))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+
+	return keys, nil
+}
+
+func (s *MemoryStore) TouchApiKey(ctx context.Context, id int, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = &usedAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeApiKey(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok && key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+	}
+	return nil
+}
+
+func copyApiKey(key *types.ApiKey) *types.ApiKey {
+	c := *key
+	c.Scopes = append([]string(nil), key.Scopes...)
+	return &c
+}
+
+func (s *MemoryStore) SaveTwoFactor(ctx context.Context, tf *types.TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *tf
+	s.twoFactor[tf.AccountId] = &c
+	return nil
+}
+
+func (s *MemoryStore) GetTwoFactor(ctx context.Context, accountId int) (*types.TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tf, ok := s.twoFactor[accountId]
+	if !ok {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	c := *tf
+	return &c, nil
+}
+
+func (s *MemoryStore) UseTotpStep(ctx context.Context, accountId int, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, ok := s.twoFactor[accountId]
+	if !ok || tf.LastUsedStep >= step {
+		return ErrTotpCodeReused
+	}
+
+	tf.LastUsedStep = step
+	return nil
+}
+
+func (s *MemoryStore) DeleteTwoFactor(ctx context.Context, accountId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.twoFactor, accountId)
+	s.deleteRecoveryCodes(accountId)
+	return nil
+}
+
+func (s *MemoryStore) ReplaceRecoveryCodes(ctx context.Context, accountId int, codes []*types.RecoveryCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteRecoveryCodes(accountId)
+	for _, code := range codes {
+		code.ID = s.nextCodeId
+		s.nextCodeId++
+
+		c := *code
+		s.recoveryCodes = append(s.recoveryCodes, &c)
+	}
+	return nil
+}
+
+func (s *MemoryStore) deleteRecoveryCodes(accountId int) {
+	kept := s.recoveryCodes[:0]
+	for _, code := range s.recoveryCodes {
+		if code.AccountId != accountId {
+			kept = append(kept, code)
+		}
+	}
+	s.recoveryCodes = kept
+}
+
+func (s *MemoryStore) UseRecoveryCode(ctx context.Context, accountId int, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes {
+		if code.AccountId == accountId && code.CodeHash == hash && code.UsedAt == nil {
+			now := time.Now().UTC()
+			code.UsedAt = &now
+			return nil
+		}
+	}
+	return ErrRecoveryCodeInvalid
+}
+
+func (s *MemoryStore) RecordLoginAttempt(ctx context.Context, attempt *types.LoginAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt.ID = len(s.loginAttempts) + 1
+	c := *attempt
+	s.loginAttempts = append(s.loginAttempts, &c)
+	return nil
+}
+
+func (s *MemoryStore) GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (*types.LoginFailures, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	emailSince := since
+	for _, attempt := range s.loginAttempts {
+		if attempt.Email == email && attempt.Outcome == types.LoginSuccess && attempt.CreatedAt.After(emailSince) {
+			emailSince = attempt.CreatedAt
+		}
+	}
+
+	failures := &types.LoginFailures{}
+	for _, attempt := range s.loginAttempts {
+		if attempt.Outcome != types.LoginFailure {
+			continue
+		}
+		if attempt.Email == email && attempt.CreatedAt.After(emailSince) {
+			failures.Email++
+			if attempt.CreatedAt.After(failures.LastByEmail) {
+				failures.LastByEmail = attempt.CreatedAt
+			}
+		}
+		if attempt.IP == ip && attempt.CreatedAt.After(since) {
+			failures.IP++
+			if attempt.CreatedAt.After(failures.LastByIP) {
+				failures.LastByIP = attempt.CreatedAt
+			}
+		}
+	}
+
+	return failures, nil
+}
+
+func (s *MemoryStore) CountLoginAttempts(ctx context.Context, outcome, email, ip string, since time.Time) (*types.LoginFailures, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := &types.LoginFailures{}
+	for _, attempt := range s.loginAttempts {
+		if attempt.Outcome != outcome || !attempt.CreatedAt.After(since) {
+			continue
+		}
+		if attempt.Email == email {
+			counts.Email++
+			if attempt.CreatedAt.After(counts.LastByEmail) {
+				counts.LastByEmail = attempt.CreatedAt
+			}
+		}
+		if attempt.IP == ip {
+			counts.IP++
+			if attempt.CreatedAt.After(counts.LastByIP) {
+				counts.LastByIP = attempt.CreatedAt
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+func (s *MemoryStore) CreatePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ID = len(s.resetTokens) + 1
+	c := *token
+	s.resetTokens = append(s.resetTokens, &c)
+	return nil
+}
+
+func (s *MemoryStore) ConsumePasswordResetToken(ctx context.Context, hash string, now time.Time) (*types.PasswordResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.resetTokens {
+		if token.TokenHash == hash && token.UsedAt == nil && now.Before(token.ExpiresAt) {
+			usedAt := now
+			token.UsedAt = &usedAt
+
+			c := *token
+			return &c, nil
+		}
+	}
+	return nil, ErrResetTokenInvalid
+}
+
+func (s *MemoryStore) CreateEmailChangeToken(ctx context.Context, token *types.EmailChangeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.emailTokens {
+		if other.AccountId == token.AccountId && other.UsedAt == nil {
+			usedAt := token.CreatedAt
+			other.UsedAt = &usedAt
+		}
+	}
+
+	token.ID = len(s.emailTokens) + 1
+	c := *token
+	s.emailTokens = append(s.emailTokens, &c)
+	return nil
+}
+
+func (s *MemoryStore) ConsumeEmailChangeToken(ctx context.Context, accountId int, hash string, now time.Time) (*types.EmailChangeToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.emailTokens {
+		if token.AccountId == accountId && token.TokenHash == hash && token.UsedAt == nil && now.Before(token.ExpiresAt) {
+			usedAt := now
+			token.UsedAt = &usedAt
+
+			c := *token
+			return &c, nil
+		}
+	}
+	return nil, ErrEmailChangeTokenInvalid
+}
+
+func (s *MemoryStore) RevokePasswordResetTokens(ctx context.Context, accountId int, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.resetTokens {
+		if token.AccountId == accountId && token.UsedAt == nil {
+			usedAt := now
+			token.UsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+// truncateDay matches the date column type used by PostgresStore.
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func copyLedgerTransaction(ltx *types.LedgerTransaction) *types.LedgerTransaction {
+	c := *ltx
+	c.Entries = make([]*types.LedgerEntry, len(ltx.Entries))
+	for i, entry := range ltx.Entries {
+		e := *entry
+		c.Entries[i] = &e
+	}
+	return &c
+}
+
+func copyAccount(acc *types.Account) *types.Account {
+	c := *acc
+	if acc.DeletedAt != nil {
+		deletedAt := *acc.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
+}
+
+func copyExpense(exp *types.Expense) *types.Expense {
+	c := *exp
+	c.Account = nil
+	c.CategoryId = copyIntPtr(exp.CategoryId)
+	c.Tags = append([]string{}, exp.Tags...)
+	return &c
+}
+
+func copyCategory(category *types.Category) *types.Category {
+	c := *category
+	c.ParentId = copyIntPtr(category.ParentId)
+	return &c
+}
+
+func copyIntPtr(p *int) *int {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+func equalIntPtr(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func sortedKeys[T any](m map[int]T) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockId is the advisory lock key taken while migrations run, so
+// that two instances starting together do not apply the same step twice.
+const migrationLockId = 72697466
+
+var migrationName = regexp.MustCompile(`^(\d{4})_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type SchemaMigration struct {
+	bun.BaseModel `bun:"table:schema_migrations,alias:sm"`
+	Version       int       `bun:"version,pk"`
+	Name          string    `bun:"name"`
+	AppliedAt     time.Time `bun:"applied_at"`
+}
+
+// Migrations returns the embedded migrations ordered by version. Every
+// version must have both an up and a down script.
+func Migrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		body, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// LatestVersion is the highest version among the embedded migrations.
+func LatestVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+func (s *PostgresStore) createMigrationsTable(ctx context.Context) error {
+	_, err := s.Db.NewCreateTable().Model((*SchemaMigration)(nil)).IfNotExists().Exec(ctx)
+	return err
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an
+// empty database.
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (int, error) {
+	if err := s.createMigrationsTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err := s.Db.NewSelect().Model((*SchemaMigration)(nil)).ColumnExpr("max(version)").Scan(ctx, &version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// MigrateUp applies every pending migration.
+func (s *PostgresStore) MigrateUp(ctx context.Context) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	return s.MigrateTo(ctx, latest)
+}
+
+// Rollback reverts the given number of most recently applied migrations.
+func (s *PostgresStore) Rollback(ctx context.Context, steps int) error {
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	target := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if migrations[i].Version > current {
+			continue
+		}
+		if steps == 0 {
+			target = migrations[i].Version
+			break
+		}
+		steps--
+	}
+
+	return s.MigrateTo(ctx, target)
+}
+
+// MigrateTo applies or reverts migrations until the schema is at the given
+// version. Each step runs in its own transaction together with its
+// schema_migrations bookkeeping.
+func (s *PostgresStore) MigrateTo(ctx context.Context, target int) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	known := target == 0
+	for _, m := range migrations {
+		if m.Version == target {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown migration version %d", target)
+	}
+
+	if err := s.createMigrationsTable(ctx); err != nil {
+		return err
+	}
+
+	applied := map[int]bool{}
+	var rows []SchemaMigration
+	if err := s.Db.NewSelect().Model(&rows).Scan(ctx); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		applied[row.Version] = true
+	}
+
+	for _, m := range migrations {
+		if m.Version > target || applied[m.Version] {
+			continue
+		}
+		if err := s.applyMigration(ctx, m, true); err != nil {
+			return err
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= target || !applied[m.Version] {
+			continue
+		}
+		if err := s.applyMigration(ctx, m, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) applyMigration(ctx context.Context, m *Migration, up bool) error {
+	direction, script := "up", m.Up
+	if !up {
+		direction, script = "down", m.Down
+	}
+	fmt.Printf("Migrating %s %04d_%s\n", direction, m.Version, m.Name)
+
+	err := s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock(?)", migrationLockId); err != nil {
+			return err
+		}
+
+		// Another instance may have run this step while we waited for the lock.
+		exists, err := tx.NewSelect().Model((*SchemaMigration)(nil)).Where("version = ?", m.Version).Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if exists == up {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+
+		if up {
+			_, err = tx.NewInsert().Model(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Exec(ctx)
+		} else {
+			_, err = tx.NewDelete().Model((*SchemaMigration)(nil)).Where("version = ?", m.Version).Exec(ctx)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migration %04d_%s %s failed: %v", m.Version, m.Name, direction, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,2 @@
+drop table if exists expense;
+drop table if exists account;
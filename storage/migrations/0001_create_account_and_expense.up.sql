@@ -0,0 +1,23 @@
+create table if not exists account (
+	id serial primary key,
+	first_name varchar(50),
+	last_name varchar(50),
+	email varchar(50),
+	password varchar(200),
+	status varchar(50),
+	number serial,
+	balance int,
+	created_at timestamp
+);
+
+create table if not exists expense (
+	id serial primary key,
+	user_id int,
+	expense_name varchar(50),
+	expense_purpose varchar(50),
+	expense_category varchar(50),
+	expense_value float,
+	created_at timestamp,
+	updated_at timestamp,
+	FOREIGN KEY (user_id) REFERENCES account(id)
+);
@@ -0,0 +1,4 @@
+alter table account alter column balance type int;
+
+drop table if exists ledger_entry;
+drop table if exists ledger_transaction;
@@ -0,0 +1,19 @@
+create table if not exists ledger_transaction (
+	id serial primary key,
+	kind varchar(20) not null,
+	description varchar(200),
+	created_at timestamp not null
+);
+
+create table if not exists ledger_entry (
+	id serial primary key,
+	transaction_id int not null references ledger_transaction(id),
+	account_id int not null references account(id),
+	amount bigint not null,
+	created_at timestamp not null
+);
+
+create index if not exists ledger_entry_account_id_idx on ledger_entry (account_id, created_at);
+
+alter table account alter column balance type bigint;
+update account set balance = 0 where balance is null;
@@ -0,0 +1 @@
+alter table ledger_entry alter column account_id drop not null;
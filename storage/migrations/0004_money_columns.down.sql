@@ -0,0 +1,11 @@
+alter table ledger_entry drop column currency;
+
+alter table expense add column expense_value float;
+update expense set expense_value = expense_value_amount / 100.0;
+alter table expense drop column expense_value_currency;
+alter table expense drop column expense_value_amount;
+
+alter table account add column balance bigint;
+update account set balance = balance_amount;
+alter table account drop column balance_currency;
+alter table account drop column balance_amount;
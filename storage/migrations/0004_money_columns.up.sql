@@ -0,0 +1,11 @@
+alter table account add column balance_amount bigint not null default 0;
+alter table account add column balance_currency char(3) not null default 'EUR';
+update account set balance_amount = coalesce(balance, 0);
+alter table account drop column balance;
+
+alter table expense add column expense_value_amount bigint not null default 0;
+alter table expense add column expense_value_currency char(3) not null default 'EUR';
+update expense set expense_value_amount = round(coalesce(expense_value, 0)::numeric * 100);
+alter table expense drop column expense_value;
+
+alter table ledger_entry add column currency char(3) not null default 'EUR';
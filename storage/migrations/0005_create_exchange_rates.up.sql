@@ -0,0 +1,7 @@
+create table if not exists exchange_rates (
+	base char(3) not null,
+	quote char(3) not null,
+	valid_from date not null,
+	rate numeric(24, 12) not null check (rate > 0),
+	primary key (base, quote, valid_from)
+);
@@ -0,0 +1 @@
+alter table account add column role varchar(20) not null default 'user';
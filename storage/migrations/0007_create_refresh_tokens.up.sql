@@ -0,0 +1,12 @@
+create table if not exists refresh_tokens (
+	id serial primary key,
+	account_id int not null references account(id),
+	family_id varchar(64) not null,
+	token_hash varchar(64) not null unique,
+	created_at timestamp not null,
+	expires_at timestamp not null,
+	used_at timestamp,
+	revoked_at timestamp
+);
+
+create index if not exists refresh_tokens_family_id_idx on refresh_tokens (family_id);
@@ -0,0 +1,11 @@
+create table if not exists sessions (
+	id varchar(64) primary key,
+	account_id int not null references account(id),
+	user_agent varchar(500),
+	ip varchar(64),
+	created_at timestamp not null,
+	last_seen_at timestamp not null,
+	revoked_at timestamp
+);
+
+create index if not exists sessions_account_id_idx on sessions (account_id);
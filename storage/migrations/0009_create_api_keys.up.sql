@@ -0,0 +1,14 @@
+create table if not exists api_keys (
+	id serial primary key,
+	account_id int not null references account(id),
+	name varchar(100) not null,
+	prefix varchar(16) not null,
+	key_hash varchar(64) not null unique,
+	scopes text[] not null default '{}',
+	created_at timestamp not null,
+	expires_at timestamp,
+	last_used_at timestamp,
+	revoked_at timestamp
+);
+
+create index if not exists api_keys_account_id_idx on api_keys (account_id);
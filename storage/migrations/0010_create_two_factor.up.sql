@@ -0,0 +1,16 @@
+create table if not exists two_factor (
+	account_id int primary key references account(id),
+	secret varchar(64) not null,
+	enabled_at timestamp,
+	last_used_step bigint not null default 0,
+	created_at timestamp not null
+);
+
+create table if not exists recovery_codes (
+	id serial primary key,
+	account_id int not null references account(id),
+	code_hash varchar(64) not null,
+	used_at timestamp
+);
+
+create index if not exists recovery_codes_account_id_idx on recovery_codes (account_id);
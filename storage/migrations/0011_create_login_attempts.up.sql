@@ -0,0 +1,11 @@
+create table if not exists login_attempts (
+	id serial primary key,
+	email varchar(255) not null,
+	ip varchar(64) not null,
+	outcome varchar(20) not null,
+	reason varchar(50),
+	created_at timestamp not null
+);
+
+create index if not exists login_attempts_email_idx on login_attempts (email, created_at);
+create index if not exists login_attempts_ip_idx on login_attempts (ip, created_at);
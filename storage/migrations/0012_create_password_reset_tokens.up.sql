@@ -0,0 +1,8 @@
+create table if not exists password_reset_tokens (
+	id serial primary key,
+	account_id int not null references account(id),
+	token_hash varchar(64) not null unique,
+	created_at timestamp not null,
+	expires_at timestamp not null,
+	used_at timestamp
+);
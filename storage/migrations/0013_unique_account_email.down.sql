@@ -0,0 +1 @@
+drop index if exists account_email_key;
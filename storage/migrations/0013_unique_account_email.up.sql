@@ -0,0 +1,13 @@
+-- Profile updates can change the email, so uniqueness is enforced here
+-- rather than only checked at registration. Addresses are compared without
+-- case, the way logins are throttled.
+drop index if exists account_email_key;
+
+-- Admins could create accounts without an email check, so an address can
+-- repeat. The oldest holder keeps it; the others get a placeholder they
+-- cannot log in with, in the style of purged accounts, and keep their id.
+update account a
+set email = 'duplicate-' || a.id || '@invalid'
+where exists (select 1 from account b where lower(b.email) = lower(a.email) and b.id < a.id);
+
+create unique index if not exists account_email_key on account (lower(email));
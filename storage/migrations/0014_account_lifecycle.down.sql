@@ -0,0 +1 @@
+alter table account drop column if exists deleted_at;
@@ -0,0 +1,4 @@
+alter table account add column if not exists deleted_at timestamp;
+
+-- Accounts deleted before the grace period existed start it now.
+update account set deleted_at = now() at time zone 'utc' where status = 'Deleted' and deleted_at is null;
@@ -0,0 +1,4 @@
+drop index if exists account_number_key;
+alter table account alter column number drop not null;
+alter table account alter column number type bigint using substring(number from 9)::bigint;
+alter table account alter column number set default nextval('account_number_seq');
@@ -0,0 +1,21 @@
+-- Account numbers become IBAN-style strings: GB, two mod-97 check digits,
+-- the bank code GOBK and the old number padded to 14 digits. G=16, O=24,
+-- B=11 and K=20 when computing the check digits, as in
+-- types.FormatAccountNumber.
+alter table account alter column number drop default;
+alter table account alter column number type varchar(34) using number::text;
+
+-- Numbers used to be drawn at random below 10^6, so they can repeat. Every
+-- account but the oldest holder of a number gets one derived from its id,
+-- in a range far above anything issued so far.
+update account a
+set number = (90000000000000 + a.id)::text
+where exists (select 1 from account b where b.number = a.number and b.id < a.id);
+
+update account
+set number = 'GB'
+	|| lpad((98 - mod(('16241120' || lpad(number, 14, '0') || '161100')::numeric, 97))::text, 2, '0')
+	|| 'GOBK' || lpad(number, 14, '0');
+
+alter table account alter column number set not null;
+create unique index if not exists account_number_key on account (number);
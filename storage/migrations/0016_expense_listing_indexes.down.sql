@@ -0,0 +1,2 @@
+drop index if exists expense_user_value_idx;
+drop index if exists expense_user_created_at_idx;
@@ -0,0 +1,3 @@
+-- Expense listings page with a keyset on the sort column and id.
+create index if not exists expense_user_created_at_idx on expense (user_id, created_at, id);
+create index if not exists expense_user_value_idx on expense (user_id, expense_value_amount, id);
@@ -0,0 +1,2 @@
+drop index if exists expense_search_idx;
+alter table expense drop column if exists search;
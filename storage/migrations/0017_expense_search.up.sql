@@ -0,0 +1,10 @@
+-- The simple configuration neither stems nor drops stop words, so a prefix
+-- of any word matches, as in the in-memory search. Names rank above
+-- purposes.
+alter table expense add column if not exists search tsvector
+	generated always as (
+		setweight(to_tsvector('simple', coalesce(expense_name, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(expense_purpose, '')), 'B')
+	) stored;
+
+create index if not exists expense_search_idx on expense using gin (search);
@@ -0,0 +1,9 @@
+alter table expense add column if not exists expense_category varchar(50);
+
+update expense e set expense_category = c.name
+from category c
+where c.id = e.category_id;
+
+drop index if exists expense_category_id_idx;
+alter table expense drop column if exists category_id;
+drop table if exists category;
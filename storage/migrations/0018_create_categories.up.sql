@@ -0,0 +1,32 @@
+create table if not exists category (
+	id serial primary key,
+	user_id int not null references account(id),
+	parent_id int references category(id),
+	name varchar(50) not null,
+	color varchar(7) not null default '',
+	icon varchar(50) not null default '',
+	created_at timestamp not null default (now() at time zone 'utc'),
+	updated_at timestamp not null default (now() at time zone 'utc')
+);
+
+-- Sibling names are unique ignoring case, top-level ones included.
+create unique index if not exists category_name_key on category (user_id, coalesce(parent_id, 0), lower(name));
+
+alter table expense add column if not exists category_id int references category(id);
+create index if not exists expense_category_id_idx on expense (category_id);
+
+-- Every free-text category becomes a top-level category of its user.
+-- Values differing only in case or surrounding spaces share one, named
+-- after their most common spelling.
+insert into category (user_id, name)
+select distinct on (user_id, lower(trim(expense_category))) user_id, trim(expense_category)
+from expense
+where user_id is not null and trim(coalesce(expense_category, '')) <> ''
+group by user_id, trim(expense_category)
+order by user_id, lower(trim(expense_category)), count(*) desc, trim(expense_category);
+
+update expense e set category_id = c.id
+from category c
+where c.user_id = e.user_id and c.parent_id is null and lower(c.name) = lower(trim(e.expense_category));
+
+alter table expense drop column if exists expense_category;
@@ -0,0 +1,2 @@
+drop table if exists expense_tags;
+drop table if exists tag;
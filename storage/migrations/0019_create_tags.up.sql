@@ -0,0 +1,17 @@
+create table if not exists tag (
+	id serial primary key,
+	user_id int not null references account(id),
+	name varchar(50) not null,
+	created_at timestamp not null default (now() at time zone 'utc')
+);
+
+-- Names are stored lower-case, so no lower() is needed here.
+create unique index if not exists tag_name_key on tag (user_id, name);
+
+create table if not exists expense_tags (
+	expense_id int not null references expense(id) on delete cascade,
+	tag_id int not null references tag(id) on delete cascade,
+	primary key (expense_id, tag_id)
+);
+
+create index if not exists expense_tags_tag_id_idx on expense_tags (tag_id);
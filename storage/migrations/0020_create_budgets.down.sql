@@ -0,0 +1,2 @@
+drop table if exists budget_alerts;
+drop table if exists budget;
@@ -0,0 +1,20 @@
+create table if not exists budget (
+	id serial primary key,
+	user_id int not null references account(id),
+	category_id int not null references category(id) on delete cascade,
+	limit_amount bigint not null,
+	limit_currency varchar(3) not null,
+	rollover boolean not null default false,
+	created_at timestamp not null default (now() at time zone 'utc'),
+	updated_at timestamp not null default (now() at time zone 'utc')
+);
+
+-- One budget per category; it applies to every month.
+create unique index if not exists budget_category_key on budget (user_id, category_id);
+
+create table if not exists budget_alerts (
+	budget_id int not null references budget(id) on delete cascade,
+	month varchar(7) not null,
+	threshold int not null,
+	primary key (budget_id, month, threshold)
+);
@@ -0,0 +1 @@
+alter table account drop column if exists previous_status;
@@ -0,0 +1,3 @@
+-- The status an account had before it was suspended or deleted, so that
+-- restoring it does not activate accounts whose email was never verified.
+alter table account add column if not exists previous_status varchar(50);
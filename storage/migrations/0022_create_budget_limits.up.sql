@@ -0,0 +1,13 @@
+-- The limit of a budget from a month on, so that changing it does not
+-- rewrite what earlier months rolled over.
+create table if not exists budget_limits (
+	budget_id int not null references budget(id) on delete cascade,
+	month varchar(7) not null,
+	limit_amount bigint not null,
+	limit_currency varchar(3) not null,
+	primary key (budget_id, month)
+);
+
+insert into budget_limits (budget_id, month, limit_amount, limit_currency)
+select id, to_char(created_at, 'YYYY-MM'), limit_amount, limit_currency from budget
+on conflict do nothing;
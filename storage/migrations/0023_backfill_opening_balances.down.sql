@@ -0,0 +1,4 @@
+delete from ledger_entry
+where transaction_id in (select id from ledger_transaction where kind = 'opening');
+
+delete from ledger_transaction where kind = 'opening';
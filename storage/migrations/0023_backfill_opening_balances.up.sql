@@ -0,0 +1,21 @@
+-- Balances that predate the ledger have no entries behind them. Give every
+-- account whose cached balance differs from its entries an opening deposit
+-- for the difference, dated before its first entry.
+create temporary table opening_balance on commit drop as
+select a.id as account_id,
+	nextval(pg_get_serial_sequence('ledger_transaction', 'id')) as transaction_id,
+	a.balance_amount - coalesce(sum(le.amount), 0) as amount,
+	a.balance_currency as currency,
+	coalesce(least(a.created_at, min(le.created_at) - interval '1 microsecond'), now()::timestamp) as created_at
+from account a
+left join ledger_entry le on le.account_id = a.id and le.currency = a.balance_currency
+group by a.id
+having a.balance_amount <> coalesce(sum(le.amount), 0);
+
+insert into ledger_transaction (id, kind, description, created_at)
+select transaction_id, 'opening', 'Opening balance', created_at from opening_balance;
+
+insert into ledger_entry (transaction_id, account_id, amount, currency, created_at)
+select transaction_id, account_id, amount, currency, created_at from opening_balance
+union all
+select transaction_id, null, -amount, currency, created_at from opening_balance;
@@ -0,0 +1 @@
+drop table if exists email_change_tokens;
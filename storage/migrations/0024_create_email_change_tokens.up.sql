@@ -0,0 +1,11 @@
+create table if not exists email_change_tokens (
+	id serial primary key,
+	account_id int not null references account(id),
+	email varchar(255) not null,
+	token_hash varchar(64) not null unique,
+	created_at timestamp not null,
+	expires_at timestamp not null,
+	used_at timestamp
+);
+
+create index if not exists email_change_tokens_account_id_idx on email_change_tokens (account_id);
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func (s *PostgresStore) CreatePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	_, err := s.Db.NewInsert().Model(token).Exec(ctx)
+	return err
+}
+
+// ConsumePasswordResetToken marks an unused, unexpired token as used and
+// returns it. The update is the check, so a token cannot be used twice even
+// by concurrent requests.
+func (s *PostgresStore) ConsumePasswordResetToken(ctx context.Context, hash string, now time.Time) (*types.PasswordResetToken, error) {
+	token := new(types.PasswordResetToken)
+	err := s.Db.NewUpdate().
+		Model(token).
+		Set("used_at = ?", now).
+		Where("token_hash = ?", hash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", now).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrResetTokenInvalid
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RevokePasswordResetTokens marks every unused token of the account used,
+// once its password has been changed or reset.
+func (s *PostgresStore) RevokePasswordResetTokens(ctx context.Context, accountId int, now time.Time) error {
+	_, err := s.Db.NewUpdate().
+		Model((*types.PasswordResetToken)(nil)).
+		Set("used_at = ?", now).
+		Where("account_id = ?", accountId).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	return err
+}
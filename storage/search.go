@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+// Matched words are wrapped in these markers in search highlights. The rest
+// of the text is HTML-escaped, so highlights are safe to render as HTML.
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// ts_headline marks matches with these control characters instead, so the
+// text can be escaped before the markers are swapped for highlightStart and
+// highlightStop.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// escapeHeadline HTML-escapes a ts_headline result and turns its markers
+// into highlight markers. ok reports whether anything in it matched.
+func escapeHeadline(headline string) (highlight string, ok bool) {
+	if !strings.Contains(headline, headlineStart) {
+		return "", false
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(headline, headlineStart) {
+		if i == 0 {
+			b.WriteString(html.EscapeString(part))
+			continue
+		}
+		word, rest, _ := strings.Cut(part, headlineStop)
+		b.WriteString(highlightStart + html.EscapeString(word) + highlightStop + html.EscapeString(rest))
+	}
+	return b.String(), true
+}
+
+// Weights of a matched word by field. They are ts_rank's defaults for the A
+// and B labels that migration 0017 gives expense_name and expense_purpose.
+const (
+	searchNameWeight    = 1.0
+	searchPurposeWeight = 0.4
+)
+
+// searchQuery turns the terms into a tsquery matching expenses that have a
+// word starting with each term.
+func searchQuery(terms []string) string {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+	return strings.Join(prefixes, " & ")
+}
+
+type expenseSearchRow struct {
+	ID                   int       `bun:"id"`
+	UserId               int       `bun:"user_id"`
+	ExpenseName          string    `bun:"expense_name"`
+	ExpensePurpose       string    `bun:"expense_purpose"`
+	CategoryId           *int      `bun:"category_id"`
+	ExpenseValueAmount   int64     `bun:"expense_value_amount"`
+	ExpenseValueCurrency string    `bun:"expense_value_currency"`
+	CreatedAt            time.Time `bun:"created_at"`
+	UpdatedAt            time.Time `bun:"updated_at"`
+	Rank                 float64   `bun:"rank"`
+	NameHighlight        string    `bun:"name_highlight"`
+	PurposeHighlight     string    `bun:"purpose_highlight"`
+}
+
+// SearchExpenses finds the user's expenses whose name or purpose has a word
+// starting with each term of text, best match first, using the search
+// column and its GIN index.
+func (s *PostgresStore) SearchExpenses(ctx context.Context, userId int, text string, limit int) ([]*types.ExpenseSearchResult, error) {
+	terms := types.SearchTerms(text)
+	if len(terms) == 0 {
+		return []*types.ExpenseSearchResult{}, nil
+	}
+
+	query := `
+		with search as (select to_tsquery('simple', ?) as query)
+		select e.id, e.user_id,
+			coalesce(e.expense_name, '') as expense_name,
+			coalesce(e.expense_purpose, '') as expense_purpose,
+			e.category_id, e.expense_value_amount, e.expense_value_currency, e.created_at, e.updated_at,
+			ts_rank(e.search, search.query) as rank,
+			ts_headline('simple', coalesce(e.expense_name, ''), search.query, ?) as name_highlight,
+			ts_headline('simple', coalesce(e.expense_purpose, ''), search.query, ?) as purpose_highlight
+		from expense e, search
+		where e.user_id = ? and e.search @@ search.query
+		order by rank desc, e.created_at desc, e.id desc
+		limit ?`
+	options := "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", HighlightAll=true"
+
+	var rows []expenseSearchRow
+	if err := s.Db.NewRaw(query, searchQuery(terms), options, options, userId, limit).Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	results := make([]*types.ExpenseSearchResult, 0, len(rows))
+	expenses := make([]*types.Expense, 0, len(rows))
+	for _, row := range rows {
+		result := &types.ExpenseSearchResult{
+			Expense: &types.Expense{
+				ID:             row.ID,
+				UserId:         row.UserId,
+				ExpenseName:    row.ExpenseName,
+				ExpensePurpose: row.ExpensePurpose,
+				CategoryId:     row.CategoryId,
+				ExpenseValue:   types.NewMoney(row.ExpenseValueAmount, row.ExpenseValueCurrency),
+				CreatedAt:      row.CreatedAt,
+				UpdatedAt:      row.UpdatedAt,
+			},
+			Rank:       row.Rank,
+			Highlights: map[string]string{},
+		}
+		// ts_headline returns the text unchanged when nothing in it matched.
+		if highlight, ok := escapeHeadline(row.NameHighlight); ok {
+			result.Highlights["expense_name"] = highlight
+		}
+		if highlight, ok := escapeHeadline(row.PurposeHighlight); ok {
+			result.Highlights["expense_purpose"] = highlight
+		}
+		results = append(results, result)
+		expenses = append(expenses, result.Expense)
+	}
+
+	if err := loadExpenseTags(ctx, s.Db, expenses); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// searchExpense matches exp the way the search column does for stores
+// without Postgres: every term must start a word of the name or purpose.
+func searchExpense(exp *types.Expense, terms []string) (*types.ExpenseSearchResult, bool) {
+	name, nameHits, nameFound := highlightWords(exp.ExpenseName, terms)
+	purpose, purposeHits, purposeFound := highlightWords(exp.ExpensePurpose, terms)
+	for _, term := range terms {
+		if !nameFound[term] && !purposeFound[term] {
+			return nil, false
+		}
+	}
+
+	result := &types.ExpenseSearchResult{
+		Expense:    copyExpense(exp),
+		Rank:       searchNameWeight*float64(nameHits) + searchPurposeWeight*float64(purposeHits),
+		Highlights: map[string]string{},
+	}
+	if nameHits > 0 {
+		result.Highlights["expense_name"] = name
+	}
+	if purposeHits > 0 {
+		result.Highlights["expense_purpose"] = purpose
+	}
+	return result, true
+}
+
+// highlightWords marks the words of text that start with one of the terms
+// and HTML-escapes the rest. It returns the marked text, how many words matched and which terms did.
+func highlightWords(text string, terms []string) (string, int, map[string]bool) {
+	var b strings.Builder
+	found := map[string]bool{}
+	hits := 0
+
+	word := -1
+	endWord := func(end int) {
+		lower := strings.ToLower(text[word:end])
+		matched := false
+		for _, term := range terms {
+			if strings.HasPrefix(lower, term) {
+				found[term] = true
+				matched = true
+			}
+		}
+		if matched {
+			hits++
+			b.WriteString(highlightStart + html.EscapeString(text[word:end]) + highlightStop)
+		} else {
+			b.WriteString(html.EscapeString(text[word:end]))
+		}
+		word = -1
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if word < 0 {
+				word = i
+			}
+		} else {
+			if word >= 0 {
+				endWord(i)
+			}
+			b.WriteString(html.EscapeString(text[i : i+size]))
+		}
+		i += size
+	}
+	if word >= 0 {
+		endWord(len(text))
+	}
+
+	return b.String(), hits, found
+}
+
+// sortSearchResults orders results best match first, then newest first.
+func sortSearchResults(results []*types.ExpenseSearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.Expense.CreatedAt.Equal(b.Expense.CreatedAt) {
+			return a.Expense.CreatedAt.After(b.Expense.CreatedAt)
+		}
+		return a.Expense.ID > b.Expense.ID
+	})
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+func (s *PostgresStore) CreateSession(ctx context.Context, session *types.Session) error {
+	_, err := s.Db.NewInsert().Model(session).Exec(ctx)
+	return err
+}
+
+func (s *PostgresStore) GetSession(ctx context.Context, id string) (*types.Session, error) {
+	session := new(types.Session)
+
+	err := s.Db.NewSelect().Model(session).Where("id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("session %s not found", id)
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetSessionsForAccount returns the sessions of an account that were not
+// revoked, most recently used first.
+func (s *PostgresStore) GetSessionsForAccount(ctx context.Context, accountId int) ([]*types.Session, error) {
+	var sessions []*types.Session
+	err := s.Db.NewSelect().
+		Model(&sessions).
+		Where("account_id = ?", accountId).
+		Where("revoked_at IS NULL").
+		Order("last_seen_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *PostgresStore) TouchSession(ctx context.Context, id string, seenAt time.Time) error {
+	_, err := s.Db.NewUpdate().
+		Model((*types.Session)(nil)).
+		Set("last_seen_at = ?", seenAt).
+		Where("id = ?", id).
+		Exec(ctx)
+
+	return err
+}
+
+func (s *PostgresStore) RevokeSession(ctx context.Context, id string) error {
+	return revokeFamily(ctx, s.Db, id, time.Now().UTC())
+}
+
+// RevokeAccountSessions revokes every session of the account except exceptId,
+// which may be empty to log out everywhere.
+func (s *PostgresStore) RevokeAccountSessions(ctx context.Context, accountId int, exceptId string) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var ids []string
+		err := tx.NewSelect().
+			Model((*types.Session)(nil)).
+			Column("id").
+			Where("account_id = ?", accountId).
+			Where("revoked_at IS NULL").
+			Where("id <> ?", exceptId).
+			Scan(ctx, &ids)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, id := range ids {
+			if err := revokeFamily(ctx, tx, id, now); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/ElenaGrasovskaya/gobank/types"
 	"github.com/joho/godotenv"
@@ -20,17 +21,100 @@ type Storage interface {
 	CreateAccount(context.Context, *types.Account) (*types.Account, error)
 	DeleteAccount(context.Context, int) error
 	RestoreAccount(context.Context, int) error
+	ActivateAccount(context.Context, int) error
+	SuspendAccount(context.Context, int) error
+	PurgeAccount(context.Context, int) error
+	GetAccountsDeletedBefore(context.Context, time.Time) ([]*types.Account, error)
+	SetAccountRole(context.Context, int, string) error
+	SetAccountPassword(ctx context.Context, id int, passwordHash string) error
 	UpdateAccount(context.Context, *types.Account) error
 	GetAccounts(context.Context) ([]*types.Account, error)
 	GetAccountById(context.Context, int) (*types.Account, error)
 	GetAccountByEmail(context.Context, string) (*types.Account, error)
+	GetAccountByNumber(context.Context, string) (*types.Account, error)
 
 	CreateExpense(context.Context, *types.Expense) (*types.Expense, error)
 	UpdateExpense(context.Context, int, *types.Expense) error
 	DeleteExpense(context.Context, int) error
-	GetExpenseForUser(context.Context, int) ([]*types.Expense, error)
+	GetExpenseForUser(context.Context, int, *types.ExpenseQuery) (*types.ExpensePage, error)
 	GetExpenseById(context.Context, int) (*types.Expense, error)
-	GetAllExpense(context.Context) ([]*types.Expense, error)
+	GetAllExpense(context.Context, *types.ExpenseQuery) (*types.ExpensePage, error)
+	SearchExpenses(ctx context.Context, userId int, text string, limit int) ([]*types.ExpenseSearchResult, error)
+
+	CreateCategory(context.Context, *types.Category) (*types.Category, error)
+	UpdateCategory(context.Context, *types.Category) error
+	DeleteCategory(context.Context, int) error
+	GetCategoryById(context.Context, int) (*types.Category, error)
+	GetCategoriesForUser(context.Context, int) ([]*types.Category, error)
+
+	GetTagsForUser(context.Context, int) ([]*types.TagUsage, error)
+	RenameTag(ctx context.Context, userId int, from, to string) error
+	MergeTags(ctx context.Context, userId int, from []string, into string) error
+
+	CreateBudget(context.Context, *types.Budget) (*types.Budget, error)
+	UpdateBudget(context.Context, *types.Budget) error
+	DeleteBudget(context.Context, int) error
+	GetBudgetById(context.Context, int) (*types.Budget, error)
+	GetBudgetsForUser(context.Context, int) ([]*types.Budget, error)
+	GetBudgetLimits(context.Context, int) ([]*types.BudgetLimit, error)
+	RecordBudgetAlert(ctx context.Context, budgetId int, month string, threshold int) (bool, error)
+	DeleteBudgetAlert(ctx context.Context, budgetId int, month string, threshold int) error
+
+	PostLedgerTransaction(context.Context, *types.LedgerTransaction) (*types.LedgerTransaction, error)
+	GetStatement(ctx context.Context, accountId, page, limit int) (*types.StatementPage, error)
+
+	SaveExchangeRates(context.Context, []*types.ExchangeRate) error
+	GetExchangeRate(ctx context.Context, base, quote string, on time.Time) (*types.ExchangeRate, error)
+
+	CreateRefreshToken(context.Context, *types.RefreshToken) error
+	GetRefreshToken(ctx context.Context, hash string) (*types.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, hash string, next *types.RefreshToken) (*types.RefreshToken, error)
+	RevokeRefreshFamily(ctx context.Context, familyId string) error
+
+	CreateSession(context.Context, *types.Session) error
+	GetSession(context.Context, string) (*types.Session, error)
+	GetSessionsForAccount(context.Context, int) ([]*types.Session, error)
+	TouchSession(ctx context.Context, id string, seenAt time.Time) error
+	RevokeSession(context.Context, string) error
+	RevokeAccountSessions(ctx context.Context, accountId int, exceptId string) error
+	CreateApiKey(context.Context, *types.ApiKey) error
+	GetApiKeyByHash(context.Context, string) (*types.ApiKey, error)
+	GetApiKeysForAccount(context.Context, int) ([]*types.ApiKey, error)
+	TouchApiKey(ctx context.Context, id int, usedAt time.Time) error
+	RevokeApiKey(context.Context, int) error
+	SaveTwoFactor(context.Context, *types.TwoFactor) error
+	GetTwoFactor(context.Context, int) (*types.TwoFactor, error)
+	UseTotpStep(ctx context.Context, accountId int, step int64) error
+	DeleteTwoFactor(context.Context, int) error
+	ReplaceRecoveryCodes(ctx context.Context, accountId int, codes []*types.RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, accountId int, hash string) error
+	RecordLoginAttempt(context.Context, *types.LoginAttempt) error
+	GetLoginFailures(ctx context.Context, email, ip string, since time.Time) (*types.LoginFailures, error)
+	CountLoginAttempts(ctx context.Context, outcome, email, ip string, since time.Time) (*types.LoginFailures, error)
+	CreatePasswordResetToken(context.Context, *types.PasswordResetToken) error
+	ConsumePasswordResetToken(ctx context.Context, hash string, now time.Time) (*types.PasswordResetToken, error)
+	RevokePasswordResetTokens(ctx context.Context, accountId int, now time.Time) error
+	CreateEmailChangeToken(context.Context, *types.EmailChangeToken) error
+	ConsumeEmailChangeToken(ctx context.Context, accountId int, hash string, now time.Time) (*types.EmailChangeToken, error)
+}
+
+const (
+	accountNumberConstraint = "account_number_key"
+	accountEmailConstraint  = "account_email_key"
+
+	// accountNumberAttempts bounds the retries on number collisions, which
+	// with 10^14 possible numbers should never take more than one.
+	accountNumberAttempts = 5
+)
+
+// uniqueViolation reports whether err is a unique constraint violation and
+// names the constraint.
+func uniqueViolation(err error) (string, bool) {
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) && pgErr.Field('C') == "23505" {
+		return pgErr.Field('n'), true
+	}
+	return "", false
 }
 
 type PostgresStore struct {
@@ -75,64 +159,92 @@ func NewPostgresStore() (*PostgresStore, error) {
 	return &PostgresStore{Db: db}, nil
 }
 
+// Init brings the schema up to the latest migration.
 func (s *PostgresStore) Init() error {
-	return s.CreateTables()
-}
-
-func (s *PostgresStore) CreateTables() error {
-	query := `
-		create table if not exists account (
-		id serial primary key,
-		first_name varchar(50),
-		last_name varchar(50),
-		email varchar(50),
-		password varchar(200),
-		status varchar(50),
-		number serial,
-		balance int,
-		created_at timestamp
-	);
-		create table if not exists expense (
-		id serial primary key,
-		user_id int,
-		expense_name varchar(50),
-		expense_purpose varchar(50),
-		expense_category varchar(50),
-		expense_value float,
-		created_at timestamp,
-		updated_at timestamp,
-		FOREIGN KEY (user_id) REFERENCES account(id)
-		);`
-	_, err := s.Db.Exec(query)
-	return err
+	return s.MigrateUp(context.Background())
 }
 
+// CreateAccount stores acc, giving it a new account number if it has none.
+// A generated number that turns out to be taken is replaced; a number the
+// caller chose is not, and ErrAccountNumberInUse is returned instead.
 func (s *PostgresStore) CreateAccount(ctx context.Context, acc *types.Account) (*types.Account, error) {
-	_, err := s.Db.NewInsert().Model(acc).Exec(ctx)
-	if err != nil {
-		return nil, err
+	generated := acc.Number == ""
+	for attempt := 1; ; attempt++ {
+		if generated {
+			number, err := types.NewAccountNumber()
+			if err != nil {
+				return nil, err
+			}
+			acc.Number = number
+		}
+
+		_, err := s.Db.NewInsert().Model(acc).Exec(ctx)
+		switch constraint, _ := uniqueViolation(err); {
+		case err == nil:
+			return acc, nil
+		case constraint == accountNumberConstraint && !generated:
+			return nil, ErrAccountNumberInUse
+		case constraint == accountNumberConstraint && attempt < accountNumberAttempts:
+			continue
+		case constraint == accountNumberConstraint:
+			acc.Number = ""
+			return nil, ErrAccountNumberTaken
+		case constraint == accountEmailConstraint:
+			return nil, ErrEmailTaken
+		default:
+			return nil, err
+		}
 	}
-	return acc, nil
 }
 
 func (s *PostgresStore) CreateExpense(ctx context.Context, exp *types.Expense) (*types.Expense, error) {
-	_, err := s.Db.NewInsert().Model(exp).Exec(ctx)
+	err := s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(exp).Exec(ctx); err != nil {
+			return err
+		}
+		return saveExpenseTags(ctx, tx, exp)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if exp.Tags == nil {
+		exp.Tags = []string{}
+	}
 	return exp, nil
 }
 
-func (s *PostgresStore) UpdateAccount(context.Context, *types.Account) error {
+// UpdateAccount saves the profile fields of acc: names and email.
+func (s *PostgresStore) UpdateAccount(ctx context.Context, acc *types.Account) error {
+	res, err := s.Db.NewUpdate().
+		Model(acc).
+		Column("first_name", "last_name", "email").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		if constraint, ok := uniqueViolation(err); ok && constraint == accountEmailConstraint {
+			return ErrEmailTaken
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("account %d not found", acc.ID)
+	}
 
 	return nil
 }
 
+// DeleteAccount marks the account deleted and starts its grace period.
 func (s *PostgresStore) DeleteAccount(ctx context.Context, id int) error {
-	newStatus := "Deleted"
 	_, err := s.Db.NewUpdate().
 		Model((*types.Account)(nil)).
-		Set("status = ?", newStatus).
+		Apply(rememberStatus).
+		Set("status = ?", types.StatusDeleted).
+		Set("deleted_at = ?", time.Now().UTC()).
 		Where("id = ?", id).
 		Exec(ctx)
 
@@ -140,32 +252,68 @@ func (s *PostgresStore) DeleteAccount(ctx context.Context, id int) error {
 }
 
 func (s *PostgresStore) RestoreAccount(ctx context.Context, id int) error {
-	newStatus := "Active"
 	_, err := s.Db.NewUpdate().
 		Model((*types.Account)(nil)).
-		Set("status = ?", newStatus).
+		Set("status = coalesce(previous_status, ?)", types.StatusActive).
+		Set("previous_status = NULL").
+		Set("deleted_at = NULL").
 		Where("id = ?", id).
 		Exec(ctx)
 
 	return err
 }
 
-func (s *PostgresStore) DeleteExpense(ctx context.Context, id int) error {
+// ActivateAccount marks a pending account active once its email address is
+// verified. Accounts in any other status are left alone.
+func (s *PostgresStore) ActivateAccount(ctx context.Context, id int) error {
+	res, err := s.Db.NewUpdate().
+		Model((*types.Account)(nil)).
+		Set("status = ?", types.StatusActive).
+		Where("id = ?", id).
+		Where("status = ?", types.StatusPending).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
 
-	expense := &types.Expense{ID: id}
-	_, err := s.Db.NewDelete().Model(expense).Where("? = ?", bun.Ident("id"), id).Exec(ctx)
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAccountNotPending
+	}
 
-	return err
+	return nil
 }
 
-func (s *PostgresStore) UpdateExpense(ctx context.Context, id int, newExp *types.Expense) error {
-	newExp.ID = id
-
+func (s *PostgresStore) SetAccountPassword(ctx context.Context, id int, passwordHash string) error {
 	res, err := s.Db.NewUpdate().
-		Model(newExp).
+		Model((*types.Account)(nil)).
+		Set("password = ?", passwordHash).
 		Where("id = ?", id).
 		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("account %d not found", id)
+	}
+
+	return nil
+}
 
+func (s *PostgresStore) SetAccountRole(ctx context.Context, id int, role string) error {
+	res, err := s.Db.NewUpdate().
+		Model((*types.Account)(nil)).
+		Set("role = ?", role).
+		Where("id = ?", id).
+		Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -175,12 +323,45 @@ func (s *PostgresStore) UpdateExpense(ctx context.Context, id int, newExp *types
 		return err
 	}
 	if affected == 0 {
-		return fmt.Errorf("no rows affected")
+		return fmt.Errorf("account %d not found", id)
 	}
 
 	return nil
 }
 
+func (s *PostgresStore) DeleteExpense(ctx context.Context, id int) error {
+
+	expense := &types.Expense{ID: id}
+	_, err := s.Db.NewDelete().Model(expense).Where("? = ?", bun.Ident("id"), id).Exec(ctx)
+
+	return err
+}
+
+// UpdateExpense replaces the expense, tags included.
+func (s *PostgresStore) UpdateExpense(ctx context.Context, id int, newExp *types.Expense) error {
+	newExp.ID = id
+
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewUpdate().
+			Model(newExp).
+			Where("id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("no rows affected")
+		}
+
+		return saveExpenseTags(ctx, tx, newExp)
+	})
+}
+
 func (s *PostgresStore) GetAccountById(ctx context.Context, id int) (*types.Account, error) {
 	if id == 0 {
 		return nil, fmt.Errorf("account %d not found", id)
@@ -214,9 +395,14 @@ func (s *PostgresStore) GetExpenseById(ctx context.Context, id int) (*types.Expe
 		return nil, err
 	}
 
+	if err := loadExpenseTags(ctx, s.Db, []*types.Expense{expense}); err != nil {
+		return nil, err
+	}
 	return expense, nil
 }
 
+// GetAccountByEmail finds the account with the given email, ignoring case
+// like the account_email_key index.
 func (s *PostgresStore) GetAccountByEmail(ctx context.Context, email string) (*types.Account, error) {
 	if email == "" {
 		return nil, fmt.Errorf("account %v not found", email)
@@ -224,7 +410,7 @@ func (s *PostgresStore) GetAccountByEmail(ctx context.Context, email string) (*t
 
 	account := new(types.Account)
 
-	err := s.Db.NewSelect().Model(account).Where("email = ?", email).Scan(ctx)
+	err := s.Db.NewSelect().Model(account).Where("lower(email) = lower(?)", email).Scan(ctx)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, fmt.Errorf("account for %v not found", email)
@@ -235,6 +421,24 @@ func (s *PostgresStore) GetAccountByEmail(ctx context.Context, email string) (*t
 	return account, nil
 }
 
+func (s *PostgresStore) GetAccountByNumber(ctx context.Context, number string) (*types.Account, error) {
+	number, err := types.ParseAccountNumber(number)
+	if err != nil {
+		return nil, err
+	}
+
+	account := new(types.Account)
+	err = s.Db.NewSelect().Model(account).Where("number = ?", number).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("account number %s not found", number)
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
+
 func (s *PostgresStore) GetAccounts(ctx context.Context) ([]*types.Account, error) {
 	var accounts []*types.Account
 	err := s.Db.NewSelect().Model(&accounts).Order("id ASC").Scan(ctx)
@@ -255,8 +459,11 @@ func ScanIntoAccount(r *sql.Rows) (*types.Account, error) {
 		&account.Password,
 		&account.Status,
 		&account.Number,
-		&account.Balance,
-		&account.CreatedAt)
+		&account.CreatedAt,
+		&account.Balance.Amount,
+		&account.Balance.Currency,
+		&account.Role,
+		&account.DeletedAt)
 
 	return account, err
 }
@@ -268,31 +475,12 @@ func ScanIntoExpense(r *sql.Rows) (*types.Expense, error) {
 		&expense.UserId,
 		&expense.ExpenseName,
 		&expense.ExpensePurpose,
-		&expense.ExpenseCategory,
-		&expense.ExpenseValue,
+		&expense.CategoryId,
 		&expense.CreatedAt,
 		&expense.UpdatedAt,
+		&expense.ExpenseValue.Amount,
+		&expense.ExpenseValue.Currency,
 	)
 
 	return expense, err
 }
-
-func (s *PostgresStore) GetExpenseForUser(ctx context.Context, id int) ([]*types.Expense, error) {
-	var expenses []*types.Expense
-	err := s.Db.NewSelect().Model(&expenses).Where("user_id = ?", id).Order("id ASC").Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return expenses, nil
-}
-
-func (s *PostgresStore) GetAllExpense(ctx context.Context) ([]*types.Expense, error) {
-	var expenses []*types.Expense
-	err := s.Db.NewSelect().Model(&expenses).Order("id ASC").Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	return expenses, nil
-}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+const tagNameConstraint = "tag_name_key"
+
+// saveExpenseTags replaces the tags of exp with exp.Tags, creating the tags
+// its owner does not have yet.
+func saveExpenseTags(ctx context.Context, db bun.IDB, exp *types.Expense) error {
+	if _, err := db.NewDelete().Model((*types.ExpenseTag)(nil)).Where("expense_id = ?", exp.ID).Exec(ctx); err != nil {
+		return err
+	}
+	if len(exp.Tags) == 0 {
+		return nil
+	}
+
+	tagIds, err := ensureTags(ctx, db, exp.UserId, exp.Tags)
+	if err != nil {
+		return err
+	}
+
+	links := make([]*types.ExpenseTag, len(tagIds))
+	for i, tagId := range tagIds {
+		links[i] = &types.ExpenseTag{ExpenseId: exp.ID, TagId: tagId}
+	}
+	_, err = db.NewInsert().Model(&links).Exec(ctx)
+	return err
+}
+
+// ensureTags creates the named tags the user does not have yet and returns
+// the ids of all of them.
+func ensureTags(ctx context.Context, db bun.IDB, userId int, names []string) ([]int, error) {
+	now := time.Now().UTC()
+	tags := make([]*types.Tag, len(names))
+	for i, name := range names {
+		tags[i] = &types.Tag{UserId: userId, Name: name, CreatedAt: now}
+	}
+
+	_, err := db.NewInsert().
+		Model(&tags).
+		On("CONFLICT (user_id, name) DO NOTHING").
+		Returning("NULL").
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	err = db.NewSelect().
+		Model((*types.Tag)(nil)).
+		Column("id").
+		Where("user_id = ?", userId).
+		Where("name IN (?)", bun.In(names)).
+		Scan(ctx, &ids)
+	return ids, err
+}
+
+// loadExpenseTags fills in the tags of every expense, sorted by name.
+func loadExpenseTags(ctx context.Context, db bun.IDB, expenses []*types.Expense) error {
+	byId := make(map[int]*types.Expense, len(expenses))
+	ids := make([]int, 0, len(expenses))
+	for _, exp := range expenses {
+		exp.Tags = []string{}
+		byId[exp.ID] = exp
+		ids = append(ids, exp.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		ExpenseId int    `bun:"expense_id"`
+		Name      string `bun:"name"`
+	}
+	err := db.NewSelect().
+		Model((*types.ExpenseTag)(nil)).
+		ColumnExpr("et.expense_id, t.name").
+		Join("JOIN tag AS t ON t.id = et.tag_id").
+		Where("et.expense_id IN (?)", bun.In(ids)).
+		Order("t.name ASC").
+		Scan(ctx, &rows)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		exp := byId[row.ExpenseId]
+		exp.Tags = append(exp.Tags, row.Name)
+	}
+	return nil
+}
+
+// taggedExpenses selects the ids of expenses carrying any of the tags, or
+// all of them.
+func taggedExpenses(db bun.IDB, tags []string, all bool) *bun.SelectQuery {
+	sel := db.NewSelect().
+		Model((*types.ExpenseTag)(nil)).
+		Column("et.expense_id").
+		Join("JOIN tag AS t ON t.id = et.tag_id").
+		Where("t.name IN (?)", bun.In(tags))
+	if all {
+		sel = sel.Group("et.expense_id").Having("count(*) = ?", len(tags))
+	}
+	return sel
+}
+
+// GetTagsForUser lists the user's tags, most used first.
+func (s *PostgresStore) GetTagsForUser(ctx context.Context, userId int) ([]*types.TagUsage, error) {
+	query := `
+		select t.name, count(et.expense_id) as count
+		from tag t
+		left join expense_tags et on et.tag_id = t.id
+		where t.user_id = ?
+		group by t.id, t.name
+		order by count desc, t.name`
+
+	tags := []*types.TagUsage{}
+	if err := s.Db.NewRaw(query, userId).Scan(ctx, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// RenameTag renames one of the user's tags on all of their expenses.
+func (s *PostgresStore) RenameTag(ctx context.Context, userId int, from, to string) error {
+	res, err := s.Db.NewUpdate().
+		Model((*types.Tag)(nil)).
+		Set("name = ?", to).
+		Where("user_id = ?", userId).
+		Where("name = ?", from).
+		Exec(ctx)
+	if err != nil {
+		if constraint, ok := uniqueViolation(err); ok && constraint == tagNameConstraint {
+			return ErrTagExists
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// MergeTags moves the expenses of the tags in from to into, creating it if
+// needed, and removes the tags in from.
+func (s *PostgresStore) MergeTags(ctx context.Context, userId int, from []string, into string) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		targetIds, err := ensureTags(ctx, tx, userId, []string{into})
+		if err != nil {
+			return err
+		}
+
+		var sourceIds []int
+		err = tx.NewSelect().
+			Model((*types.Tag)(nil)).
+			Column("id").
+			Where("user_id = ?", userId).
+			Where("name IN (?)", bun.In(from)).
+			Where("name <> ?", into).
+			Scan(ctx, &sourceIds)
+		if err != nil {
+			return err
+		}
+		if len(sourceIds) != len(withoutTag(from, into)) {
+			return ErrTagNotFound
+		}
+		if len(sourceIds) == 0 {
+			return nil
+		}
+
+		_, err = tx.NewRaw(`
+			insert into expense_tags (expense_id, tag_id)
+			select distinct expense_id, ? from expense_tags where tag_id in (?)
+			on conflict do nothing`, targetIds[0], bun.In(sourceIds)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().Model((*types.Tag)(nil)).Where("id IN (?)", bun.In(sourceIds)).Exec(ctx)
+		return err
+	})
+}
+
+func withoutTag(tags []string, tag string) []string {
+	rest := []string{}
+	for _, t := range tags {
+		if t != tag {
+			rest = append(rest, t)
+		}
+	}
+	return rest
+}
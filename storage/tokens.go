@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+func (s *PostgresStore) CreateRefreshToken(ctx context.Context, token *types.RefreshToken) error {
+	_, err := s.Db.NewInsert().Model(token).Exec(ctx)
+	return err
+}
+
+func (s *PostgresStore) GetRefreshToken(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	token := new(types.RefreshToken)
+
+	err := s.Db.NewSelect().Model(token).Where("token_hash = ?", hash).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken marks the token with the given hash as used and stores
+// next in the same family. Presenting a token that was already used revokes
+// the whole family and returns ErrRefreshTokenReused.
+func (s *PostgresStore) RotateRefreshToken(ctx context.Context, hash string, next *types.RefreshToken) (*types.RefreshToken, error) {
+	var rotateErr error
+
+	err := s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		current := new(types.RefreshToken)
+		err := tx.NewSelect().Model(current).Where("token_hash = ?", hash).For("UPDATE").Scan(ctx)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				rotateErr = ErrRefreshTokenInvalid
+				return nil
+			}
+			return err
+		}
+
+		now := time.Now().UTC()
+		if rotateErr = checkRotation(current, now); rotateErr != nil {
+			if errors.Is(rotateErr, ErrRefreshTokenReused) {
+				return revokeFamily(ctx, tx, current.FamilyId, now)
+			}
+			return nil
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*types.RefreshToken)(nil)).
+			Set("used_at = ?", now).
+			Where("id = ?", current.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		next.AccountId = current.AccountId
+		next.FamilyId = current.FamilyId
+		_, err = tx.NewInsert().Model(next).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rotateErr != nil {
+		return nil, rotateErr
+	}
+
+	return next, nil
+}
+
+func (s *PostgresStore) RevokeRefreshFamily(ctx context.Context, familyId string) error {
+	return revokeFamily(ctx, s.Db, familyId, time.Now().UTC())
+}
+
+// revokeFamily revokes every refresh token of a login together with the
+// session row of the same id.
+func revokeFamily(ctx context.Context, db bun.IDB, familyId string, now time.Time) error {
+	_, err := db.NewUpdate().
+		Model((*types.RefreshToken)(nil)).
+		Set("revoked_at = ?", now).
+		Where("family_id = ?", familyId).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewUpdate().
+		Model((*types.Session)(nil)).
+		Set("revoked_at = ?", now).
+		Where("id = ?", familyId).
+		Where("revoked_at IS NULL").
+		Exec(ctx)
+
+	return err
+}
+
+// checkRotation decides whether a stored refresh token may be exchanged.
+// Replay of a used token is reported before expiry so that a stolen old
+// token still burns its family.
+func checkRotation(token *types.RefreshToken, now time.Time) error {
+	switch {
+	case token.RevokedAt != nil:
+		return ErrRefreshTokenInvalid
+	case token.UsedAt != nil:
+		return ErrRefreshTokenReused
+	case !now.Before(token.ExpiresAt):
+		return ErrRefreshTokenInvalid
+	default:
+		return nil
+	}
+}
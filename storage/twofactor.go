@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/uptrace/bun"
+)
+
+// SaveTwoFactor creates or replaces the two-factor settings of an account.
+func (s *PostgresStore) SaveTwoFactor(ctx context.Context, tf *types.TwoFactor) error {
+	_, err := s.Db.NewInsert().
+		Model(tf).
+		On("CONFLICT (account_id) DO UPDATE").
+		Set("secret = EXCLUDED.secret").
+		Set("enabled_at = EXCLUDED.enabled_at").
+		Set("last_used_step = EXCLUDED.last_used_step").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+
+	return err
+}
+
+func (s *PostgresStore) GetTwoFactor(ctx context.Context, accountId int) (*types.TwoFactor, error) {
+	tf := new(types.TwoFactor)
+
+	err := s.Db.NewSelect().Model(tf).Where("account_id = ?", accountId).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, err
+	}
+
+	return tf, nil
+}
+
+// UseTotpStep records that the code of a time step was used. It fails with
+// ErrTotpCodeReused for that step or an earlier one, so a code cannot be
+// replayed within its validity window.
+func (s *PostgresStore) UseTotpStep(ctx context.Context, accountId int, step int64) error {
+	res, err := s.Db.NewUpdate().
+		Model((*types.TwoFactor)(nil)).
+		Set("last_used_step = ?", step).
+		Where("account_id = ?", accountId).
+		Where("last_used_step < ?", step).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return ErrTotpCodeReused
+	}
+
+	return nil
+}
+
+// DeleteTwoFactor turns two-factor login off and drops the recovery codes.
+func (s *PostgresStore) DeleteTwoFactor(ctx context.Context, accountId int) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*types.RecoveryCode)(nil)).Where("account_id = ?", accountId).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().Model((*types.TwoFactor)(nil)).Where("account_id = ?", accountId).Exec(ctx)
+		return err
+	})
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of the account for codes.
+func (s *PostgresStore) ReplaceRecoveryCodes(ctx context.Context, accountId int, codes []*types.RecoveryCode) error {
+	return s.Db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().Model((*types.RecoveryCode)(nil)).Where("account_id = ?", accountId).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(codes) == 0 {
+			return nil
+		}
+		_, err = tx.NewInsert().Model(&codes).Exec(ctx)
+		return err
+	})
+}
+
+func (s *PostgresStore) UseRecoveryCode(ctx context.Context, accountId int, hash string) error {
+	res, err := s.Db.NewUpdate().
+		Model((*types.RecoveryCode)(nil)).
+		Set("used_at = ?", time.Now().UTC()).
+		Where("account_id = ?", accountId).
+		Where("code_hash = ?", hash).
+		Where("used_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package tag
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+)
+
+type TagHandlers interface {
+	HandleGetTags(*gin.Context)
+	HandleRenameTag(*gin.Context)
+	HandleMergeTags(*gin.Context)
+}
+
+type StoreHandler struct {
+	store storage.Storage
+}
+
+func NewTagHandler(store storage.Storage) *StoreHandler {
+	return &StoreHandler{
+		store: store,
+	}
+}
+
+// HandleGetTags lists the caller's tags with the number of expenses
+// carrying each, most used first.
+func (s *StoreHandler) HandleGetTags(c *gin.Context) {
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := s.store.GetTagsForUser(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// HandleRenameTag renames the tag in the path on all of the caller's
+// expenses. Renaming onto an existing tag is refused; merge them instead.
+func (s *StoreHandler) HandleRenameTag(c *gin.Context) {
+	req := new(types.RenameTagRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := types.NormalizeTag(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := types.NormalizeTag(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.store.RenameTag(c.Request.Context(), userId, from, to); err != nil {
+		tagStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]string{"renamed": from, "name": to})
+}
+
+// HandleMergeTags moves the caller's expenses from several tags onto one
+// and removes the others.
+func (s *StoreHandler) HandleMergeTags(c *gin.Context) {
+	req := new(types.MergeTagsRequest)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId, err := services.GetIdFromCookie(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, err := types.NormalizeTags(req.From)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(from) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must name at least one tag"})
+		return
+	}
+	into, err := types.NormalizeTag(req.Into)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.store.MergeTags(c.Request.Context(), userId, from, into); err != nil {
+		tagStoreError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"merged": from, "into": into})
+}
+
+func tagStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storage.ErrTagNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, storage.ErrTagExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ElenaGrasovskaya/gobank/notify"
 	"github.com/ElenaGrasovskaya/gobank/router"
 	"github.com/ElenaGrasovskaya/gobank/services"
 	"github.com/ElenaGrasovskaya/gobank/storage"
@@ -30,30 +31,42 @@ import (
 	"github.com/uptrace/bun/driver/pgdriver"
 )
 
-func createMockAuthCookie() (*http.Cookie, *types.Account) {
-	mockAccount := &types.Account{
+func newMockAccount() *types.Account {
+	return &types.Account{
 		ID:        7,
 		FirstName: "Test",
 		LastName:  "Testovich",
 		Email:     "testing@gmail.com",
 		Password:  "$2a$10$q/cjukk2QtKtTdcaype0UOgPydr5MRcQm9wmbpfvyDksUuuv2gomu",
 		Status:    "Active",
-		Number:    112302,
-		Balance:   0,
+		Number:    types.FormatAccountNumber(112302),
+		Balance:   types.NewMoney(0, types.DefaultCurrency),
 		CreatedAt: time.Now(),
 	}
+}
 
-	tokenString, err := services.CreateJWT(mockAccount)
+// authCookie starts a session for the account and returns an access token
+// cookie tied to it.
+func authCookie(store storage.Storage, account *types.Account) *http.Cookie {
+	session, err := services.StartSession(context.Background(), store, account, "test", "127.0.0.1")
+	if err != nil {
+		log.Fatalf("Failed to start session: %v", err)
+	}
+
+	tokenString, err := services.CreateJWT(account, session.ID)
 	if err != nil {
 		log.Fatalf("Failed to generate token: %v", err)
 	}
 
-	cookie := &http.Cookie{
+	return &http.Cookie{
 		Name:  "token",
 		Value: tokenString,
 	}
+}
 
-	return cookie, mockAccount
+func createMockAuthCookie(store storage.Storage) (*http.Cookie, *types.Account) {
+	mockAccount := newMockAccount()
+	return authCookie(store, mockAccount), mockAccount
 }
 
 func NewTestPostgresStore() (*storage.PostgresStore, error) {
@@ -82,10 +95,28 @@ func NewTestPostgresStore() (*storage.PostgresStore, error) {
 	return &storage.PostgresStore{Db: db}, nil
 }
 
-func InitializeTestServer() (*gin.Engine, *storage.PostgresStore) {
+// NewTestMemoryStore returns an in-memory store seeded with the account
+// that createMockAuthCookie signs tokens for.
+func NewTestMemoryStore() *storage.MemoryStore {
+	store := storage.NewMemoryStore()
+	if _, err := store.CreateAccount(context.Background(), newMockAccount()); err != nil {
+		log.Fatalf("Failed to seed the test store: %v", err)
+	}
+
+	return store
+}
+
+// InitializeTestServer runs against an in-memory store unless TEST_POSTGRES
+// is set, in which case the database described by ../.env is used.
+func InitializeTestServer() (*gin.Engine, storage.Storage) {
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
 
+	if os.Getenv("TEST_POSTGRES") == "" {
+		store := NewTestMemoryStore()
+		return router.SetupRouter(store), store
+	}
+
 	store, err := NewTestPostgresStore()
 	if err != nil {
 		log.Fatalf("Failed to initialize the test store: %v", err)
@@ -166,25 +197,55 @@ func TestHandleLogout(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code, "Expected not found got %v", w.Code)
 }
 
+// createAdminAuthCookie stores an admin account and returns a cookie for it.
+func createAdminAuthCookie(store storage.Storage) *http.Cookie {
+	admin := &types.Account{
+		FirstName: "Admin",
+		LastName:  "Adminovich",
+		Email:     "admin@gmail.com",
+		Status:    "Active",
+		Role:      types.RoleAdmin,
+		Number:    types.FormatAccountNumber(100001),
+		Balance:   types.NewMoney(0, types.DefaultCurrency),
+		CreatedAt: time.Now(),
+	}
+	admin, err := store.CreateAccount(context.Background(), admin)
+	if err != nil {
+		log.Fatalf("Failed to create admin account: %v", err)
+	}
+
+	return authCookie(store, admin)
+}
+
 func TestHandleGetAccount(t *testing.T) {
-	router, _ := InitializeTestServer()
-	cookie, _ := createMockAuthCookie()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	adminCookie := createAdminAuthCookie(store)
 
+	// Test 1: Regular users may not list every account
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/accounts", nil)
 	req.AddCookie(cookie)
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusForbidden, w.Code, "Expected status code 403")
+
+	// Test 2: Admin request
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/accounts", nil)
+	req.AddCookie(adminCookie)
+	router.ServeHTTP(w, req)
+
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected Status.OK and accounts data; got %v", w.Code)
 	}
 }
 
 func TestHandleGetAccountById(t *testing.T) {
-	router, _ := InitializeTestServer()
+	router, store := InitializeTestServer()
 
 	testID := 7
-	cookie, mockAccount := createMockAuthCookie()
+	cookie, mockAccount := createMockAuthCookie(store)
 	// Test 1: Valid request
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/account/%d", testID), nil)
@@ -228,7 +289,7 @@ func TestHandleGetAccountById(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected status code 400")
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected status code 404")
 
 	//Test 5: Account doesn't exist
 
@@ -238,14 +299,26 @@ func TestHandleGetAccountById(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code, "Expected status code 400, got %v", w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Expected status code 404, got %v", w.Code)
 }
 
 func TestHandleDeleteAccount(t *testing.T) {
-	router, _ := InitializeTestServer()
+	router, store := InitializeTestServer()
 
 	testID := "7"
-	cookie, _ := createMockAuthCookie()
+	cookie, _ := createMockAuthCookie(store)
+	adminCookie := createAdminAuthCookie(store)
+
+	// Regular users may not delete accounts, not even their own
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/account/"+testID, nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// The cases below expect the account to be deleted already.
+	assert.NoError(t, store.DeleteAccount(context.Background(), 7))
+
 	tests := []struct {
 		description  string
 		accountID    string
@@ -253,7 +326,7 @@ func TestHandleDeleteAccount(t *testing.T) {
 		expectedBody string
 	}{
 		//{"Delete existing account", testID, http.StatusOK, "{\"deleted\":7}"},
-		{"Delete non-existing account", "0", http.StatusBadRequest, ""},
+		{"Delete non-existing account", "0", http.StatusNotFound, ""},
 		{"Invalid account ID", "abc", http.StatusBadRequest, ""},
 		{"Delete already deleted account", testID, http.StatusBadRequest, "{\"This accout was already deleted\": 7}"},
 	}
@@ -262,7 +335,7 @@ func TestHandleDeleteAccount(t *testing.T) {
 		t.Run(test.description, func(t *testing.T) {
 			w := httptest.NewRecorder()
 			req, _ := http.NewRequest("DELETE", "/account/"+test.accountID, nil)
-			req.AddCookie(cookie)
+			req.AddCookie(adminCookie)
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, test.expectedCode, w.Code)
@@ -274,44 +347,144 @@ func TestHandleDeleteAccount(t *testing.T) {
 	}
 }
 
+func TestHandleUpdateAccount(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	adminCookie := createAdminAuthCookie(store)
+	notifier := useCaptureNotifier(t)
+
+	update := func(cookie *http.Cookie, id string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSON(router, "PATCH", "/account/"+id, cookie, body)
+	}
+	str := func(s string) *string { return &s }
+
+	// Test 1: The owner changes a name; other fields stay
+	w := update(cookie, "7", &types.UpdateAccountRequest{FirstName: str("  Tester ")})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated types.AccountUpdateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "Tester", updated.FirstName)
+	assert.Equal(t, "Testovich", updated.LastName)
+	assert.Empty(t, updated.PendingEmail)
+
+	// Test 2: Invalid input, taken emails and a wrong password are refused
+	assert.Equal(t, http.StatusBadRequest, update(cookie, "7", &types.UpdateAccountRequest{}).Code)
+	assert.Equal(t, http.StatusBadRequest, update(cookie, "7", &types.UpdateAccountRequest{LastName: str(" ")}).Code)
+	assert.Equal(t, http.StatusBadRequest, update(cookie, "7", &types.UpdateAccountRequest{Email: str("Test <new@gmail.com>")}).Code)
+	assert.Equal(t, http.StatusConflict, update(cookie, "7", &types.UpdateAccountRequest{Email: str("admin@gmail.com"), CurrentPassword: "test"}).Code)
+	assert.Equal(t, http.StatusUnauthorized, update(cookie, "7", &types.UpdateAccountRequest{Email: str("renamed@gmail.com")}).Code)
+	assert.Equal(t, http.StatusUnauthorized, update(cookie, "7", &types.UpdateAccountRequest{Email: str("renamed@gmail.com"), CurrentPassword: "wrong"}).Code)
+	assert.Empty(t, notifier.notifications)
+
+	// Test 3: Other users' accounts are not found
+	admin, err := store.GetAccountByEmail(context.Background(), "admin@gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, update(cookie, fmt.Sprint(admin.ID), &types.UpdateAccountRequest{FirstName: str("Mallory")}).Code)
+
+	// Test 4: A new email waits until the link mailed to it is followed
+	w = update(cookie, "7", &types.UpdateAccountRequest{Email: str("renamed@gmail.com"), CurrentPassword: "test"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "testing@gmail.com", updated.Email)
+	assert.Equal(t, "renamed@gmail.com", updated.PendingEmail)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/expense", cookie).Code)
+
+	msg := notifier.last()
+	assert.Equal(t, notify.KindConfirmEmailChange, msg.Kind)
+	assert.Equal(t, "renamed@gmail.com", msg.To)
+	match := tokenParam.FindStringSubmatch(msg.Body)
+	assert.Len(t, match, 2)
+	confirm := "/account/email/confirm?token=" + match[1]
+
+	// Test 5: Only the account's own session can confirm, getting a new token
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", confirm, adminCookie).Code)
+	w = doWithCookie(router, "GET", confirm, cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "renamed@gmail.com", updated.Email)
+	assert.NotEmpty(t, updated.Token)
+	reissued := responseCookie(w, "token")
+	assert.NotNil(t, reissued)
+	assert.Equal(t, updated.Token, reissued.Value)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/expense", reissued).Code)
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", cookie).Code)
+	assert.Equal(t, http.StatusOK, postJSON(router, "/login", nil, &types.LoginRequest{Email: "renamed@gmail.com", Password: "test"}).Code)
+
+	// Test 6: Admins may change names, and emails without a password, without touching their own token
+	w = update(adminCookie, "7", &types.UpdateAccountRequest{LastName: str("Adminset")})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, responseCookie(w, "token"))
+	account, err := store.GetAccountById(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "Adminset", account.LastName)
+
+	w = update(adminCookie, "7", &types.UpdateAccountRequest{Email: str("admin-set@gmail.com")})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "renamed@gmail.com", updated.Email)
+	assert.Equal(t, "admin-set@gmail.com", updated.PendingEmail)
+	assert.Equal(t, "admin-set@gmail.com", notifier.last().To)
+	adminSet := "/account/email/confirm?token=" + tokenParam.FindStringSubmatch(notifier.last().Body)[1]
+
+	// Test 7: Links work once, and a newer request cancels the ones before it
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", confirm, reissued).Code)
+	w = update(reissued, "7", &types.UpdateAccountRequest{Email: str("latest@gmail.com"), CurrentPassword: "test"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", adminSet, reissued).Code)
+	latest := "/account/email/confirm?token=" + tokenParam.FindStringSubmatch(notifier.last().Body)[1]
+	w = doWithCookie(router, "GET", latest, reissued)
+	assert.Equal(t, http.StatusOK, w.Code)
+	reissued = responseCookie(w, "token")
+	account, err = store.GetAccountById(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "latest@gmail.com", account.Email)
+
+	// Test 8: Nothing is saved when the confirmation cannot be sent
+	services.SetNotifier(failingNotifier{})
+	w = update(reissued, "7", &types.UpdateAccountRequest{FirstName: str("Unsent"), Email: str("unsent@gmail.com"), CurrentPassword: "test"})
+	services.SetNotifier(notifier)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	account, err = store.GetAccountById(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "Tester", account.FirstName)
+
+	// Test 9: API keys cannot change the account at all
+	w = createApiKey(t, router, reissued, &types.CreateApiKeyRequest{Name: "script", Scopes: []string{types.ScopeAccountsWrite}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var key types.CreateApiKeyResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &key))
+	w = withBearer(router, "PATCH", "/account/7", key.Key, &types.UpdateAccountRequest{Email: str("thief@gmail.com"), CurrentPassword: "test"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
 func TestHandleGetAllExpense(t *testing.T) {
-	router, _ := InitializeTestServer()
-	cookie, _ := createMockAuthCookie()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	adminCookie := createAdminAuthCookie(store)
 
+	// Test 1: Regular users may not list every expense
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/expenses", nil)
 	req.AddCookie(cookie)
 	router.ServeHTTP(w, req)
 
+	assert.Equal(t, http.StatusForbidden, w.Code, "Expected status code 403")
+
+	// Test 2: Admin request
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/expenses", nil)
+	req.AddCookie(adminCookie)
+	router.ServeHTTP(w, req)
+
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected Status.OK and expenses data; got %v", w.Code)
 	}
 }
 
 func TestHandleGetExpenseForUser(t *testing.T) {
-	router, _ := InitializeTestServer()
-
-	mockAccount := &types.Account{
-		ID:        7,
-		FirstName: "Test",
-		LastName:  "Testovich",
-		Email:     "testing@gmail.com",
-		Password:  "$2a$10$q/cjukk2QtKtTdcaype0UOgPydr5MRcQm9wmbpfvyDksUuuv2gomu",
-		Status:    "Active",
-		Number:    112302,
-		Balance:   0,
-		CreatedAt: time.Now(),
-	}
+	router, store := InitializeTestServer()
 
-	tokenString, err := services.CreateJWT(mockAccount)
-	if err != nil {
-		log.Fatalf("Failed to generate token: %v", err)
-	}
-
-	cookie := &http.Cookie{
-		Name:  "token",
-		Value: tokenString,
-	}
+	cookie, _ := createMockAuthCookie(store)
 
 	// Test 1: Not authorized request
 
@@ -328,9 +501,10 @@ func TestHandleGetExpenseForUser(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code, "Expected status code 200 and expense data for the account")
 
-	var response []types.Expense
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	var response types.ExpensePage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err, "Expected no error unmarshalling response")
+	assert.NotNil(t, response.Expenses)
 
 	// Test 3: Invalid request
 	testInvalidId := "test"
@@ -343,17 +517,17 @@ func TestHandleGetExpenseForUser(t *testing.T) {
 }
 
 func TestHandleCreateExpense(t *testing.T) {
-	router, _ := InitializeTestServer()
+	router, store := InitializeTestServer()
 
 	expenseData := &types.CreateExpenseRequest{
 		ExpenseName:     "test",
 		ExpensePurpose:  "test",
 		ExpenseCategory: "test",
-		ExpenseValue:    100,
+		ExpenseValue:    types.NewMoney(10000, types.DefaultCurrency),
 		CreatedAt:       time.Now(),
 	}
 
-	cookie, _ := createMockAuthCookie()
+	cookie, _ := createMockAuthCookie(store)
 
 	body, err := json.Marshal(expenseData)
 	if err != nil {
@@ -386,15 +560,14 @@ func TestHandleCreateExpense(t *testing.T) {
 func TestHandleUpdateExpense(t *testing.T) {
 	ctx := context.Background()
 	router, store := InitializeTestServer()
-	cookie, _ := createMockAuthCookie()
+	cookie, _ := createMockAuthCookie(store)
 	newExpenseData := &types.Expense{
-		UserId:          7,
-		ExpenseName:     "test",
-		ExpensePurpose:  "test",
-		ExpenseCategory: "test",
-		ExpenseValue:    100,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
+		UserId:         7,
+		ExpenseName:    "test",
+		ExpensePurpose: "test",
+		ExpenseValue:   types.NewMoney(10000, types.DefaultCurrency),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	newExp, err := store.CreateExpense(ctx, newExpenseData)
@@ -411,7 +584,7 @@ func TestHandleUpdateExpense(t *testing.T) {
 		ExpenseName:     "edited",
 		ExpensePurpose:  "edited",
 		ExpenseCategory: "edited",
-		ExpenseValue:    200,
+		ExpenseValue:    types.NewMoney(20000, types.DefaultCurrency),
 		CreatedAt:       time.Now(),
 	}
 
@@ -447,9 +620,9 @@ func TestHandleUpdateExpense(t *testing.T) {
 func TestHandleDeleteExpense(t *testing.T) {
 	ctx := context.Background()
 	router, store := InitializeTestServer()
-	cookie, _ := createMockAuthCookie()
+	cookie, _ := createMockAuthCookie(store)
 
-	testExpense, err := types.NewExpense(7, "test", "test", "test", 100, time.Now())
+	testExpense, err := types.NewExpense(7, "test", "test", nil, types.NewMoney(10000, types.DefaultCurrency), time.Now())
 	assert.NoError(t, err, "Expected no error creating new expense")
 
 	newExpense, newErr := store.CreateExpense(ctx, testExpense)
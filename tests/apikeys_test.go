@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func withBearer(router *gin.Engine, method, path, credential string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+credential)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func createApiKey(t *testing.T, router *gin.Engine, cookie *http.Cookie, req *types.CreateApiKeyRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r, _ := http.NewRequest("POST", "/apikeys", bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestBearerToken(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	assert.Equal(t, http.StatusOK, withBearer(router, "GET", "/expense", cookie.Value, nil).Code)
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "GET", "/expense", "not-a-token", nil).Code)
+
+	req, _ := http.NewRequest("GET", "/expense", nil)
+	req.Header.Set("Authorization", "Basic "+cookie.Value)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestApiKeys(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	// Test 1: Invalid requests
+	for _, req := range []*types.CreateApiKeyRequest{
+		{Name: "", Scopes: []string{types.ScopeExpensesRead}},
+		{Name: "script", Scopes: nil},
+		{Name: "script", Scopes: []string{"everything"}},
+	} {
+		assert.Equal(t, http.StatusBadRequest, createApiKey(t, router, cookie, req).Code)
+	}
+
+	// Test 2: A read-only key
+	w := createApiKey(t, router, cookie, &types.CreateApiKeyRequest{Name: "script", Scopes: []string{types.ScopeExpensesRead}})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created types.CreateApiKeyResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Contains(t, created.Key, "gbk_")
+	assert.Equal(t, created.Key[:len(created.Prefix)], created.Prefix)
+
+	assert.Equal(t, http.StatusOK, withBearer(router, "GET", "/expense", created.Key, nil).Code)
+
+	expense := &types.CreateExpenseRequest{ExpenseName: "key", ExpenseValue: eur(5)}
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "POST", "/expense", created.Key, expense).Code)
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "GET", "/apikeys", created.Key, nil).Code)
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "GET", "/sessions", created.Key, nil).Code)
+
+	// Test 3: Listing never shows the key itself
+	req, _ := http.NewRequest("GET", "/apikeys", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key)
+	assert.NotContains(t, w.Body.String(), "key_hash")
+
+	var listed []types.ApiKey
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed, 1)
+	assert.NotNil(t, listed[0].LastUsedAt)
+
+	// Test 4: Other users cannot revoke the key
+	adminCookie := createAdminAuthCookie(store)
+	w = doWithCookie(router, "DELETE", fmt.Sprintf("/apikeys/%d", created.ID), adminCookie)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test 5: A revoked key is refused
+	w = doWithCookie(router, "DELETE", fmt.Sprintf("/apikeys/%d", created.ID), cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "GET", "/expense", created.Key, nil).Code)
+}
+
+func TestApiKeyExpiry(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	past := time.Now().Add(-time.Hour)
+	w := createApiKey(t, router, cookie, &types.CreateApiKeyRequest{Name: "old", Scopes: []string{types.ScopeExpensesRead}, ExpiresAt: &past})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	soon := time.Now().Add(time.Hour)
+	w = createApiKey(t, router, cookie, &types.CreateApiKeyRequest{Name: "soon", Scopes: []string{types.ScopeExpensesRead}, ExpiresAt: &soon})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created types.CreateApiKeyResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, http.StatusOK, withBearer(router, "GET", "/expense", created.Key, nil).Code)
+
+	// A key past its expiry is refused
+	plain := "gbk_expired-test-key"
+	sum := sha256.Sum256([]byte(plain))
+	assert.NoError(t, store.CreateApiKey(context.Background(), &types.ApiKey{
+		AccountId: 7,
+		Name:      "expired",
+		Prefix:    plain[:12],
+		KeyHash:   hex.EncodeToString(sum[:]),
+		Scopes:    []string{types.ScopeExpensesRead},
+		CreatedAt: past.Add(-time.Hour),
+		ExpiresAt: &past,
+	}))
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "GET", "/expense", plain, nil).Code)
+}
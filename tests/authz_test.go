@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOtherUsersResourcesAreNotFound(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	_, other := createFundedAccount(t, store, 500500, 0)
+
+	foreign, err := store.CreateExpense(ctx, &types.Expense{
+		UserId:       other.ID,
+		ExpenseName:  "theirs",
+		ExpenseValue: types.NewMoney(500, types.DefaultCurrency),
+		CreatedAt:    time.Now(),
+	})
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(&types.UpdateExpenseRequest{ExpenseName: "mine now", ExpenseValue: types.NewMoney(1, types.DefaultCurrency)})
+	tests := []struct {
+		description  string
+		method       string
+		path         string
+		body         []byte
+		expectedCode int
+	}{
+		{"Update another user's expense", "POST", fmt.Sprintf("/expense/%d", foreign.ID), body, http.StatusNotFound},
+		{"Delete another user's expense", "DELETE", fmt.Sprintf("/expense/%d", foreign.ID), nil, http.StatusNotFound},
+		{"Read another user's account", "GET", fmt.Sprintf("/account/%d", other.ID), nil, http.StatusNotFound},
+		{"Delete another user's account", "DELETE", fmt.Sprintf("/account/%d", other.ID), nil, http.StatusForbidden},
+		{"List another user's transactions", "GET", fmt.Sprintf("/account/%d/transactions", other.ID), nil, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, test.path, bytes.NewBuffer(test.body))
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedCode, w.Code)
+		})
+	}
+
+	stored, err := store.GetExpenseById(ctx, foreign.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, other.ID, stored.UserId, "Expense must keep its owner")
+	assert.Equal(t, "theirs", stored.ExpenseName)
+
+	acc, err := store.GetAccountById(ctx, other.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Active", acc.Status)
+}
+
+func TestAdminCanReachOtherUsersResources(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	adminCookie := createAdminAuthCookie(store)
+	mockAccount := newMockAccount()
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/account/%d", mockAccount.ID), nil)
+	req.AddCookie(adminCookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Demoting the admin takes effect without a new token
+	admin, err := store.GetAccountByEmail(ctx, "admin@gmail.com")
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetAccountRole(ctx, admin.ID, types.RoleUser))
+
+	req, _ = http.NewRequest("GET", "/accounts", nil)
+	req.AddCookie(adminCookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/budget"
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestBudgets(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	food := createCategory(t, store, 7, "Food", nil)
+	travel := createCategory(t, store, 7, "Travel", nil)
+	foreign := createCategory(t, store, 1, "Theirs", nil)
+
+	create := func(req *types.BudgetRequest) (int, *types.Budget) {
+		w := postJSON(router, "/budgets", cookie, req)
+		created := new(types.Budget)
+		if w.Code == http.StatusCreated {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), created))
+		}
+		return w.Code, created
+	}
+
+	// Test 1: A category gets one budget with a positive limit
+	code, mine := create(&types.BudgetRequest{CategoryId: food.ID, Limit: eur(300)})
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, 7, mine.UserId)
+	assert.Equal(t, eur(300), mine.Limit)
+
+	code, _ = create(&types.BudgetRequest{CategoryId: food.ID, Limit: eur(100)})
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = create(&types.BudgetRequest{CategoryId: foreign.ID, Limit: eur(100)})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = create(&types.BudgetRequest{CategoryId: travel.ID, Limit: eur(0)})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Test 2: The limit and rollover change, the category does not
+	path := fmt.Sprintf("/budgets/%d", mine.ID)
+	w := sendJSON(router, "PUT", path, cookie, &types.BudgetRequest{Limit: eur(250), Rollover: true})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = sendJSON(router, "PUT", path, cookie, &types.BudgetRequest{CategoryId: travel.ID, Limit: eur(250)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doWithCookie(router, "GET", path, cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	updated := new(types.Budget)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), updated))
+	assert.Equal(t, eur(250), updated.Limit)
+	assert.True(t, updated.Rollover)
+	assert.Equal(t, food.ID, updated.CategoryId)
+
+	// Test 3: Other users' budgets are invisible
+	theirs, err := types.NewBudget(1, &types.BudgetRequest{CategoryId: foreign.ID, Limit: eur(10)})
+	assert.NoError(t, err)
+	theirs, err = store.CreateBudget(context.Background(), theirs)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, doWithCookie(router, "GET", fmt.Sprintf("/budgets/%d", theirs.ID), cookie).Code)
+	assert.Equal(t, http.StatusNotFound, doWithCookie(router, "DELETE", fmt.Sprintf("/budgets/%d", theirs.ID), cookie).Code)
+
+	w = doWithCookie(router, "GET", "/budgets", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed []*types.Budget
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed, 1)
+
+	// Test 4: Deleting a budget, or its category, removes it
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "DELETE", path, cookie).Code)
+	assert.Equal(t, http.StatusNotFound, doWithCookie(router, "GET", path, cookie).Code)
+
+	code, mine = create(&types.BudgetRequest{CategoryId: travel.ID, Limit: eur(100)})
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "DELETE", fmt.Sprintf("/categories/%d", travel.ID), cookie).Code)
+	assert.Equal(t, http.StatusNotFound, doWithCookie(router, "GET", fmt.Sprintf("/budgets/%d", mine.ID), cookie).Code)
+}
+
+func TestBudgetStatus(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	food := createCategory(t, store, 7, "Food", nil)
+	groceries := createCategory(t, store, 7, "Groceries", &food.ID)
+	travel := createCategory(t, store, 7, "Travel", nil)
+
+	spend := func(categoryId int, amount int64, on time.Time) {
+		w := postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{
+			ExpenseName: "Spending", CategoryId: &categoryId, ExpenseValue: eur(amount), CreatedAt: on,
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		// Alerts go out in the background; later tests must not see them.
+		budget.WaitForThresholdChecks()
+	}
+	status := func(month string) map[int]*types.BudgetStatus {
+		w := doWithCookie(router, "GET", "/budgets/status?month="+month, cookie)
+		assert.Equal(t, http.StatusOK, w.Code, month)
+		var response types.BudgetStatusResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, month, response.Month)
+		byCategory := map[int]*types.BudgetStatus{}
+		for _, s := range response.Budgets {
+			byCategory[s.CategoryId] = s
+		}
+		return byCategory
+	}
+
+	// The food budget carries unspent money over from January on
+	rollover, err := types.NewBudget(7, &types.BudgetRequest{CategoryId: food.ID, Limit: eur(200), Rollover: true})
+	assert.NoError(t, err)
+	rollover.CreatedAt = time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)
+	_, err = store.CreateBudget(ctx, rollover)
+	assert.NoError(t, err)
+
+	w := postJSON(router, "/budgets", cookie, &types.BudgetRequest{CategoryId: travel.ID, Limit: eur(100)})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	spend(food.ID, 150, time.Date(2024, time.January, 5, 12, 0, 0, 0, time.UTC))
+	spend(groceries.ID, 280, time.Date(2024, time.February, 3, 12, 0, 0, 0, time.UTC))
+	spend(groceries.ID, 60, time.Date(2024, time.March, 3, 12, 0, 0, 0, time.UTC))
+	spend(travel.ID, 120, time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC))
+
+	// Test 1: Spending in subcategories counts against the budget
+	jan := status("2024-01")
+	assert.Equal(t, eur(150), jan[food.ID].Spent)
+	assert.Equal(t, eur(0), jan[food.ID].RolledOver)
+	assert.Equal(t, eur(50), jan[food.ID].Remaining)
+	assert.Equal(t, 75.0, jan[food.ID].Percent)
+	assert.Equal(t, types.BudgetOk, jan[food.ID].State)
+	assert.Equal(t, "Food", jan[food.ID].Category)
+
+	// Test 2: Unspent money rolls over, overspending does not
+	feb := status("2024-02")
+	assert.Equal(t, eur(50), feb[food.ID].RolledOver)
+	assert.Equal(t, eur(250), feb[food.ID].Available)
+	assert.Equal(t, eur(280), feb[food.ID].Spent)
+	assert.Equal(t, eur(-30), feb[food.ID].Remaining)
+	assert.Equal(t, 112.0, feb[food.ID].Percent)
+	assert.Equal(t, types.BudgetExceeded, feb[food.ID].State)
+
+	mar := status("2024-03")
+	assert.Equal(t, eur(0), mar[food.ID].RolledOver)
+	assert.Equal(t, 30.0, mar[food.ID].Percent)
+
+	// Test 3: Budgets without rollover only see their own month
+	assert.Equal(t, eur(0), mar[travel.ID].RolledOver)
+	assert.Equal(t, eur(120), mar[travel.ID].Spent)
+	assert.Equal(t, types.BudgetExceeded, mar[travel.ID].State)
+	assert.Equal(t, eur(0), feb[travel.ID].Spent)
+
+	// Test 4: A new limit applies from its month on, earlier months keep theirs
+	changed, err := store.GetBudgetById(ctx, rollover.ID)
+	assert.NoError(t, err)
+	changed.Limit = eur(100)
+	changed.UpdatedAt = time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.UpdateBudget(ctx, changed))
+
+	feb = status("2024-02")
+	assert.Equal(t, eur(200), feb[food.ID].Limit)
+	assert.Equal(t, eur(50), feb[food.ID].RolledOver)
+	mar = status("2024-03")
+	assert.Equal(t, eur(100), mar[food.ID].Limit)
+	assert.Equal(t, 60.0, mar[food.ID].Percent)
+	apr := status("2024-04")
+	assert.Equal(t, eur(40), apr[food.ID].RolledOver)
+	assert.Equal(t, eur(140), apr[food.ID].Available)
+
+	// Test 5: The currency of a budget cannot change
+	w = sendJSON(router, "PUT", fmt.Sprintf("/budgets/%d", rollover.ID), cookie, &types.BudgetRequest{Limit: types.NewMoney(100, "USD"), Rollover: true})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test 6: Invalid months are rejected
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", "/budgets/status?month=2024-13", cookie).Code)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/budgets/status", cookie).Code)
+}
+
+func TestBudgetNotifications(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	notifier := useCaptureNotifier(t)
+	food := createCategory(t, store, 7, "Food", nil)
+	groceries := createCategory(t, store, 7, "Groceries", &food.ID)
+	month := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	w := postJSON(router, "/budgets", cookie, &types.BudgetRequest{CategoryId: food.ID, Limit: eur(100)})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	spend := func(categoryId *int, amount int64, day int) *types.Expense {
+		w := postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{
+			ExpenseName: "Spending", CategoryId: categoryId, ExpenseValue: eur(amount), CreatedAt: month.AddDate(0, 0, day),
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		budget.WaitForThresholdChecks()
+		exp := new(types.Expense)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), exp))
+		return exp
+	}
+	sent := func() []*notify.Notification {
+		notifier.mu.Lock()
+		defer notifier.mu.Unlock()
+		return append([]*notify.Notification(nil), notifier.notifications...)
+	}
+
+	// Test 1: Nothing is sent below 80%, or for uncategorized spending
+	spend(&groceries.ID, 70, 0)
+	spend(nil, 500, 1)
+	assert.Empty(t, sent())
+
+	// Test 2: Reaching 80% warns once
+	spend(&groceries.ID, 15, 2)
+	assert.Len(t, sent(), 1)
+	msg := notifier.last()
+	assert.Equal(t, notify.KindBudgetThreshold, msg.Kind)
+	assert.Equal(t, "testing@gmail.com", msg.To)
+	assert.Equal(t, "80", msg.Data["threshold"])
+	assert.Equal(t, "2024-05", msg.Data["month"])
+	assert.Equal(t, "Food", msg.Data["category"])
+
+	last := spend(&food.ID, 5, 3)
+	assert.Len(t, sent(), 1)
+
+	// Test 3: Exceeding the limit, here by editing an expense, notifies again
+	categoryId := food.ID
+	w = postJSON(router, fmt.Sprintf("/expense/%d", last.ID), cookie, &types.UpdateExpenseRequest{
+		ExpenseName: last.ExpenseName, CategoryId: &categoryId, ExpenseValue: eur(20), CreatedAt: last.CreatedAt,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	budget.WaitForThresholdChecks()
+	assert.Len(t, sent(), 2)
+	assert.Equal(t, "100", notifier.last().Data["threshold"])
+
+	spend(&food.ID, 50, 4)
+	assert.Len(t, sent(), 2)
+
+	// Test 4: A new month starts over, jumping straight to the highest threshold
+	month = month.AddDate(0, 1, 0)
+	spend(&groceries.ID, 120, 0)
+	assert.Len(t, sent(), 3)
+	assert.Equal(t, "100", notifier.last().Data["threshold"])
+	assert.Equal(t, "2024-06", notifier.last().Data["month"])
+
+	// Test 5: An alert that could not be sent goes out with the next expense
+	month = month.AddDate(0, 1, 0)
+	services.SetNotifier(failingNotifier{})
+	spend(&groceries.ID, 90, 0)
+	services.SetNotifier(notifier)
+	assert.Len(t, sent(), 3)
+
+	spend(&groceries.ID, 1, 1)
+	assert.Len(t, sent(), 4)
+	assert.Equal(t, "80", notifier.last().Data["threshold"])
+	assert.Equal(t, "2024-07", notifier.last().Data["month"])
+}
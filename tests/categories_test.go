@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func createCategory(t *testing.T, store storage.Storage, userId int, name string, parentId *int) *types.Category {
+	category, err := types.NewCategory(userId, &types.CategoryRequest{Name: name, ParentId: parentId})
+	assert.NoError(t, err)
+	category, err = store.CreateCategory(context.Background(), category)
+	assert.NoError(t, err)
+	return category
+}
+
+func TestCategories(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+	foreign := createCategory(t, store, 1, "Theirs", nil)
+
+	create := func(req *types.CategoryRequest) (int, *types.Category) {
+		w := postJSON(router, "/categories", cookie, req)
+		category := new(types.Category)
+		if w.Code == http.StatusCreated {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), category))
+		}
+		return w.Code, category
+	}
+	update := func(id int, req *types.CategoryRequest) int {
+		return sendJSON(router, "PUT", fmt.Sprintf("/categories/%d", id), cookie, req).Code
+	}
+
+	// Test 1: Categories nest and carry a color and an icon
+	code, food := create(&types.CategoryRequest{Name: " Food ", Color: "#1E90FF", Icon: "shopping-cart"})
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "Food", food.Name)
+	assert.Equal(t, "#1e90ff", food.Color)
+	assert.Equal(t, 7, food.UserId)
+
+	code, groceries := create(&types.CategoryRequest{Name: "Groceries", ParentId: &food.ID})
+	assert.Equal(t, http.StatusCreated, code)
+	code, fruit := create(&types.CategoryRequest{Name: "Fruit", ParentId: &groceries.ID})
+	assert.Equal(t, http.StatusCreated, code)
+
+	// Test 2: Names are unique among siblings only, ignoring case
+	code, _ = create(&types.CategoryRequest{Name: "FOOD"})
+	assert.Equal(t, http.StatusConflict, code)
+	code, _ = create(&types.CategoryRequest{Name: "Food", ParentId: &groceries.ID})
+	assert.Equal(t, http.StatusCreated, code)
+
+	// Test 3: Invalid fields and foreign parents are rejected
+	for _, bad := range []*types.CategoryRequest{
+		{Name: ""},
+		{Name: "Colorful", Color: "blue"},
+		{Name: "Iconic", Icon: "Not An Icon"},
+		{Name: "Adopted", ParentId: &foreign.ID},
+	} {
+		code, _ = create(bad)
+		assert.Equal(t, http.StatusBadRequest, code, bad.Name)
+	}
+
+	// Test 4: Other users' categories are invisible
+	assert.Equal(t, http.StatusNotFound, doWithCookie(router, "GET", fmt.Sprintf("/categories/%d", foreign.ID), cookie).Code)
+	assert.Equal(t, http.StatusNotFound, update(foreign.ID, &types.CategoryRequest{Name: "Mine"}))
+
+	w := doWithCookie(router, "GET", "/categories", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed []*types.Category
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed, 4)
+
+	// Test 5: A category cannot move below itself
+	assert.Equal(t, http.StatusBadRequest, update(food.ID, &types.CategoryRequest{Name: "Food", ParentId: &fruit.ID}))
+	assert.Equal(t, http.StatusBadRequest, update(food.ID, &types.CategoryRequest{Name: "Food", ParentId: &food.ID}))
+	assert.Equal(t, http.StatusOK, update(fruit.ID, &types.CategoryRequest{Name: "Fruit & Veg", ParentId: &food.ID, Icon: "apple"}))
+
+	updated, err := store.GetCategoryById(ctx, fruit.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Fruit & Veg", updated.Name)
+	assert.Equal(t, food.ID, *updated.ParentId)
+
+	// Test 6: Expenses are filed by id, or by name into a top-level category
+	w = postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{ExpenseName: "apples", CategoryId: &groceries.ID, CreatedAt: time.Now()})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var apples types.Expense
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apples))
+	assert.Equal(t, groceries.ID, *apples.CategoryId)
+
+	w = postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{ExpenseName: "bread", ExpenseCategory: "food", CreatedAt: time.Now()})
+	var bread types.Expense
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bread))
+	assert.Equal(t, food.ID, *bread.CategoryId)
+
+	w = postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{ExpenseName: "fuel", ExpenseCategory: "Car", CreatedAt: time.Now()})
+	var fuel types.Expense
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fuel))
+	car, err := store.GetCategoryById(ctx, *fuel.CategoryId)
+	assert.NoError(t, err)
+	assert.Equal(t, "Car", car.Name)
+	assert.Nil(t, car.ParentId)
+
+	w = postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{ExpenseName: "stolen", CategoryId: &foreign.ID, CreatedAt: time.Now()})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test 7: Deleting a category moves its children and expenses up
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "DELETE", fmt.Sprintf("/categories/%d", groceries.ID), cookie).Code)
+	assert.Equal(t, http.StatusNotFound, doWithCookie(router, "GET", fmt.Sprintf("/categories/%d", groceries.ID), cookie).Code)
+
+	moved, err := store.GetExpenseById(ctx, apples.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, food.ID, *moved.CategoryId)
+
+	// The second Food now sits beside Fruit & Veg, below the first.
+	categories, err := store.GetCategoriesForUser(ctx, 7)
+	assert.NoError(t, err)
+	for _, category := range categories {
+		if category.ID != food.ID && category.ID != car.ID {
+			assert.Equal(t, food.ID, *category.ParentId, category.Name)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/exchange"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRatesCSV = `base,quote,date,rate
+USD,EUR,2024-01-01,0.90
+USD,EUR,2024-02-01,0.92
+EUR,GBP,2024-01-01,0.85
+`
+
+func TestParseRates(t *testing.T) {
+	rates, err := exchange.ParseCSV(strings.NewReader(testRatesCSV))
+	assert.NoError(t, err)
+	assert.Len(t, rates, 3)
+	assert.Equal(t, "USD", rates[0].Base)
+	assert.Equal(t, "0.92", rates[1].Rate)
+
+	rates, err = exchange.ParseJSON(strings.NewReader(`[{"base":"gbp","quote":"eur","date":"2024-03-01","rate":"1.17"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "GBP", rates[0].Base)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), rates[0].ValidFrom)
+
+	_, err = exchange.ParseCSV(strings.NewReader("USD,EUR,2024-01-01,-1\n"))
+	assert.Error(t, err)
+	_, err = exchange.ParseJSON(strings.NewReader(`[{"base":"USD","quote":"EUR","date":"01/02/2024","rate":"1"}]`))
+	assert.Error(t, err)
+}
+
+func TestMoneyConvert(t *testing.T) {
+	converted, err := types.NewMoney(1005, "USD").Convert(big.NewRat(9, 10), "EUR")
+	assert.NoError(t, err)
+	// 10.05 * 0.9 = 9.045, rounded half away from zero
+	assert.Equal(t, types.NewMoney(905, "EUR"), converted)
+
+	converted, err = types.NewMoney(1000, "EUR").Convert(big.NewRat(16250, 100), "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewMoney(1625, "JPY"), converted)
+}
+
+func TestConverterUsesRateValidOnDate(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	rates, err := exchange.ParseCSV(strings.NewReader(testRatesCSV))
+	assert.NoError(t, err)
+	assert.NoError(t, store.SaveExchangeRates(ctx, rates))
+
+	converter := exchange.NewConverter(store)
+	hundredDollars := types.NewMoney(10000, "USD")
+
+	january, err := converter.Convert(ctx, hundredDollars, "EUR", time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewMoney(9000, "EUR"), january)
+
+	february, err := converter.Convert(ctx, hundredDollars, "EUR", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewMoney(9200, "EUR"), february)
+
+	// Only EUR/GBP is stored, so GBP/EUR uses its inverse.
+	pounds, err := converter.Convert(ctx, types.NewMoney(8500, "GBP"), "EUR", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, types.NewMoney(10000, "EUR"), pounds)
+
+	_, err = converter.Convert(ctx, hundredDollars, "EUR", time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, storage.ErrRateNotFound)
+}
+
+// brokenInverseStore fails every lookup of the quote/base pair.
+type brokenInverseStore struct {
+	storage.Storage
+	quote, base string
+}
+
+var errRatesUnavailable = errors.New("rates unavailable")
+
+func (s brokenInverseStore) GetExchangeRate(ctx context.Context, base, quote string, on time.Time) (*types.ExchangeRate, error) {
+	if base == s.quote && quote == s.base {
+		return nil, errRatesUnavailable
+	}
+	return s.Storage.GetExchangeRate(ctx, base, quote, on)
+}
+
+func TestConverterReportsInverseLookupErrors(t *testing.T) {
+	ctx := context.Background()
+	store := brokenInverseStore{Storage: storage.NewMemoryStore(), base: "GBP", quote: "EUR"}
+
+	// The direct pair is missing, so the failing inverse lookup decides.
+	_, err := exchange.NewConverter(store).Rate(ctx, "GBP", "EUR", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, errRatesUnavailable)
+	assert.NotErrorIs(t, err, storage.ErrRateNotFound)
+}
+
+func TestExpenseReports(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	rates, err := exchange.ParseCSV(strings.NewReader(testRatesCSV))
+	assert.NoError(t, err)
+	assert.NoError(t, store.SaveExchangeRates(ctx, rates))
+
+	food := createCategory(t, store, 7, "food", nil)
+	travel := createCategory(t, store, 7, "travel", nil)
+	for _, exp := range []*types.Expense{
+		{UserId: 7, CategoryId: &food.ID, ExpenseValue: types.NewMoney(1000, "EUR"), CreatedAt: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)},
+		{UserId: 7, CategoryId: &food.ID, ExpenseValue: types.NewMoney(1000, "USD"), CreatedAt: time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)},
+		{UserId: 7, CategoryId: &travel.ID, ExpenseValue: types.NewMoney(5000, "USD"), CreatedAt: time.Date(2024, 2, 5, 12, 0, 0, 0, time.UTC)},
+	} {
+		_, err := store.CreateExpense(ctx, exp)
+		assert.NoError(t, err)
+	}
+
+	r, _ := http.NewRequest("GET", "/reports/expenses/categories", nil)
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report types.ExpenseReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "EUR", report.Currency)
+	assert.Equal(t, 3, report.Count)
+	// 10.00 + 10.00 * 0.90 + 50.00 * 0.92
+	assert.Equal(t, types.NewMoney(6500, "EUR"), report.Total)
+	assert.Len(t, report.Categories, 2)
+	assert.Equal(t, "food", report.Categories[0].Category)
+	assert.Equal(t, food.ID, *report.Categories[0].CategoryId)
+	assert.Equal(t, types.NewMoney(1900, "EUR"), report.Categories[0].Total)
+
+	r, _ = http.NewRequest("GET", "/reports/expenses/monthly?from=2024-01-01&to=2024-01-31", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	report = types.ExpenseReport{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Count)
+	assert.Len(t, report.Months, 1)
+	assert.Equal(t, "2024-01", report.Months[0].Month)
+
+	// An expense without a usable rate cannot be reported on
+	_, err = store.CreateExpense(ctx, &types.Expense{UserId: 7, ExpenseValue: types.NewMoney(100, "CHF"), CreatedAt: time.Now()})
+	assert.NoError(t, err)
+	r, _ = http.NewRequest("GET", "/reports/expenses/monthly", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestExpenseListing(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	food := createCategory(t, store, 7, "food", nil)
+	lunch := createCategory(t, store, 7, "lunch", &food.ID)
+	travel := createCategory(t, store, 7, "travel", nil)
+	other := createCategory(t, store, 1, "food", nil)
+
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	for _, exp := range []*types.Expense{
+		{UserId: 7, ExpenseName: "a", CategoryId: &lunch.ID, ExpensePurpose: "lunch", ExpenseValue: types.NewMoney(1200, "EUR"), CreatedAt: day(1)},
+		{UserId: 7, ExpenseName: "b", CategoryId: &food.ID, ExpensePurpose: "dinner", ExpenseValue: types.NewMoney(3500, "EUR"), CreatedAt: day(2)},
+		{UserId: 7, ExpenseName: "c", CategoryId: &travel.ID, ExpensePurpose: "train", ExpenseValue: types.NewMoney(3500, "EUR"), CreatedAt: day(2)},
+		{UserId: 7, ExpenseName: "d", CategoryId: &travel.ID, ExpensePurpose: "taxi", ExpenseValue: types.NewMoney(800, "USD"), CreatedAt: day(5)},
+		{UserId: 7, ExpenseName: "e", CategoryId: &lunch.ID, ExpensePurpose: "lunch", ExpenseValue: types.NewMoney(900, "EUR"), CreatedAt: day(9)},
+		{UserId: 1, ExpenseName: "other", CategoryId: &other.ID, ExpenseValue: types.NewMoney(100, "EUR"), CreatedAt: day(3)},
+	} {
+		_, err := store.CreateExpense(ctx, exp)
+		assert.NoError(t, err)
+	}
+
+	list := func(query string) (int, *types.ExpensePage) {
+		w := doWithCookie(router, "GET", "/expense"+query, cookie)
+		page := new(types.ExpensePage)
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), page))
+		}
+		return w.Code, page
+	}
+	names := func(page *types.ExpensePage) []string {
+		var names []string
+		for _, exp := range page.Expenses {
+			names = append(names, exp.ExpenseName)
+		}
+		return names
+	}
+
+	// Test 1: Newest first by default, only the caller's expenses
+	code, page := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"e", "d", "c", "b", "a"}, names(page))
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.NextCursor)
+
+	// Test 2: Following the cursor visits every expense once
+	var walked []string
+	query := "?limit=2&sort=value&currency=eur"
+	for pages := 0; ; pages++ {
+		code, page = list(query)
+		assert.Equal(t, http.StatusOK, code)
+		assert.LessOrEqual(t, len(page.Expenses), 2)
+		walked = append(walked, names(page)...)
+		if !page.HasMore {
+			assert.Equal(t, 1, pages)
+			break
+		}
+		query = "?limit=2&sort=value&currency=eur&cursor=" + page.NextCursor
+	}
+	// Equal values keep id order.
+	assert.Equal(t, []string{"e", "a", "b", "c"}, walked)
+
+	// Test 3: Filters combine
+	_, page = list(fmt.Sprintf("?category_id=%d&purpose=lunch", food.ID))
+	assert.Equal(t, []string{"e", "a"}, names(page))
+
+	// A category takes in its subcategories.
+	_, page = list(fmt.Sprintf("?category_id=%d", food.ID))
+	assert.Equal(t, []string{"e", "b", "a"}, names(page))
+
+	_, page = list(fmt.Sprintf("?category_id=%d", lunch.ID))
+	assert.Equal(t, []string{"e", "a"}, names(page))
+
+	_, page = list("?from=2024-03-02&to=2024-03-05&sort=date")
+	assert.Equal(t, []string{"b", "c", "d"}, names(page))
+
+	_, page = list("?min_value=10&max_value=35.00&currency=EUR&sort=-value")
+	assert.Equal(t, []string{"c", "b", "a"}, names(page))
+
+	_, page = list("?min_value=5&currency=usd")
+	assert.Equal(t, []string{"d"}, names(page))
+
+	_, page = list("?currency=usd&sort=-value")
+	assert.Equal(t, []string{"d"}, names(page))
+
+	// Test 4: Bad parameters are rejected
+	for _, bad := range []string{"?sort=name", "?sort=value", "?sort=-value&min_value=5", "?currency=euro", "?limit=0", "?limit=101", "?from=03-2024", "?min_value=ten", "?cursor=garbage", "?category_id=x", fmt.Sprintf("?category_id=%d", other.ID)} {
+		code, _ = list(bad)
+		assert.Equal(t, http.StatusBadRequest, code, bad)
+	}
+
+	// A cursor only continues the sort it was made for.
+	_, page = list("?limit=1&sort=value&currency=eur")
+	code, _ = list("?sort=date&cursor=" + page.NextCursor)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestExpenseSearch(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	for _, exp := range []*types.Expense{
+		{UserId: 7, ExpenseName: "Coffee beans", ExpensePurpose: "kitchen", CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{UserId: 7, ExpenseName: "Train ticket", ExpensePurpose: "coffee tasting trip", CreatedAt: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{UserId: 7, ExpenseName: "Coffee-cups & coffee filters", ExpensePurpose: "office", CreatedAt: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{UserId: 7, ExpenseName: "Decoffeinated tea", ExpensePurpose: "office", CreatedAt: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{UserId: 1, ExpenseName: "Coffee", ExpensePurpose: "not yours", CreatedAt: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+	} {
+		_, err := store.CreateExpense(ctx, exp)
+		assert.NoError(t, err)
+	}
+
+	search := func(query string) (int, *types.ExpenseSearchResponse) {
+		w := doWithCookie(router, "GET", "/expense/search"+query, cookie)
+		response := new(types.ExpenseSearchResponse)
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+		}
+		return w.Code, response
+	}
+	names := func(response *types.ExpenseSearchResponse) []string {
+		var names []string
+		for _, result := range response.Results {
+			names = append(names, result.Expense.ExpenseName)
+		}
+		return names
+	}
+
+	// Test 1: Prefixes match whole words only, ranked by where and how often
+	code, response := search("?q=COF")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"Coffee-cups & coffee filters", "Coffee beans", "Train ticket"}, names(response))
+	assert.Greater(t, response.Results[0].Rank, response.Results[1].Rank)
+	assert.Greater(t, response.Results[1].Rank, response.Results[2].Rank)
+
+	// Test 2: Matched words are highlighted in the fields they appear in
+	assert.Equal(t, "<mark>Coffee</mark>-cups &amp; <mark>coffee</mark> filters", response.Results[0].Highlights["expense_name"])
+	assert.NotContains(t, response.Results[0].Highlights, "expense_purpose")
+	assert.Equal(t, "<mark>coffee</mark> tasting trip", response.Results[2].Highlights["expense_purpose"])
+	assert.NotContains(t, response.Results[2].Highlights, "expense_name")
+
+	// Test 3: Every word must match, in either field
+	_, response = search("?q=coffee+trip")
+	assert.Equal(t, []string{"Train ticket"}, names(response))
+
+	_, response = search("?q=office&limit=1")
+	assert.Equal(t, []string{"Decoffeinated tea"}, names(response))
+
+	// Test 4: Highlights escape the text around the marks
+	_, err := store.CreateExpense(ctx, &types.Expense{UserId: 7, ExpenseName: "<script>alert(1)</script> snacks", ExpensePurpose: "office"})
+	assert.NoError(t, err)
+	_, response = search("?q=snacks")
+	assert.Equal(t, []string{"<script>alert(1)</script> snacks"}, names(response))
+	assert.Equal(t, "&lt;script&gt;alert(1)&lt;/script&gt; <mark>snacks</mark>", response.Results[0].Highlights["expense_name"])
+
+	_, response = search("?q=script")
+	assert.Equal(t, "&lt;<mark>script</mark>&gt;alert(1)&lt;/<mark>script</mark>&gt; snacks", response.Results[0].Highlights["expense_name"])
+
+	// Test 5: A query needs a word
+	for _, bad := range []string{"", "?q=", "?q=%26%21*", "?q=coffee&limit=0"} {
+		code, _ = search(bad)
+		assert.Equal(t, http.StatusBadRequest, code, bad)
+	}
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/keyring"
+	"github.com/ElenaGrasovskaya/gobank/services"
+)
+
+func newTestKey(t *testing.T, id string, private crypto.Signer, signFrom, expiresAt time.Time) *keyring.Key {
+	key, err := keyring.NewKey(id, private, signFrom, expiresAt)
+	assert.NoError(t, err)
+	return key
+}
+
+func kidOf(t *testing.T, tokenString string) string {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	assert.NoError(t, err)
+	return token.Header["kid"].(string)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	now := time.Now()
+	_, oldPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	newPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	oldKey := newTestKey(t, "old", oldPrivate, now.Add(-24*time.Hour), now.Add(time.Hour))
+	before, err := keyring.New(oldKey)
+	assert.NoError(t, err)
+	oldToken, err := before.Sign(jwt.MapClaims{"id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", kidOf(t, oldToken))
+
+	// The new key takes over signing while the old one is still accepted
+	during, err := keyring.New(oldKey, newTestKey(t, "new", newPrivate, now.Add(-time.Minute), time.Time{}))
+	assert.NoError(t, err)
+
+	newToken, err := during.Sign(jwt.MapClaims{"id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", kidOf(t, newToken))
+
+	for _, tokenString := range []string{oldToken, newToken} {
+		_, err := jwt.Parse(tokenString, during.Keyfunc)
+		assert.NoError(t, err)
+	}
+
+	// Once the overlap window closes the old key is refused
+	after, err := keyring.New(
+		newTestKey(t, "old", oldPrivate, now.Add(-24*time.Hour), now.Add(-time.Second)),
+		newTestKey(t, "new", newPrivate, now.Add(-time.Minute), time.Time{}),
+	)
+	assert.NoError(t, err)
+	_, err = jwt.Parse(oldToken, after.Keyfunc)
+	assert.Error(t, err)
+	assert.Len(t, after.JWKS().Keys, 1)
+
+	// Duplicate ids and small RSA keys are rejected
+	_, err = keyring.New(oldKey, oldKey)
+	assert.Error(t, err)
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	_, err = keyring.NewKey("small", small, now, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestKeyringRejectsForgedTokens(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	k, err := keyring.New(newTestKey(t, "main", private, time.Time{}, time.Time{}))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		description string
+		token       *jwt.Token
+		key         interface{}
+	}{
+		{"HMAC with the public key as secret", jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{}), []byte(private.Public().(ed25519.PublicKey))},
+		{"Unknown kid", jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{}), private},
+	}
+	tests[0].token.Header["kid"] = "main"
+	tests[1].token.Header["kid"] = "other"
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tokenString, err := test.token.SignedString(test.key)
+			assert.NoError(t, err)
+			_, err = jwt.Parse(tokenString, k.Keyfunc)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestKeyringLoad(t *testing.T) {
+	dir := t.TempDir()
+	for name, alg := range map[string]string{"rsa.pem": keyring.AlgRS256, "ed.pem": keyring.AlgEdDSA} {
+		data, err := keyring.GeneratePEM(alg)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+
+	config := `{"keys": [
+		{"kid": "2024-01", "file": "rsa.pem", "sign_from": "2024-01-01T00:00:00Z", "expires_at": "2999-01-01T00:00:00Z"},
+		{"kid": "2999-01", "file": "ed.pem", "sign_from": "2999-01-01T00:00:00Z"}
+	]}`
+	path := filepath.Join(dir, "keyring.json")
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0600))
+
+	k, err := keyring.Load(path)
+	assert.NoError(t, err)
+
+	key, err := k.SigningKey(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01", key.ID)
+	assert.Equal(t, keyring.AlgRS256, key.Algorithm)
+
+	// The upcoming key is already published
+	assert.Len(t, k.JWKS().Keys, 2)
+}
+
+func TestHandleJWKS(t *testing.T) {
+	router, store := InitializeTestServer()
+	previous, err := services.Keyring()
+	assert.NoError(t, err)
+	defer services.SetKeyring(previous)
+
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	k, err := keyring.New(newTestKey(t, "jwks-test", private, time.Time{}, time.Time{}))
+	assert.NoError(t, err)
+	services.SetKeyring(k)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var set keyring.JWKS
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "OKP", set.Keys[0].Kty)
+	assert.Equal(t, "jwks-test", set.Keys[0].Kid)
+	assert.NotEmpty(t, set.Keys[0].X)
+
+	// Access tokens carry the kid of the key that signed them
+	cookie, _ := createMockAuthCookie(store)
+	assert.Equal(t, "jwks-test", kidOf(t, cookie.Value))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/expense", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSetKeyringWhileServing(t *testing.T) {
+	router, store := InitializeTestServer()
+	previous, err := services.Keyring()
+	assert.NoError(t, err)
+	defer services.SetKeyring(previous)
+
+	cookie, _ := createMockAuthCookie(store)
+
+	// Requests keep verifying tokens while the keyring is swapped; run with
+	// -race to check the swap is synchronized.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/expense", nil)
+				req.AddCookie(cookie)
+				router.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		services.SetKeyring(previous)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func eur(units int64) types.Money {
+	return types.NewMoney(units*100, "EUR")
+}
+
+// createFundedAccount stores an extra account with an opening balance and
+// returns it together with an auth cookie for it.
+func createFundedAccount(t *testing.T, store storage.Storage, serial uint64, balance int64) (*http.Cookie, *types.Account) {
+	acc := &types.Account{
+		FirstName: "Funded",
+		LastName:  "Account",
+		Email:     "funded@gmail.com",
+		Status:    "Active",
+		Number:    types.FormatAccountNumber(serial),
+		Balance:   eur(balance),
+		CreatedAt: time.Now(),
+	}
+	acc, err := store.CreateAccount(context.Background(), acc)
+	assert.NoError(t, err)
+
+	return authCookie(store, acc), acc
+}
+
+func postTransfer(router http.Handler, cookie *http.Cookie, req *types.TransferRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	r, _ := http.NewRequest("POST", "/transfer", bytes.NewBuffer(body))
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandleTransfer(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	mockAccount := newMockAccount()
+	assert.NoError(t, store.RestoreAccount(ctx, mockAccount.ID))
+	funded, fundedAccount := createFundedAccount(t, store, 500500, 1000)
+
+	// Test 1: Not authorized request
+	w := postTransfer(router, nil, &types.TransferRequest{FromAccount: fundedAccount.Number, ToAccount: mockAccount.Number, Amount: eur(300)})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Test 2: Valid transfer moves the money and writes balanced entries
+	w = postTransfer(router, funded, &types.TransferRequest{FromAccount: fundedAccount.Number, ToAccount: mockAccount.Number, Amount: eur(300)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var posted types.LedgerTransaction
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &posted))
+	assert.Equal(t, "transfer", posted.Kind)
+	assert.Len(t, posted.Entries, 2)
+	assert.Equal(t, eur(-300), posted.Entries[0].Amount)
+	assert.Equal(t, eur(300), posted.Entries[1].Amount)
+
+	from, _ := store.GetAccountById(ctx, fundedAccount.ID)
+	to, _ := store.GetAccountById(ctx, mockAccount.ID)
+	assert.Equal(t, eur(700), from.Balance)
+	assert.Equal(t, eur(300), to.Balance)
+
+	// Test 3: Insufficient funds
+	w = postTransfer(router, funded, &types.TransferRequest{FromAccount: fundedAccount.Number, ToAccount: mockAccount.Number, Amount: eur(701)})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Test 4: Non-positive amount
+	w = postTransfer(router, funded, &types.TransferRequest{FromAccount: fundedAccount.Number, ToAccount: mockAccount.Number, Amount: eur(0)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test 5: Transfer from an account the caller does not own
+	w = postTransfer(router, funded, &types.TransferRequest{FromAccount: mockAccount.Number, ToAccount: fundedAccount.Number, Amount: eur(10)})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test 6: Mistyped account number
+	typo := mockAccount.Number[:len(mockAccount.Number)-1] + "9"
+	w = postTransfer(router, funded, &types.TransferRequest{FromAccount: fundedAccount.Number, ToAccount: typo, Amount: eur(10)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test 7: Deleted destination account
+	assert.NoError(t, store.DeleteAccount(ctx, mockAccount.ID))
+	w = postTransfer(router, funded, &types.TransferRequest{FromAccount: fundedAccount.Number, ToAccount: mockAccount.Number, Amount: eur(10)})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	from, _ = store.GetAccountById(ctx, fundedAccount.ID)
+	assert.Equal(t, eur(700), from.Balance, "Rejected transfers must not change balances")
+}
+
+func postAmount(router http.Handler, cookie *http.Cookie, path string, amount int64) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(&types.AmountRequest{Amount: eur(amount), Description: "test"})
+	r, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandleDepositWithdrawAndHistory(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, mockAccount := createMockAuthCookie(store)
+	assert.NoError(t, store.RestoreAccount(ctx, mockAccount.ID))
+	_, other := createFundedAccount(t, store, 500500, 0)
+
+	assert.Equal(t, http.StatusOK, postAmount(router, cookie, "/account/7/deposit", 1000).Code)
+	assert.Equal(t, http.StatusOK, postAmount(router, cookie, "/account/7/withdraw", 300).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, postAmount(router, cookie, "/account/7/withdraw", 5000).Code)
+	assert.Equal(t, http.StatusBadRequest, postAmount(router, cookie, "/account/7/deposit", -5).Code)
+	assert.Equal(t, http.StatusNotFound, postAmount(router, cookie, "/account/8/deposit", 10).Code)
+
+	w := postTransfer(router, cookie, &types.TransferRequest{FromAccount: mockAccount.Number, ToAccount: other.Number, Amount: eur(200)})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	acc, _ := store.GetAccountById(ctx, mockAccount.ID)
+	assert.Equal(t, eur(500), acc.Balance)
+
+	// First page holds the two newest entries
+	r, _ := http.NewRequest("GET", "/account/7/transactions?limit=2", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var page types.StatementPage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Transactions, 2)
+	assert.Equal(t, types.LedgerTransfer, page.Transactions[0].Type)
+	assert.Equal(t, eur(-200), page.Transactions[0].Amount)
+	assert.Equal(t, other.Number, page.Transactions[0].Counterparty)
+	assert.Equal(t, eur(500), page.Transactions[0].Balance)
+	assert.Equal(t, types.LedgerWithdrawal, page.Transactions[1].Type)
+	assert.Equal(t, eur(700), page.Transactions[1].Balance)
+
+	// Second page holds the opening deposit
+	r, _ = http.NewRequest("GET", "/account/7/transactions?limit=2&page=2", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Transactions, 1)
+	assert.Equal(t, types.LedgerDeposit, page.Transactions[0].Type)
+	assert.Equal(t, types.ExternalCounterparty, page.Transactions[0].Counterparty)
+	assert.Equal(t, eur(1000), page.Transactions[0].Balance)
+
+	r, _ = http.NewRequest("GET", "/account/7/transactions?limit=500", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminCannotMoveOtherUsersMoney(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	adminCookie := createAdminAuthCookie(store)
+	admin, err := store.GetAccountByEmail(ctx, "admin@gmail.com")
+	assert.NoError(t, err)
+	_, victim := createFundedAccount(t, store, 500500, 1000)
+
+	// Test 1: Transfers out of, and withdrawals from, other users' accounts
+	w := postTransfer(router, adminCookie, &types.TransferRequest{FromAccount: victim.Number, ToAccount: admin.Number, Amount: eur(100)})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	path := fmt.Sprintf("/account/%d/", victim.ID)
+	assert.Equal(t, http.StatusNotFound, postAmount(router, adminCookie, path+"withdraw", 100).Code)
+	assert.Equal(t, http.StatusNotFound, postAmount(router, adminCookie, path+"deposit", 100).Code)
+
+	acc, _ := store.GetAccountById(ctx, victim.ID)
+	assert.Equal(t, eur(1000), acc.Balance)
+
+	// Test 2: Admins can still read the statement
+	r, _ := http.NewRequest("GET", path+"transactions", nil)
+	r.AddCookie(adminCookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
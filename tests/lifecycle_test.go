@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/account"
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestAccountLifecycle(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	adminCookie := createAdminAuthCookie(store)
+	session := responseCookie(login(t, router), "token")
+	credentials := &types.LoginRequest{Email: "testing@gmail.com", Password: "test"}
+
+	lifecycle := func(action string) int {
+		return doWithCookie(router, "POST", "/account/7/"+action, adminCookie).Code
+	}
+	loginCode := func() int {
+		return postJSON(router, "/login", nil, credentials).Code
+	}
+
+	// Test 1: Only admins manage the lifecycle
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "POST", "/account/7/suspend", session).Code)
+
+	// Test 2: Suspension ends sessions and blocks login until restored
+	assert.Equal(t, http.StatusOK, lifecycle("suspend"))
+	assert.Equal(t, http.StatusConflict, lifecycle("suspend"))
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", session).Code)
+	assert.Equal(t, http.StatusForbidden, loginCode())
+
+	assert.Equal(t, http.StatusOK, lifecycle("restore"))
+	assert.Equal(t, http.StatusOK, loginCode())
+
+	// Test 3: Deleted accounts cannot log in but can be restored in time
+	_, err := store.CreateExpense(ctx, &types.Expense{UserId: 7, ExpenseName: "private", CreatedAt: time.Now()})
+	assert.NoError(t, err)
+	createCategory(t, store, 7, "private", nil)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "DELETE", "/account/7", adminCookie).Code)
+	assert.Equal(t, http.StatusForbidden, loginCode())
+	assert.Equal(t, http.StatusOK, lifecycle("restore"))
+
+	t.Setenv("ACCOUNT_RETENTION_DAYS", "0")
+	assert.Equal(t, http.StatusConflict, lifecycle("purge"))
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "DELETE", "/account/7", adminCookie).Code)
+	assert.Equal(t, http.StatusConflict, lifecycle("restore"))
+
+	// Test 4: Purging anonymizes the account and removes what it owned
+	assert.Equal(t, http.StatusOK, lifecycle("purge"))
+	assert.Equal(t, http.StatusConflict, lifecycle("restore"))
+
+	acc, err := store.GetAccountById(ctx, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StatusPurged, acc.Status)
+	assert.Empty(t, acc.FirstName)
+	assert.Empty(t, acc.Password)
+	assert.NotEqual(t, "testing@gmail.com", acc.Email)
+
+	expenses, err := store.GetExpenseForUser(ctx, 7, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, expenses.Expenses)
+	categories, err := store.GetCategoriesForUser(ctx, 7)
+	assert.NoError(t, err)
+	assert.Empty(t, categories)
+	assert.Equal(t, http.StatusUnauthorized, loginCode())
+}
+
+func TestRestoreKeepsPendingAccountsPending(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	adminCookie := createAdminAuthCookie(store)
+
+	pending, err := types.NewAccount("New", "User", "pending@gmail.com", "password", "")
+	assert.NoError(t, err)
+	assert.Equal(t, types.StatusPending, pending.Status)
+	pending, err = store.CreateAccount(ctx, pending)
+	assert.NoError(t, err)
+
+	path := fmt.Sprintf("/account/%d/", pending.ID)
+	status := func() string {
+		acc, err := store.GetAccountById(ctx, pending.ID)
+		assert.NoError(t, err)
+		return acc.Status
+	}
+
+	// Test 1: Suspending and restoring does not skip email verification
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "POST", path+"suspend", adminCookie).Code)
+	assert.Equal(t, types.StatusSuspended, status())
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "POST", path+"restore", adminCookie).Code)
+	assert.Equal(t, types.StatusPending, status())
+
+	// Test 2: Nor does deleting a suspended pending account and restoring it
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "POST", path+"suspend", adminCookie).Code)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "DELETE", fmt.Sprintf("/account/%d", pending.ID), adminCookie).Code)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "POST", path+"restore", adminCookie).Code)
+	assert.Equal(t, types.StatusPending, status())
+	assert.NotEqual(t, http.StatusOK, postJSON(router, "/login", nil, &types.LoginRequest{Email: "pending@gmail.com", Password: "password"}).Code)
+
+	// Test 3: Active accounts come back active
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "POST", "/account/7/suspend", adminCookie).Code)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "POST", "/account/7/restore", adminCookie).Code)
+	acc, err := store.GetAccountById(ctx, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StatusActive, acc.Status)
+}
+
+func TestPurgeExpiredAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	var ids []int
+	for _, email := range []string{"a@b.c", "d@e.f", "g@h.i"} {
+		acc, err := store.CreateAccount(ctx, &types.Account{Email: email, Status: types.StatusActive})
+		assert.NoError(t, err)
+		ids = append(ids, acc.ID)
+	}
+	assert.NoError(t, store.DeleteAccount(ctx, ids[0]))
+	assert.NoError(t, store.DeleteAccount(ctx, ids[1]))
+
+	retention := 30 * 24 * time.Hour
+
+	// Test 1: Nothing happens within the grace period
+	purged, err := account.PurgeExpiredAccounts(ctx, store, retention, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	// Test 2: Afterwards only deleted accounts are purged
+	purged, err = account.PurgeExpiredAccounts(ctx, store, retention, time.Now().Add(retention+time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	for i, id := range ids {
+		acc, err := store.GetAccountById(ctx, id)
+		assert.NoError(t, err)
+		if i < 2 {
+			assert.Equal(t, types.StatusPurged, acc.Status)
+		} else {
+			assert.Equal(t, types.StatusActive, acc.Status)
+		}
+	}
+}
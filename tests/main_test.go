@@ -0,0 +1,25 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain gives the handlers a notifier, since none is configured by
+// default. Tests that look at notifications use useCaptureNotifier instead.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gobank-tests")
+	if err != nil {
+		panic(err)
+	}
+
+	if os.Getenv("NOTIFIER") == "" && os.Getenv("MAIL_SENDER") == "" {
+		os.Setenv("NOTIFIER", "file")
+		os.Setenv("NOTIFY_FILE", filepath.Join(dir, "notifications.jsonl"))
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreAccounts(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	acc, err := types.NewAccount("a", "b", "a@b.c", "111", "")
+	assert.NoError(t, err)
+
+	created, err := store.CreateAccount(ctx, acc)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created.ID)
+
+	byEmail, err := store.GetAccountByEmail(ctx, "a@b.c")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, byEmail.ID)
+
+	byNumber, err := store.GetAccountByNumber(ctx, created.Number)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, byNumber.ID)
+
+	// Numbers are generated when missing; a taken one is refused
+	_, err = types.ParseAccountNumber(created.Number)
+	assert.NoError(t, err)
+	_, err = store.CreateAccount(ctx, &types.Account{Email: "d@e.f", Number: created.Number})
+	assert.ErrorIs(t, err, storage.ErrAccountNumberInUse)
+
+	// Emails are compared without case
+	_, err = store.CreateAccount(ctx, &types.Account{Email: "a@b.c"})
+	assert.ErrorIs(t, err, storage.ErrEmailTaken)
+	_, err = store.CreateAccount(ctx, &types.Account{Email: "A@b.C"})
+	assert.ErrorIs(t, err, storage.ErrEmailTaken)
+	byEmail, err = store.GetAccountByEmail(ctx, "A@B.c")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, byEmail.ID)
+
+	_, err = store.GetAccountByEmail(ctx, "missing@b.c")
+	assert.EqualError(t, err, "account for missing@b.c not found")
+
+	_, err = store.GetAccountById(ctx, 42)
+	assert.EqualError(t, err, "account 42 not found")
+
+	assert.NoError(t, store.DeleteAccount(ctx, created.ID))
+	deleted, err := store.GetAccountById(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Deleted", deleted.Status)
+
+	// Restoring returns the account to the status it had, here unverified
+	assert.NoError(t, store.RestoreAccount(ctx, created.ID))
+	restored, err := store.GetAccountById(ctx, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Pending", restored.Status)
+}
+
+func TestMemoryStoreExpenses(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	first, err := store.CreateExpense(ctx, &types.Expense{UserId: 1, ExpenseName: "first", CreatedAt: time.Now()})
+	assert.NoError(t, err)
+	_, err = store.CreateExpense(ctx, &types.Expense{UserId: 2, ExpenseName: "second", CreatedAt: time.Now()})
+	assert.NoError(t, err)
+
+	forUser, err := store.GetExpenseForUser(ctx, 1, nil)
+	assert.NoError(t, err)
+	assert.Len(t, forUser.Expenses, 1)
+
+	// Mutating a returned value must not leak into the store.
+	forUser.Expenses[0].ExpenseName = "changed"
+	stored, err := store.GetExpenseById(ctx, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", stored.ExpenseName)
+
+	assert.EqualError(t, store.UpdateExpense(ctx, 99, &types.Expense{}), "no rows affected")
+
+	assert.NoError(t, store.DeleteExpense(ctx, first.ID))
+	_, err = store.GetExpenseById(ctx, first.ID)
+	assert.EqualError(t, err, "expense 1 not found")
+
+	all, err := store.GetAllExpense(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, all.Expenses, 1)
+}
+
+func TestMemoryStoreConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.CreateExpense(ctx, &types.Expense{UserId: 1})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	all, err := store.GetAllExpense(ctx, nil)
+	assert.NoError(t, err)
+	assert.Len(t, all.Expenses, 50)
+}
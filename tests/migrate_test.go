@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/storage"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	migrations, err := storage.Migrations()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, migrations)
+
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_account_and_expense", migrations[0].Name)
+
+	for i, m := range migrations {
+		assert.NotEmpty(t, m.Up, "migration %d has no up script", m.Version)
+		assert.NotEmpty(t, m.Down, "migration %d has no down script", m.Version)
+		if i > 0 {
+			assert.Greater(t, m.Version, migrations[i-1].Version)
+		}
+	}
+
+	latest, err := storage.LatestVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, latest)
+}
+
+// TestChecksummedAccountNumbersResolvesDuplicates runs migration 0015 over
+// accounts sharing a legacy number. It needs TEST_POSTGRES and rolls the
+// test database back to version 14 on the way.
+func TestUniqueAccountEmailResolvesDuplicates(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("TEST_POSTGRES is not set")
+	}
+
+	ctx := context.Background()
+	store, err := NewTestPostgresStore()
+	assert.NoError(t, err)
+	assert.NoError(t, store.MigrateTo(ctx, 12))
+	t.Cleanup(func() { assert.NoError(t, store.MigrateUp(ctx)) })
+
+	var ids []int
+	for i, email := range []string{"twice-legacy@gmail.com", "Twice-Legacy@gmail.com"} {
+		var id int
+		err := store.Db.QueryRowContext(ctx,
+			"insert into account (first_name, last_name, email, status, number) values ('Legacy', 'Holder', ?, 'Active', ?) returning id",
+			email, 112400+i).Scan(&id)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	t.Cleanup(func() {
+		_, err := store.Db.ExecContext(ctx, "delete from account where id in (?)", bun.In(ids))
+		assert.NoError(t, err)
+	})
+
+	assert.NoError(t, store.MigrateTo(ctx, 13))
+
+	emails := make([]string, len(ids))
+	for i, id := range ids {
+		assert.NoError(t, store.Db.QueryRowContext(ctx, "select email from account where id = ?", id).Scan(&emails[i]))
+	}
+	assert.Equal(t, "twice-legacy@gmail.com", emails[0], "the oldest account keeps its email")
+	assert.Equal(t, fmt.Sprintf("duplicate-%d@invalid", ids[1]), emails[1])
+}
+
+func TestChecksummedAccountNumbersResolvesDuplicates(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("TEST_POSTGRES is not set")
+	}
+
+	ctx := context.Background()
+	store, err := NewTestPostgresStore()
+	assert.NoError(t, err)
+	assert.NoError(t, store.MigrateTo(ctx, 14))
+	t.Cleanup(func() { assert.NoError(t, store.MigrateUp(ctx)) })
+
+	var ids []int
+	for _, email := range []string{"first-legacy@gmail.com", "second-legacy@gmail.com"} {
+		var id int
+		err := store.Db.QueryRowContext(ctx,
+			"insert into account (first_name, last_name, email, status, number) values ('Legacy', 'Holder', ?, 'Active', 112302) returning id",
+			email).Scan(&id)
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	t.Cleanup(func() {
+		_, err := store.Db.ExecContext(ctx, "delete from account where id in (?)", bun.In(ids))
+		assert.NoError(t, err)
+	})
+
+	assert.NoError(t, store.MigrateTo(ctx, 15))
+
+	numbers := map[string]bool{}
+	for _, id := range ids {
+		var number string
+		assert.NoError(t, store.Db.QueryRowContext(ctx, "select number from account where id = ?", id).Scan(&number))
+		_, err := types.ParseAccountNumber(number)
+		assert.NoError(t, err)
+		numbers[number] = true
+	}
+	assert.Len(t, numbers, 2)
+	assert.True(t, numbers[types.FormatAccountNumber(112302)], "the oldest account keeps its number")
+}
+
+func TestOpeningBalancesMatchStatements(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") == "" {
+		t.Skip("TEST_POSTGRES is not set")
+	}
+
+	ctx := context.Background()
+	store, err := NewTestPostgresStore()
+	assert.NoError(t, err)
+	assert.NoError(t, store.MigrateTo(ctx, 22))
+	t.Cleanup(func() { assert.NoError(t, store.MigrateUp(ctx)) })
+
+	// A legacy balance of 50.00 of which only a 10.00 deposit is in the ledger.
+	var accountId, depositId int
+	err = store.Db.QueryRowContext(ctx,
+		"insert into account (first_name, last_name, email, status, number, balance_amount, balance_currency, created_at) values ('Legacy', 'Holder', 'opening-legacy@gmail.com', 'Active', ?, 5000, 'EUR', ?) returning id",
+		types.FormatAccountNumber(990023), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)).Scan(&accountId)
+	assert.NoError(t, err)
+	err = store.Db.QueryRowContext(ctx,
+		"insert into ledger_transaction (kind, description, created_at) values ('deposit', '', ?) returning id",
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)).Scan(&depositId)
+	assert.NoError(t, err)
+	_, err = store.Db.ExecContext(ctx,
+		"insert into ledger_entry (transaction_id, account_id, amount, currency, created_at) values (?, null, -1000, 'EUR', ?), (?, ?, 1000, 'EUR', ?)",
+		depositId, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), depositId, accountId, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		var transactionIds []int
+		err := store.Db.NewSelect().Model((*types.LedgerEntry)(nil)).
+			Column("transaction_id").Where("account_id = ?", accountId).Scan(ctx, &transactionIds)
+		assert.NoError(t, err)
+		for _, query := range []string{
+			"delete from ledger_entry where transaction_id in (?)",
+			"delete from ledger_transaction where id in (?)",
+		} {
+			_, err := store.Db.ExecContext(ctx, query, bun.In(transactionIds))
+			assert.NoError(t, err)
+		}
+		_, err = store.Db.ExecContext(ctx, "delete from account where id = ?", accountId)
+		assert.NoError(t, err)
+	})
+
+	assert.NoError(t, store.MigrateTo(ctx, 23))
+
+	statement, err := store.GetStatement(ctx, accountId, 1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, statement.Transactions, 2) {
+		assert.Equal(t, types.LedgerDeposit, statement.Transactions[0].Type)
+		assert.Equal(t, eur(50), statement.Transactions[0].Balance)
+		assert.Equal(t, types.LedgerOpening, statement.Transactions[1].Type)
+		assert.Equal(t, eur(40), statement.Transactions[1].Amount)
+		assert.Equal(t, types.ExternalCounterparty, statement.Transactions[1].Counterparty)
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		expected types.Money
+	}{
+		{"12.34", "EUR", types.NewMoney(1234, "EUR")},
+		{"12.3", "usd", types.NewMoney(1230, "USD")},
+		{"-0.05", "GBP", types.NewMoney(-5, "GBP")},
+		{"7", "", types.NewMoney(700, types.DefaultCurrency)},
+		{"1.50", "JPY", types.Money{}},
+		{"1500", "JPY", types.NewMoney(1500, "JPY")},
+		{".5", "EUR", types.NewMoney(50, "EUR")},
+		{"+5", "EUR", types.NewMoney(500, "EUR")},
+		{"-+5", "EUR", types.Money{}},
+		{"+-5", "EUR", types.Money{}},
+		{"--5", "EUR", types.Money{}},
+	}
+
+	for _, test := range tests {
+		parsed, err := types.ParseMoney(test.value, test.currency)
+		if test.expected == (types.Money{}) {
+			assert.Error(t, err, test.value)
+			continue
+		}
+		assert.NoError(t, err, test.value)
+		assert.Equal(t, test.expected, parsed, test.value)
+	}
+
+	_, err := types.ParseMoney("0.001", "EUR")
+	assert.Error(t, err, "Extra precision must not be rounded away")
+	_, err = types.ParseMoney("abc", "EUR")
+	assert.Error(t, err)
+	_, err = types.ParseMoney("99999999999999999999", "EUR")
+	assert.ErrorIs(t, err, types.ErrMoneyOverflow)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	body, err := json.Marshal(types.NewMoney(-1005, "EUR"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"-10.05","currency":"EUR"}`, string(body))
+
+	var m types.Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":"0.10","currency":"GBP"}`), &m))
+	assert.Equal(t, types.NewMoney(10, "GBP"), m)
+
+	assert.NoError(t, json.Unmarshal([]byte(`"19.99"`), &m))
+	assert.Equal(t, types.NewMoney(1999, types.DefaultCurrency), m)
+
+	// Numbers are parsed from their text, so 0.1 stays exactly ten cents.
+	assert.NoError(t, json.Unmarshal([]byte(`0.1`), &m))
+	assert.Equal(t, types.NewMoney(10, types.DefaultCurrency), m)
+
+	assert.Error(t, json.Unmarshal([]byte(`12.345`), &m))
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := types.NewMoney(150, "EUR").Add(types.NewMoney(275, "EUR"))
+	assert.NoError(t, err)
+	assert.Equal(t, "4.25 EUR", sum.String())
+
+	diff, err := sum.Sub(types.NewMoney(500, "EUR"))
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.75", diff.Decimal())
+
+	_, err = sum.Add(types.NewMoney(1, "USD"))
+	assert.ErrorIs(t, err, types.ErrCurrencyMismatch)
+
+	_, err = types.NewMoney(math.MaxInt64, "EUR").Add(types.NewMoney(1, "EUR"))
+	assert.ErrorIs(t, err, types.ErrMoneyOverflow)
+
+	cmp, err := types.NewMoney(1, "EUR").Cmp(types.NewMoney(2, "EUR"))
+	assert.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+}
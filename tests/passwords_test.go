@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/mail"
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+// captureNotifier keeps notifications for tests to inspect.
+type captureNotifier struct {
+	mu            sync.Mutex
+	notifications []*notify.Notification
+}
+
+func (n *captureNotifier) Notify(ctx context.Context, msg *notify.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, msg)
+	return nil
+}
+
+func (n *captureNotifier) last() *notify.Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.notifications) == 0 {
+		return nil
+	}
+	return n.notifications[len(n.notifications)-1]
+}
+
+// useCaptureNotifier routes account messages to a captureNotifier for the
+// rest of the test.
+func useCaptureNotifier(t *testing.T) *captureNotifier {
+	previous, err := services.Notifier()
+	assert.NoError(t, err)
+	t.Cleanup(func() { services.SetNotifier(previous) })
+
+	n := &captureNotifier{}
+	services.SetNotifier(n)
+	return n
+}
+
+// failingNotifier refuses every notification, like a mail server that is down.
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(ctx context.Context, msg *notify.Notification) error {
+	return errors.New("mail server unavailable")
+}
+
+var tokenParam = regexp.MustCompile(`token=([A-Za-z0-9_.-]+)`)
+
+func TestChangePassword(t *testing.T) {
+	router, _ := InitializeTestServer()
+	current := responseCookie(login(t, router), "token")
+	other := responseCookie(login(t, router), "token")
+
+	change := func(req *types.ChangePasswordRequest) int {
+		return sendJSON(router, "PUT", "/account/password", current, req).Code
+	}
+
+	// Test 1: Wrong current password or weak new password
+	assert.Equal(t, http.StatusUnauthorized, change(&types.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "new-password"}))
+	assert.Equal(t, http.StatusBadRequest, change(&types.ChangePasswordRequest{CurrentPassword: "test", NewPassword: "short"}))
+
+	// Test 2: The password changes and only the current session survives
+	assert.Equal(t, http.StatusOK, change(&types.ChangePasswordRequest{CurrentPassword: "test", NewPassword: "new-password"}))
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/expense", current).Code)
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", other).Code)
+
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "test"}).Code)
+	assert.Equal(t, http.StatusOK, postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "new-password"}).Code)
+}
+
+func TestPasswordReset(t *testing.T) {
+	router, _ := InitializeTestServer()
+	sender := useCaptureNotifier(t)
+	session := responseCookie(login(t, router), "token")
+
+	forgot := func(email string) int {
+		w := postJSON(router, "/password/forgot", nil, &types.ForgotPasswordRequest{Email: email})
+		services.WaitForBackgroundMail()
+		return w.Code
+	}
+	link := func() string {
+		msg := sender.last()
+		assert.NotNil(t, msg)
+		assert.Equal(t, "testing@gmail.com", msg.To)
+		assert.Equal(t, notify.KindPasswordReset, msg.Kind)
+
+		match := tokenParam.FindStringSubmatch(msg.Body)
+		assert.Len(t, match, 2)
+		return match[1]
+	}
+
+	// Test 1: Unknown emails get the same answer and no mail
+	assert.Equal(t, http.StatusAccepted, forgot("nobody@gmail.com"))
+	assert.Nil(t, sender.last())
+
+	assert.Equal(t, http.StatusAccepted, forgot("testing@gmail.com"))
+	token := link()
+
+	// Test 2: Bad tokens and weak passwords are refused
+	reset := func(token, password string) int {
+		return postJSON(router, "/password/reset", nil, &types.ResetPasswordRequest{Token: token, NewPassword: password}).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, reset("bogus", "new-password"))
+	assert.Equal(t, http.StatusBadRequest, reset(token, "short"))
+
+	// Test 3: The token works once, voids the other links and signs out every session
+	assert.Equal(t, http.StatusAccepted, forgot("testing@gmail.com"))
+	other := link()
+	assert.Equal(t, http.StatusOK, reset(token, "new-password"))
+	assert.Equal(t, http.StatusBadRequest, reset(token, "another-password"))
+	assert.Equal(t, http.StatusBadRequest, reset(other, "another-password"))
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", session).Code)
+	w := postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "new-password"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Test 4: Changing the password also voids mailed links
+	session = responseCookie(w, "token")
+	assert.Equal(t, http.StatusAccepted, forgot("testing@gmail.com"))
+	token = link()
+	change := &types.ChangePasswordRequest{CurrentPassword: "new-password", NewPassword: "changed-password"}
+	assert.Equal(t, http.StatusOK, sendJSON(router, "PUT", "/account/password", session, change).Code)
+	assert.Equal(t, http.StatusBadRequest, reset(token, "another-password"))
+}
+
+func TestPasswordResetIsThrottled(t *testing.T) {
+	router, _ := InitializeTestServer()
+	sender := useCaptureNotifier(t)
+
+	// Known and unknown emails run out of requests alike
+	for _, email := range []string{"testing@gmail.com", "nobody@gmail.com"} {
+		for i := 0; i < 4; i++ {
+			w := postJSON(router, "/password/forgot", nil, &types.ForgotPasswordRequest{Email: email})
+			assert.Equal(t, http.StatusAccepted, w.Code, email)
+		}
+		w := postJSON(router, "/password/forgot", nil, &types.ForgotPasswordRequest{Email: strings.ToUpper(email)})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, email)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	}
+
+	services.WaitForBackgroundMail()
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	assert.Len(t, sender.notifications, 4)
+}
+
+func TestPasswordResetSendFailureLooksLikeUnknownEmail(t *testing.T) {
+	router, _ := InitializeTestServer()
+	useCaptureNotifier(t) // restores the notifier afterwards
+	services.SetNotifier(failingNotifier{})
+
+	unknown := postJSON(router, "/password/forgot", nil, &types.ForgotPasswordRequest{Email: "nobody@gmail.com"})
+	known := postJSON(router, "/password/forgot", nil, &types.ForgotPasswordRequest{Email: "testing@gmail.com"})
+	services.WaitForBackgroundMail()
+	assert.Equal(t, http.StatusAccepted, known.Code)
+	assert.Equal(t, unknown.Code, known.Code)
+	assert.Equal(t, unknown.Body.String(), known.Body.String())
+}
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := &mail.FileSender{Dir: dir}
+	assert.NoError(t, sender.Send(context.Background(), &mail.Message{To: "a@b.c", Subject: "Hi", Body: "Hello"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "Subject: Hi")
+}
+
+func TestMailSenderFromEnv(t *testing.T) {
+	// Test 1: Without configuration there is no sender rather than a log
+	t.Setenv("MAIL_SENDER", "")
+	_, err := mail.FromEnv()
+	assert.ErrorIs(t, err, mail.ErrNoSender)
+
+	// Test 2: Printing links has to be asked for
+	t.Setenv("MAIL_SENDER", "log")
+	sender, err := mail.FromEnv()
+	assert.NoError(t, err)
+	assert.IsType(t, mail.LogSender{}, sender)
+
+	t.Setenv("MAIL_SENDER", "pigeon")
+	_, err = mail.FromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+const bcryptPrefix = "$2a$"
+
+func TestAccountJSONHidesPassword(t *testing.T) {
+	acc := newMockAccount()
+	now := time.Now()
+	acc.DeletedAt = &now
+
+	for _, v := range []interface{}{acc, types.NewResponceAccount(acc), types.NewResponceAccounts([]*types.Account{acc})} {
+		data, err := json.Marshal(v)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), bcryptPrefix)
+		assert.NotContains(t, string(data), `"password"`)
+	}
+}
+
+func TestAccountEndpointsHidePassword(t *testing.T) {
+	router, store := InitializeTestServer()
+	adminCookie := createAdminAuthCookie(store)
+	useCaptureNotifier(t)
+
+	create := &types.CreateAccountRequest{FirstName: "New", LastName: "User", Email: "new@gmail.com", Password: "password"}
+	responses := map[string]string{
+		"register":       postJSON(router, "/register", nil, create).Body.String(),
+		"create":         postJSON(router, "/account", adminCookie, &types.CreateAccountRequest{FirstName: "A", LastName: "B", Email: "other@gmail.com", Password: "password"}).Body.String(),
+		"list":           doWithCookie(router, "GET", "/accounts", adminCookie).Body.String(),
+		"get":            doWithCookie(router, "GET", "/account/7", adminCookie).Body.String(),
+		"update":         sendJSON(router, "PATCH", "/account/7", adminCookie, map[string]string{"first_name": "Renamed"}).Body.String(),
+		"sessions":       doWithCookie(router, "GET", "/sessions", adminCookie).Body.String(),
+		"login response": postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "test"}).Body.String(),
+	}
+
+	for name, body := range responses {
+		assert.NotContains(t, body, bcryptPrefix, name)
+		assert.NotContains(t, body, `"password"`, name)
+	}
+	assert.Contains(t, responses["get"], `"created_at"`)
+}
+
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// TestJSONFieldNames keeps every JSON field of the API types in snake_case.
+func TestJSONFieldNames(t *testing.T) {
+	values := []interface{}{
+		types.CreateAccountRequest{}, types.UpdateAccountRequest{}, types.CreateExpenseRequest{}, types.UpdateExpenseRequest{},
+		types.LoginRequest{}, types.LoginResponse{}, types.ResponceAccount{}, types.AccountUpdateResponse{}, types.Account{}, types.Expense{},
+		types.TransferRequest{}, types.AmountRequest{}, types.StatementEntry{}, types.StatementPage{},
+		types.LedgerTransaction{}, types.LedgerEntry{}, types.ExchangeRate{}, types.ExpenseReport{}, types.ExpensePage{}, types.ExpenseSearchResponse{},
+		types.Category{}, types.CategoryRequest{}, types.Tag{}, types.TagUsage{}, types.RenameTagRequest{}, types.MergeTagsRequest{},
+		types.Budget{}, types.BudgetRequest{}, types.BudgetStatusResponse{},
+		types.TokenResponse{}, types.SessionResponse{}, types.ApiKey{}, types.CreateApiKeyRequest{}, types.CreateApiKeyResponse{},
+		types.TwoFactorEnrollment{}, types.TwoFactorLoginRequest{}, types.TwoFactorChallenge{}, types.RecoveryCodesResponse{},
+		types.ChangePasswordRequest{}, types.ForgotPasswordRequest{}, types.ResendVerificationRequest{}, types.ResetPasswordRequest{},
+	}
+
+	seen := map[reflect.Type]bool{}
+	var check func(reflect.Type)
+	check = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != reflect.TypeOf(types.Account{}).PkgPath() || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		// Types such as Money choose their own representation.
+		if reflect.PtrTo(typ).Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+			return
+		}
+
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				check(field.Type)
+				continue
+			}
+			assert.Regexp(t, snakeCase, name, "%s.%s", typ.Name(), field.Name)
+			check(field.Type)
+		}
+	}
+
+	for _, v := range values {
+		check(reflect.TypeOf(v))
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func doWithCookie(router *gin.Engine, method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("User-Agent", "session-test")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func getSessions(t *testing.T, router *gin.Engine, cookie *http.Cookie) []types.SessionResponse {
+	w := doWithCookie(router, "GET", "/sessions", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var sessions []types.SessionResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions))
+	return sessions
+}
+
+func TestListSessions(t *testing.T) {
+	router, _ := InitializeTestServer()
+	first := responseCookie(login(t, router), "token")
+	second := responseCookie(login(t, router), "token")
+
+	sessions := getSessions(t, router, second)
+	assert.Len(t, sessions, 2)
+
+	current := 0
+	for _, session := range sessions {
+		assert.NotEmpty(t, session.ID)
+		if session.Current {
+			current++
+		}
+	}
+	assert.Equal(t, 1, current, "Expected exactly one current session")
+
+	// Every session sees the same list
+	assert.Len(t, getSessions(t, router, first), 2)
+}
+
+func TestRevokeSession(t *testing.T) {
+	router, store := InitializeTestServer()
+	first := login(t, router)
+	firstAccess := responseCookie(first, "token")
+	secondAccess := responseCookie(login(t, router), "token")
+
+	var firstId string
+	for _, session := range getSessions(t, router, secondAccess) {
+		if !session.Current {
+			firstId = session.ID
+		}
+	}
+	assert.NotEmpty(t, firstId)
+
+	// Test 1: Other users' sessions are not found
+	otherCookie := createAdminAuthCookie(store)
+	w := doWithCookie(router, "DELETE", "/sessions/"+firstId, otherCookie)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test 2: Revoking a session rejects its access and refresh tokens
+	w = doWithCookie(router, "DELETE", "/sessions/"+firstId, secondAccess)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", firstAccess).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(router, responseCookie(first, "refresh_token")).Code)
+
+	// Test 3: The other session keeps working
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/expense", secondAccess).Code)
+	assert.Len(t, getSessions(t, router, secondAccess), 1)
+
+	// Test 4: Revoking twice is not found
+	w = doWithCookie(router, "DELETE", "/sessions/"+firstId, secondAccess)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLogoutEverywhere(t *testing.T) {
+	router, _ := InitializeTestServer()
+	first := responseCookie(login(t, router), "token")
+	second := responseCookie(login(t, router), "token")
+
+	w := doWithCookie(router, "DELETE", "/sessions", second)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", first).Code)
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", second).Code)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	router, _ := InitializeTestServer()
+	access := responseCookie(login(t, router), "token")
+
+	w := doWithCookie(router, "POST", "/logout", access)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusForbidden, doWithCookie(router, "GET", "/expense", access).Code)
+}
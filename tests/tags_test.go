@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestExpenseTags(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	create := func(name string, tags ...string) (int, *types.Expense) {
+		w := postJSON(router, "/expense", cookie, &types.CreateExpenseRequest{ExpenseName: name, Tags: tags, CreatedAt: time.Now()})
+		exp := new(types.Expense)
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), exp))
+		}
+		return w.Code, exp
+	}
+	listNames := func(query string) []string {
+		w := doWithCookie(router, "GET", "/expense?sort=date"+query, cookie)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		var page types.ExpensePage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		names := []string{}
+		for _, exp := range page.Expenses {
+			names = append(names, exp.ExpenseName)
+		}
+		return names
+	}
+	usage := func() []*types.TagUsage {
+		w := doWithCookie(router, "GET", "/tags", cookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var tags []*types.TagUsage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &tags))
+		return tags
+	}
+
+	// Test 1: Tags are normalized, deduplicated and sorted
+	code, hotel := create("hotel", "Trip-Berlin", "reimbursable", "trip-berlin")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"reimbursable", "trip-berlin"}, hotel.Tags)
+
+	_, _ = create("train", "trip-berlin")
+	_, _ = create("lunch", "reimbursable", "food")
+	_, plain := create("plain")
+	assert.Equal(t, []string{}, plain.Tags)
+
+	code, _ = create("bad", "not a tag")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	stored, err := store.GetExpenseById(ctx, hotel.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"reimbursable", "trip-berlin"}, stored.Tags)
+
+	// Test 2: Listings filter by any or all of a set of tags
+	assert.Equal(t, []string{"hotel", "train", "lunch"}, listNames("&tags=trip-berlin,food"))
+	assert.Equal(t, []string{"hotel"}, listNames("&tags=trip-berlin,reimbursable&tag_match=all"))
+	assert.Equal(t, []string{}, listNames("&tags=food,trip-berlin&tag_match=all"))
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", "/expense?tags=a&tag_match=some", cookie).Code)
+
+	// Test 3: Usage counts, most used first
+	assert.Equal(t, []*types.TagUsage{{Name: "reimbursable", Count: 2}, {Name: "trip-berlin", Count: 2}, {Name: "food", Count: 1}}, usage())
+
+	// Test 4: Updating an expense replaces its tags
+	w := postJSON(router, fmt.Sprintf("/expense/%d", hotel.ID), cookie, &types.UpdateExpenseRequest{ExpenseName: "hotel", Tags: []string{"trip-berlin"}, CreatedAt: hotel.CreatedAt})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"hotel", "train"}, listNames("&tags=trip-berlin"))
+	assert.Equal(t, []string{"lunch"}, listNames("&tags=reimbursable"))
+
+	// Test 5: Renaming reaches every expense and refuses existing names
+	rename := func(from, to string) int {
+		return sendJSON(router, "PATCH", "/tags/"+from, cookie, &types.RenameTagRequest{Name: to}).Code
+	}
+	assert.Equal(t, http.StatusOK, rename("trip-berlin", "Berlin-2024"))
+	assert.Equal(t, []string{"hotel", "train"}, listNames("&tags=berlin-2024"))
+	assert.Equal(t, http.StatusConflict, rename("berlin-2024", "food"))
+	assert.Equal(t, http.StatusNotFound, rename("nope", "other"))
+
+	// Test 6: Merging moves expenses onto one tag and drops the rest
+	merge := func(req *types.MergeTagsRequest) int {
+		return postJSON(router, "/tags/merge", cookie, req).Code
+	}
+	assert.Equal(t, http.StatusOK, merge(&types.MergeTagsRequest{From: []string{"food", "reimbursable"}, Into: "expensed"}))
+	assert.Equal(t, []string{"lunch"}, listNames("&tags=expensed"))
+	assert.Equal(t, []*types.TagUsage{{Name: "berlin-2024", Count: 2}, {Name: "expensed", Count: 1}}, usage())
+
+	lunch, err := store.GetExpenseById(ctx, hotel.ID+2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"expensed"}, lunch.Tags)
+
+	assert.Equal(t, http.StatusNotFound, merge(&types.MergeTagsRequest{From: []string{"food"}, Into: "expensed"}))
+	assert.Equal(t, http.StatusBadRequest, merge(&types.MergeTagsRequest{Into: "expensed"}))
+
+	// Test 7: Other users see only their own tags
+	adminCookie := createAdminAuthCookie(store)
+	w = doWithCookie(router, "GET", "/tags", adminCookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestLoginErrorsAreUniform(t *testing.T) {
+	router, _ := InitializeTestServer()
+
+	unknown := postJSON(router, "/login", nil, &types.LoginRequest{Email: "nobody@gmail.com", Password: "test"})
+	wrong := postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "wrong"})
+
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, unknown.Code, wrong.Code)
+	assert.Equal(t, unknown.Body.String(), wrong.Body.String())
+}
+
+func TestLoginBackoff(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	wrong := &types.LoginRequest{Email: "testing@gmail.com", Password: "wrong"}
+
+	// Test 1: A few failures are free
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/login", nil, wrong).Code)
+	}
+
+	// Test 2: Then even the right password has to wait
+	w := postJSON(router, "/login", nil, &types.LoginRequest{Email: "TESTING@gmail.com", Password: "test"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, retry)
+
+	// Test 3: Every attempt is recorded. Requests from http.NewRequest have
+	// no remote address, so the IP is empty.
+	failures, err := store.GetLoginFailures(ctx, "testing@gmail.com", "", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, failures.Email)
+	assert.Equal(t, 4, failures.IP)
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+
+	record := func(email, ip, outcome string, at time.Time) {
+		assert.NoError(t, store.RecordLoginAttempt(ctx, &types.LoginAttempt{Email: email, IP: ip, Outcome: outcome, CreatedAt: at}))
+	}
+
+	// Test 1: Ten failures lock the email for fifteen minutes
+	for i := 0; i < 10; i++ {
+		record("testing@gmail.com", "198.51.100.7", types.LoginFailure, time.Now().Add(-time.Minute))
+	}
+	w := postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "test"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retry, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.InDelta(t, 14*60, retry, 5)
+
+	// Test 2: Failures outside the window are forgotten
+	router, store = InitializeTestServer()
+	for i := 0; i < 10; i++ {
+		record("testing@gmail.com", "198.51.100.7", types.LoginFailure, time.Now().Add(-time.Hour))
+	}
+	login(t, router)
+
+	// Test 3: A success resets the count for the email but not for the IP
+	router, store = InitializeTestServer()
+	for i := 0; i < 10; i++ {
+		record("testing@gmail.com", "198.51.100.7", types.LoginFailure, time.Now().Add(-2*time.Minute))
+	}
+	record("testing@gmail.com", "198.51.100.8", types.LoginSuccess, time.Now().Add(-time.Minute))
+	login(t, router)
+
+	failures, err := store.GetLoginFailures(ctx, "testing@gmail.com", "198.51.100.7", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failures.Email)
+	assert.Equal(t, 10, failures.IP)
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/types"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func responseCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func login(t *testing.T, router *gin.Engine) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(&types.LoginRequest{Email: "testing@gmail.com", Password: "test"})
+	req, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w
+}
+
+func refresh(router *gin.Engine, refreshToken *http.Cookie) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/token/refresh", nil)
+	if refreshToken != nil {
+		req.AddCookie(refreshToken)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	router, _ := InitializeTestServer()
+
+	w := login(t, router)
+	access := responseCookie(w, "token")
+	first := responseCookie(w, "refresh_token")
+	assert.NotNil(t, access)
+	assert.NotNil(t, first)
+	assert.LessOrEqual(t, access.MaxAge, int((15 * time.Minute).Seconds()))
+
+	// Test 1: A refresh token buys a new pair
+	w = refresh(router, first)
+	assert.Equal(t, http.StatusOK, w.Code)
+	second := responseCookie(w, "refresh_token")
+	assert.NotNil(t, second)
+	assert.NotEqual(t, first.Value, second.Value)
+	assert.NotNil(t, responseCookie(w, "token"))
+
+	// Test 2: Replaying the first token is detected
+	w = refresh(router, first)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Test 3: ...and revokes the whole family, including the latest token
+	w = refresh(router, second)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Test 4: Missing or unknown tokens
+	assert.Equal(t, http.StatusUnauthorized, refresh(router, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(router, &http.Cookie{Name: "refresh_token", Value: "bogus"}).Code)
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	router, _ := InitializeTestServer()
+	claims := func(cookie *http.Cookie) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		_, _, err := new(jwt.Parser).ParseUnverified(cookie.Value, claims)
+		assert.NoError(t, err)
+		return claims
+	}
+
+	w := login(t, router)
+	first := claims(responseCookie(w, "token"))
+	second := claims(responseCookie(refresh(router, responseCookie(w, "refresh_token")), "token"))
+
+	// Every access token has its own jti; the session stays in sid.
+	assert.NotEmpty(t, first["jti"])
+	assert.NotEmpty(t, first["sid"])
+	assert.NotEqual(t, first["jti"], first["sid"])
+	assert.NotEqual(t, first["jti"], second["jti"])
+	assert.Equal(t, first["sid"], second["sid"])
+}
+
+func TestRefreshTokenInBody(t *testing.T) {
+	router, _ := InitializeTestServer()
+	first := responseCookie(login(t, router), "refresh_token")
+
+	body, _ := json.Marshal(&types.RefreshTokenRequest{RefreshToken: first.Value})
+	req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	router, _ := InitializeTestServer()
+	refreshToken := responseCookie(login(t, router), "refresh_token")
+
+	req, _ := http.NewRequest("POST", "/logout", nil)
+	req.AddCookie(refreshToken)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusUnauthorized, refresh(router, refreshToken).Code)
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	router, _ := InitializeTestServer()
+	keys, err := services.Keyring()
+	assert.NoError(t, err)
+
+	sign := func(claims jwt.MapClaims) *http.Cookie {
+		tokenString, err := keys.Sign(claims)
+		assert.NoError(t, err)
+		return &http.Cookie{Name: "token", Value: tokenString}
+	}
+
+	tests := []struct {
+		description string
+		cookie      *http.Cookie
+	}{
+		{"Expired token", sign(jwt.MapClaims{"id": 7, "email": "testing@gmail.com", "exp": time.Now().Add(-time.Minute).Unix()})},
+		{"Token without expiry", sign(jwt.MapClaims{"id": 7, "email": "testing@gmail.com"})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/expense", nil)
+			req.AddCookie(test.cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
+	}
+}
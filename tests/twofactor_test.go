@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/totp"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, test := range tests {
+		code, err := totp.Code(secret, totp.Step(time.Unix(test.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, test.code, code)
+	}
+
+	now := time.Unix(1111111109, 0)
+	_, ok := totp.Validate(secret, "081804", now.Add(totp.Period))
+	assert.True(t, ok, "Expected the previous step to be accepted")
+	_, ok = totp.Validate(secret, "081804", now.Add(3*totp.Period))
+	assert.False(t, ok)
+
+	uri := totp.ProvisioningURI("GoBank", "testing@gmail.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/GoBank:testing@gmail.com?"), uri)
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func postJSON(router *gin.Engine, path string, cookie *http.Cookie, body interface{}) *httptest.ResponseRecorder {
+	return sendJSON(router, "POST", path, cookie, body)
+}
+
+func sendJSON(router *gin.Engine, method, path string, cookie *http.Cookie, body interface{}) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(data))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func loginChallenge(t *testing.T, router *gin.Engine) string {
+	w := postJSON(router, "/login", nil, &types.LoginRequest{Email: "testing@gmail.com", Password: "test"})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Nil(t, responseCookie(w, "token"))
+
+	var challenge types.TwoFactorChallenge
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &challenge))
+	assert.True(t, challenge.TwoFactorRequired)
+	return challenge.PendingToken
+}
+
+func loginSecondStep(router *gin.Engine, pending string, code types.TwoFactorCodeRequest) *httptest.ResponseRecorder {
+	return postJSON(router, "/login/2fa", nil, &types.TwoFactorLoginRequest{PendingToken: pending, TwoFactorCodeRequest: code})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	router, store := InitializeTestServer()
+	cookie, _ := createMockAuthCookie(store)
+
+	// Test 1: Enrollment
+	w := postJSON(router, "/account/2fa", cookie, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var enrollment types.TwoFactorEnrollment
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+	assert.Len(t, enrollment.RecoveryCodes, 10)
+	assert.Contains(t, enrollment.ProvisioningURI, enrollment.Secret)
+
+	// Not enforced until confirmed
+	login(t, router)
+
+	step := totp.Step(time.Now())
+	code := func(step int64) types.TwoFactorCodeRequest {
+		c, err := totp.Code(enrollment.Secret, step)
+		assert.NoError(t, err)
+		return types.TwoFactorCodeRequest{Code: c}
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/account/2fa/confirm", cookie, &types.TwoFactorCodeRequest{Code: "000000"}).Code)
+	first := code(step)
+	assert.Equal(t, http.StatusOK, postJSON(router, "/account/2fa/confirm", cookie, &first).Code)
+
+	// Test 2: The password alone yields only a pending token
+	pending := loginChallenge(t, router)
+	assert.Equal(t, http.StatusForbidden, withBearer(router, "GET", "/expense", pending, nil).Code)
+
+	// Test 3: Codes cannot be replayed
+	w = loginSecondStep(router, pending, first)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = loginSecondStep(router, pending, code(step+1))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, responseCookie(w, "token"))
+
+	// Test 4: Recovery codes work once
+	recovery := types.TwoFactorCodeRequest{RecoveryCode: strings.ToUpper(enrollment.RecoveryCodes[0])}
+	assert.Equal(t, http.StatusOK, loginSecondStep(router, loginChallenge(t, router), recovery).Code)
+	assert.Equal(t, http.StatusUnauthorized, loginSecondStep(router, loginChallenge(t, router), recovery).Code)
+
+	// Test 5: Regenerating invalidates the old codes
+	w = postJSON(router, "/account/2fa/recovery-codes", cookie, &types.TwoFactorCodeRequest{RecoveryCode: enrollment.RecoveryCodes[1]})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var regenerated types.RecoveryCodesResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &regenerated))
+	assert.Len(t, regenerated.RecoveryCodes, 10)
+
+	old := types.TwoFactorCodeRequest{RecoveryCode: enrollment.RecoveryCodes[2]}
+	assert.Equal(t, http.StatusUnauthorized, postJSON(router, "/account/2fa/disable", cookie, &old).Code)
+
+	// Test 6: Disabling restores the single-step login
+	fresh := types.TwoFactorCodeRequest{RecoveryCode: regenerated.RecoveryCodes[0]}
+	assert.Equal(t, http.StatusOK, postJSON(router, "/account/2fa/disable", cookie, &fresh).Code)
+	login(t, router)
+	assert.Equal(t, http.StatusNotFound, postJSON(router, "/account/2fa/disable", cookie, &fresh).Code)
+}
+
+func TestTwoFactorPendingToken(t *testing.T) {
+	router, _ := InitializeTestServer()
+
+	w := loginSecondStep(router, "bogus", types.TwoFactorCodeRequest{Code: "123456"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A session token is not a pending token
+	access := responseCookie(login(t, router), "token")
+	w = loginSecondStep(router, access.Value, types.TwoFactorCodeRequest{Code: "123456"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -10,21 +10,51 @@ import (
 )
 
 func TestNewAccount(t *testing.T) {
-	acc, err := types.NewAccount("a", "b", "c@gmail", "111")
+	acc, err := types.NewAccount("a", "b", "c@gmail", "111", "")
 	assert.Nil(t, err)
 	fmt.Printf("%v /n", acc)
 
 }
 
 func TestNewExpense(t *testing.T) {
-	acc, err := types.NewExpense(1, "testa", "testb", "test", 100, time.Now())
+	acc, err := types.NewExpense(1, "testa", "testb", nil, types.NewMoney(10000, types.DefaultCurrency), time.Now())
 	assert.Nil(t, err)
 
 	fmt.Printf("%v /n", acc)
 }
 
 func TestUpdatedExpense(t *testing.T) {
-	acc, err := types.UpdatedExpense(1, 1, "testa", "testb", "test", 100, time.Now())
+	acc, err := types.UpdatedExpense(1, 1, "testa", "testb", nil, types.NewMoney(10000, types.DefaultCurrency), time.Now())
 	assert.Nil(t, err)
 	fmt.Printf("%v /n", acc)
 }
+
+func TestAccountNumbers(t *testing.T) {
+	number := types.FormatAccountNumber(112302)
+	assert.Equal(t, "GB30GOBK00000000112302", number)
+
+	// Test 1: Generated numbers are valid and read back in any spacing or case
+	generated, err := types.NewAccountNumber()
+	assert.NoError(t, err)
+	parsed, err := types.ParseAccountNumber(generated)
+	assert.NoError(t, err)
+	assert.Equal(t, generated, parsed)
+
+	parsed, err = types.ParseAccountNumber(" gb30 gobk 0000 0000 1123 02 ")
+	assert.NoError(t, err)
+	assert.Equal(t, number, parsed)
+
+	// Test 2: Typos and malformed numbers are rejected
+	for _, bad := range []string{
+		"GB30GOBK00000000112303", // one digit changed
+		"GB30GOBK00000000113202", // two digits swapped
+		"GB31GOBK00000000112302", // wrong check digits
+		"GB82WEST12345698765432", // valid IBAN, other bank
+		"GB30GOBK0000000011230",  // too short
+		"112302",
+		"",
+	} {
+		_, err := types.ParseAccountNumber(bad)
+		assert.ErrorIs(t, err, types.ErrInvalidAccountNumber, bad)
+	}
+}
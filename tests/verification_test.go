@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ElenaGrasovskaya/gobank/notify"
+	"github.com/ElenaGrasovskaya/gobank/services"
+	"github.com/ElenaGrasovskaya/gobank/types"
+)
+
+func TestEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	router, store := InitializeTestServer()
+	notifier := useCaptureNotifier(t)
+
+	credentials := &types.LoginRequest{Email: "new@gmail.com", Password: "password"}
+	w := postJSON(router, "/register", nil, &types.CreateAccountRequest{FirstName: "New", LastName: "User", Email: credentials.Email, Password: credentials.Password})
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Test 1: The account starts pending and cannot log in
+	account, err := store.GetAccountByEmail(ctx, credentials.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StatusPending, account.Status)
+	assert.Equal(t, http.StatusForbidden, postJSON(router, "/login", nil, credentials).Code)
+
+	msg := notifier.last()
+	assert.NotNil(t, msg)
+	assert.Equal(t, notify.KindVerifyEmail, msg.Kind)
+	assert.Equal(t, credentials.Email, msg.To)
+
+	resend := func(email string) int {
+		w := postJSON(router, "/verify/resend", nil, &types.ResendVerificationRequest{Email: email})
+		services.WaitForBackgroundMail()
+		return w.Code
+	}
+
+	// Test 2: Resending only reaches pending accounts
+	assert.Equal(t, http.StatusAccepted, resend("testing@gmail.com"))
+	assert.Len(t, notifier.notifications, 1)
+
+	assert.Equal(t, http.StatusAccepted, resend(credentials.Email))
+	assert.Len(t, notifier.notifications, 2)
+
+	match := tokenParam.FindStringSubmatch(notifier.last().Body)
+	assert.Len(t, match, 2)
+	token := match[1]
+
+	// Test 3: Bad tokens and other purposes are refused
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", "/verify?token=bogus", nil).Code)
+	access := responseCookie(login(t, router), "token")
+	assert.Equal(t, http.StatusBadRequest, doWithCookie(router, "GET", "/verify?token="+access.Value, nil).Code)
+
+	// Test 4: The link activates the account, and following it again is harmless
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/verify?token="+token, nil).Code)
+	assert.Equal(t, http.StatusOK, doWithCookie(router, "GET", "/verify?token="+token, nil).Code)
+	assert.Equal(t, http.StatusOK, postJSON(router, "/login", nil, credentials).Code)
+
+	assert.Equal(t, http.StatusAccepted, resend(credentials.Email))
+	assert.Len(t, notifier.notifications, 2)
+
+	// Test 5: Resends are throttled, for active and unknown emails too
+	for _, email := range []string{credentials.Email, "testing@gmail.com", "nobody@gmail.com"} {
+		code := 0
+		for i := 0; i < 5; i++ {
+			code = resend(email)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, code, email)
+	}
+	assert.Len(t, notifier.notifications, 2)
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "notifications.jsonl")
+	n := &notify.FileNotifier{Path: path}
+	assert.NoError(t, n.Notify(context.Background(), &notify.Notification{Kind: notify.KindVerifyEmail, To: "a@b.c", Subject: "Hi"}))
+	assert.NoError(t, n.Notify(context.Background(), &notify.Notification{Kind: notify.KindPasswordReset, To: "a@b.c", Subject: "Hi"}))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var kinds []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var got notify.Notification
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &got))
+		assert.False(t, got.CreatedAt.IsZero())
+		kinds = append(kinds, got.Kind)
+	}
+	assert.Equal(t, []string{notify.KindVerifyEmail, notify.KindPasswordReset}, kinds)
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Budget states by share of the month's budget spent.
+const (
+	BudgetOk       = "ok"
+	BudgetWarning  = "warning"
+	BudgetExceeded = "exceeded"
+
+	BudgetWarningPercent  = 80
+	BudgetExceededPercent = 100
+)
+
+// BudgetThresholds are the percentages that notify the user when crossed.
+var BudgetThresholds = []int{BudgetWarningPercent, BudgetExceededPercent}
+
+// Budget caps what a user spends each month in a category and its
+// subcategories. With Rollover, what is left of a month is added to the
+// next one.
+type Budget struct {
+	bun.BaseModel `bun:"table:budget,alias:b" json:"-"`
+	ID            int       `bun:"id,pk,autoincrement" json:"id"`
+	UserId        int       `bun:"user_id" json:"user_id"`
+	CategoryId    int       `bun:"category_id" json:"category_id"`
+	Limit         Money     `bun:"embed:limit_" json:"limit"`
+	Rollover      bool      `bun:"rollover" json:"rollover"`
+	CreatedAt     time.Time `bun:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `bun:"updated_at" json:"updated_at"`
+}
+
+// BudgetLimit is the limit of a budget from Month, formatted YYYY-MM, until
+// the next change. Every change of the limit adds one.
+type BudgetLimit struct {
+	bun.BaseModel `bun:"table:budget_limits,alias:bl" json:"-"`
+	BudgetId      int    `bun:"budget_id,pk" json:"budget_id"`
+	Month         string `bun:"month,pk" json:"month"`
+	Limit         Money  `bun:"embed:limit_" json:"limit"`
+}
+
+// NewBudgetLimit records the limit of budget from the month of from.
+func NewBudgetLimit(budget *Budget, from time.Time) *BudgetLimit {
+	return &BudgetLimit{
+		BudgetId: budget.ID,
+		Month:    from.UTC().Format("2006-01"),
+		Limit:    budget.Limit,
+	}
+}
+
+// BudgetLimitFor returns the limit in force in month from limits sorted by
+// month: the last one set in or before it, or the first one for months
+// before that. Without limits it returns current.
+func BudgetLimitFor(limits []*BudgetLimit, month string, current Money) Money {
+	if len(limits) == 0 {
+		return current
+	}
+
+	limit := limits[0].Limit
+	for _, l := range limits {
+		if l.Month > month {
+			break
+		}
+		limit = l.Limit
+	}
+	return limit
+}
+
+// BudgetRequest creates a budget, or replaces one in full. The category of
+// an existing budget cannot change.
+type BudgetRequest struct {
+	CategoryId int   `json:"category_id"`
+	Limit      Money `json:"limit"`
+	Rollover   bool  `json:"rollover"`
+}
+
+// NewBudget validates req and builds the budget it describes.
+func NewBudget(userId int, req *BudgetRequest) (*Budget, error) {
+	if !req.Limit.IsPositive() {
+		return nil, errors.New("limit must be positive")
+	}
+
+	now := time.Now().UTC()
+	return &Budget{
+		UserId:     userId,
+		CategoryId: req.CategoryId,
+		Limit:      req.Limit,
+		Rollover:   req.Rollover,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}, nil
+}
+
+// BudgetStatus is how far through its budget a category is in one month.
+// Available is the limit plus what rolled over from earlier months.
+type BudgetStatus struct {
+	BudgetId   int     `json:"budget_id"`
+	CategoryId int     `json:"category_id"`
+	Category   string  `json:"category"`
+	Month      string  `json:"month"`
+	Limit      Money   `json:"limit"`
+	RolledOver Money   `json:"rolled_over"`
+	Available  Money   `json:"available"`
+	Spent      Money   `json:"spent"`
+	Remaining  Money   `json:"remaining"`
+	Percent    float64 `json:"percent"`
+	State      string  `json:"state"`
+}
+
+type BudgetStatusResponse struct {
+	Month   string          `json:"month"`
+	Budgets []*BudgetStatus `json:"budgets"`
+}
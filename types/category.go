@@ -0,0 +1,92 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const maxCategoryNameLength = 50
+
+var (
+	categoryColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+	categoryIcon  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Category groups a user's expenses. Categories form a tree through
+// ParentId; names are unique among siblings, ignoring case.
+type Category struct {
+	bun.BaseModel `bun:"table:category,alias:cat" json:"-"`
+	ID            int       `bun:"id,pk,autoincrement" json:"id"`
+	UserId        int       `bun:"user_id" json:"user_id"`
+	ParentId      *int      `bun:"parent_id" json:"parent_id"`
+	Name          string    `bun:"name" json:"name"`
+	Color         string    `bun:"color" json:"color"`
+	Icon          string    `bun:"icon" json:"icon"`
+	CreatedAt     time.Time `bun:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `bun:"updated_at" json:"updated_at"`
+}
+
+// CategoryRequest creates a category, or replaces one in full. Color is a
+// hex code such as "#1e90ff" and Icon a name such as "shopping-cart"; both
+// are optional. A nil ParentId makes a top-level category.
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	ParentId *int   `json:"parent_id"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+}
+
+// NewCategory validates req and builds the category it describes.
+func NewCategory(userId int, req *CategoryRequest) (*Category, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxCategoryNameLength {
+		return nil, fmt.Errorf("name must be between 1 and %d characters", maxCategoryNameLength)
+	}
+
+	color := strings.ToLower(strings.TrimSpace(req.Color))
+	if color != "" && !categoryColor.MatchString(color) {
+		return nil, fmt.Errorf("invalid color %q, expected a hex code such as #1e90ff", req.Color)
+	}
+
+	icon := strings.TrimSpace(req.Icon)
+	if icon != "" && (len(icon) > maxCategoryNameLength || !categoryIcon.MatchString(icon)) {
+		return nil, fmt.Errorf("invalid icon %q, expected a name such as shopping-cart", req.Icon)
+	}
+
+	now := time.Now().UTC()
+	return &Category{
+		UserId:    userId,
+		ParentId:  req.ParentId,
+		Name:      name,
+		Color:     color,
+		Icon:      icon,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// CategorySubtree returns the id of root and of every category below it.
+func CategorySubtree(categories []*Category, root int) []int {
+	children := map[int][]int{}
+	for _, category := range categories {
+		if category.ParentId != nil {
+			children[*category.ParentId] = append(children[*category.ParentId], category.ID)
+		}
+	}
+
+	subtree := []int{root}
+	seen := map[int]bool{root: true}
+	for i := 0; i < len(subtree); i++ {
+		for _, child := range children[subtree[i]] {
+			if !seen[child] {
+				seen[child] = true
+				subtree = append(subtree, child)
+			}
+		}
+	}
+	return subtree
+}
@@ -0,0 +1,141 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Orders for expense listings. The prefix "-" sorts descending.
+const (
+	ExpenseSortDate      = "date"
+	ExpenseSortDateDesc  = "-date"
+	ExpenseSortValue     = "value"
+	ExpenseSortValueDesc = "-value"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ExpenseQuery filters, sorts and pages an expense listing. Zero fields do
+// not filter. A Limit of zero or less returns every match.
+type ExpenseQuery struct {
+	// From and Before bound CreatedAt; From is included, Before is not.
+	From   *time.Time
+	Before *time.Time
+
+	// CategoryIds matches expenses in any of the categories.
+	CategoryIds []int
+	Purpose     string
+
+	// Tags matches expenses carrying any of the tags, or all of them if
+	// AllTags is set.
+	Tags    []string
+	AllTags bool
+
+	// MinValue and MaxValue are inclusive and only match expenses in their
+	// currency, since amounts in different currencies do not compare.
+	MinValue *Money
+	MaxValue *Money
+
+	// Currency matches only expenses in the currency. Sorting by value
+	// needs it for the same reason.
+	Currency string
+
+	Sort   string
+	Cursor *ExpenseCursor
+	Limit  int
+}
+
+// SortBy returns the sort field and direction, defaulting to newest first.
+func (q *ExpenseQuery) SortBy() (field string, descending bool) {
+	switch q.Sort {
+	case ExpenseSortDate:
+		return ExpenseSortDate, false
+	case ExpenseSortValue:
+		return ExpenseSortValue, false
+	case ExpenseSortValueDesc:
+		return ExpenseSortValue, true
+	default:
+		return ExpenseSortDate, true
+	}
+}
+
+// SortOrder returns the sort in its query form, such as "-date".
+func (q *ExpenseQuery) SortOrder() string {
+	field, descending := q.SortBy()
+	if descending {
+		return "-" + field
+	}
+	return field
+}
+
+// ExpenseCursor marks the last expense of a page: its sort key and id,
+// which breaks ties. The next page starts right after it.
+type ExpenseCursor struct {
+	Sort      string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	Value     int64     `json:"v,omitempty"`
+	ID        int       `json:"id"`
+}
+
+// NewExpenseCursor returns the cursor pointing after exp in a listing
+// sorted by sort.
+func NewExpenseCursor(sort string, exp *Expense) *ExpenseCursor {
+	return &ExpenseCursor{Sort: sort, CreatedAt: exp.CreatedAt, Value: exp.ExpenseValue.Amount, ID: exp.ID}
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c *ExpenseCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeExpenseCursor reads a cursor from Encode and checks that it was made
+// for the same sort order.
+func DecodeExpenseCursor(s, sort string) (*ExpenseCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := new(ExpenseCursor)
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.ID <= 0 || cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// ExpensePage is one page of an expense listing. NextCursor is empty on the
+// last page.
+type ExpensePage struct {
+	Expenses   []*Expense `json:"expenses"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	HasMore    bool       `json:"has_more"`
+	Limit      int        `json:"limit"`
+}
+
+// SearchTerms splits a search into lower-case words of letters and digits.
+// Every other character separates words, so the terms are safe to pass to
+// to_tsquery.
+func SearchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// ExpenseSearchResult is one match of an expense search. Highlights holds the
+// matched fields, expense_name or expense_purpose, with each matching word
+// wrapped in <mark></mark> and the rest of the text HTML-escaped.
+type ExpenseSearchResult struct {
+	Expense    *Expense          `json:"expense"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+type ExpenseSearchResponse struct {
+	Query   string                 `json:"query"`
+	Results []*ExpenseSearchResult `json:"results"`
+}
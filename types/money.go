@@ -0,0 +1,274 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "EUR"
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrMoneyOverflow    = errors.New("money amount overflows")
+)
+
+// currencyExponents lists the number of minor-unit digits for currencies
+// that do not use two.
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+// Money is an exact amount in minor units (cents for EUR) of a currency.
+// In JSON it is {"amount":"12.34","currency":"EUR"}; in the database it is
+// embedded as <prefix>amount bigint and <prefix>currency char(3).
+type Money struct {
+	Amount   int64  `bun:"amount"`
+	Currency string `bun:"currency"`
+}
+
+func NewMoney(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "-12.5" into Money. It refuses
+// more fractional digits than the currency has instead of rounding.
+func ParseMoney(value, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.TrimSpace(value)
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	exp := CurrencyExponent(currency)
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) > exp {
+		if strings.Trim(frac[exp:], "0") != "" {
+			return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", value, exp, currency)
+		}
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	digits := strings.TrimLeft(whole+frac, "0")
+	if digits == "" {
+		return Money{Currency: currency}, nil
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, ErrMoneyOverflow
+	}
+	if negative {
+		minor = -minor
+	}
+
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 style code and defaults an empty
+// one to DefaultCurrency.
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency, nil
+	}
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("invalid currency code %q", currency)
+	}
+	return currency, nil
+}
+
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal formats the amount without currency, e.g. "-12.50".
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+	sign := ""
+	abs := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		abs = uint64(-m.Amount)
+	}
+
+	digits := strconv.FormatUint(abs, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Add returns m + o. It fails on differing currencies or int64 overflow.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) ||
+		(o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. It fails on differing currencies or int64 overflow.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(o.Neg())
+}
+
+// Cmp compares two amounts of the same currency and returns -1, 0 or 1.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount":"12.34","currency":"EUR"} as well as a bare
+// decimal string or number, which is taken to be in DefaultCurrency. Numbers
+// are parsed from their literal text so no float rounding happens.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var raw moneyJSON
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	} else {
+		raw.Amount = data
+	}
+
+	amount := string(raw.Amount)
+	if len(raw.Amount) > 0 && raw.Amount[0] == '"' {
+		if err := json.Unmarshal(raw.Amount, &amount); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseMoney(amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Convert multiplies m by rate and expresses the result in currency. Unlike
+// the other helpers it has to round: the result is rounded to the nearest
+// minor unit, halves away from zero.
+func (m Money) Convert(rate *big.Rat, currency string) (Money, error) {
+	if rate.Sign() <= 0 {
+		return Money{}, fmt.Errorf("invalid exchange rate %s", rate.FloatString(6))
+	}
+
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	shift := CurrencyExponent(currency) - CurrencyExponent(m.Currency)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil))
+	if shift >= 0 {
+		value.Mul(value, scale)
+	} else {
+		value.Quo(value, scale)
+	}
+
+	// Round half away from zero: add or subtract 1/2 and truncate.
+	half := big.NewRat(1, 2)
+	if value.Sign() < 0 {
+		value.Sub(value, half)
+	} else {
+		value.Add(value, half)
+	}
+	rounded := new(big.Int).Quo(value.Num(), value.Denom())
+	if !rounded.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: rounded.Int64(), Currency: currency}, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const maxTagLength = 50
+
+var tagName = regexp.MustCompile(`^[a-z0-9]+([-_][a-z0-9]+)*$`)
+
+// Tag is a free label a user puts on any number of expenses, such as
+// "trip-berlin". Names are unique per user.
+type Tag struct {
+	bun.BaseModel `bun:"table:tag,alias:t" json:"-"`
+	ID            int       `bun:"id,pk,autoincrement" json:"id"`
+	UserId        int       `bun:"user_id" json:"user_id"`
+	Name          string    `bun:"name" json:"name"`
+	CreatedAt     time.Time `bun:"created_at" json:"created_at"`
+}
+
+// ExpenseTag links an expense to one of its tags.
+type ExpenseTag struct {
+	bun.BaseModel `bun:"table:expense_tags,alias:et" json:"-"`
+	ExpenseId     int `bun:"expense_id,pk" json:"expense_id"`
+	TagId         int `bun:"tag_id,pk" json:"tag_id"`
+}
+
+// TagUsage is a tag with the number of expenses carrying it.
+type TagUsage struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+// MergeTagsRequest moves the expenses of every tag in From to Into, which is
+// created if needed, and removes the tags in From.
+type MergeTagsRequest struct {
+	From []string `json:"from"`
+	Into string   `json:"into"`
+}
+
+// NormalizeTag lower-cases a tag and checks that it is made of letters and
+// digits joined by single hyphens or underscores.
+func NormalizeTag(name string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(name))
+	if len(tag) > maxTagLength || !tagName.MatchString(tag) {
+		return "", fmt.Errorf("invalid tag %q, expected letters and digits joined by - or _, at most %d characters", name, maxTagLength)
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag and returns them sorted without
+// duplicates.
+func NormalizeTags(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
@@ -1,7 +1,6 @@
 package types
 
 import (
-	"math/rand"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -13,21 +12,41 @@ type CreateAccountRequest struct {
 	LastName  string `json:"last_name"`
 	Email     string `json:"email"`
 	Password  string `json:"password"`
+	Currency  string `json:"currency"`
 }
 
+// UpdateAccountRequest changes an account's profile. Fields left out are
+// not changed. A new Email only applies once the address is confirmed, and
+// the account holder has to give CurrentPassword for it; admins do not.
+type UpdateAccountRequest struct {
+	FirstName       *string `json:"first_name"`
+	LastName        *string `json:"last_name"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// CreateExpenseRequest files the expense under CategoryId or, for clients
+// that send a name, under the top-level category named ExpenseCategory,
+// which is created if the user has none of that name.
 type CreateExpenseRequest struct {
 	ExpenseName     string    `json:"expense_name"`
 	ExpensePurpose  string    `json:"expense_purpose"`
+	CategoryId      *int      `json:"category_id"`
 	ExpenseCategory string    `json:"expense_category"`
-	ExpenseValue    float32   `json:"expense_value"`
+	Tags            []string  `json:"tags"`
+	ExpenseValue    Money     `json:"expense_value"`
 	CreatedAt       time.Time `json:"created_at"`
 }
 
+// UpdateExpenseRequest replaces an expense; its category is chosen as in
+// CreateExpenseRequest.
 type UpdateExpenseRequest struct {
 	ExpenseName     string    `json:"expense_name"`
 	ExpensePurpose  string    `json:"expense_purpose"`
+	CategoryId      *int      `json:"category_id"`
 	ExpenseCategory string    `json:"expense_category"`
-	ExpenseValue    float32   `json:"expense_value"`
+	Tags            []string  `json:"tags"`
+	ExpenseValue    Money     `json:"expense_value"`
 	CreatedAt       time.Time `json:"created_at"`
 }
 
@@ -43,17 +62,81 @@ type LoginResponse struct {
 	Email     string `json:"email"`
 }
 
+// ResponceAccount is what clients see of an account. Handlers return it
+// instead of Account so that the password hash never leaves the server.
 type ResponceAccount struct {
-	ID        int       `json:"id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email"`
-	Status    string    `json:"status"`
-	Balance   int64     `json:"balance"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        int        `json:"id"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Email     string     `json:"email"`
+	Status    string     `json:"status"`
+	Role      string     `json:"role"`
+	Number    string     `json:"number"`
+	Currency  string     `json:"currency"`
+	Balance   Money      `json:"balance"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// AccountUpdateResponse is an account after a change to it. PendingEmail is
+// the address waiting to be confirmed. Token is the caller's new access
+// token once its email changed, for clients that do not keep cookies.
+type AccountUpdateResponse struct {
+	*ResponceAccount
+	PendingEmail string `json:"pending_email,omitempty"`
+	Token        string `json:"token,omitempty"`
 }
 
-func NewAccount(firstName, lastName, email, password string) (*Account, error) {
+func NewResponceAccount(acc *Account) *ResponceAccount {
+	return &ResponceAccount{
+		ID:        acc.ID,
+		FirstName: acc.FirstName,
+		LastName:  acc.LastName,
+		Email:     acc.Email,
+		Status:    acc.Status,
+		Role:      acc.Role,
+		Number:    acc.Number,
+		Currency:  acc.Balance.Currency,
+		Balance:   acc.Balance,
+		CreatedAt: acc.CreatedAt,
+		DeletedAt: acc.DeletedAt,
+	}
+}
+
+func NewResponceAccounts(accounts []*Account) []*ResponceAccount {
+	responses := make([]*ResponceAccount, 0, len(accounts))
+	for _, acc := range accounts {
+		responses = append(responses, NewResponceAccount(acc))
+	}
+	return responses
+}
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Account statuses. New accounts are pending until their email address is
+// verified. Only active accounts may sign in. A deleted account can be
+// restored during a grace period, after which it is purged: its personal
+// data is anonymized and only the ledger history remains.
+const (
+	StatusPending   = "Pending"
+	StatusActive    = "Active"
+	StatusSuspended = "Suspended"
+	StatusDeleted   = "Deleted"
+	StatusPurged    = "Purged"
+)
+
+// NewAccount creates a pending account whose balance, and base currency for
+// reports, is in currency. An empty currency means DefaultCurrency. The
+// account number is left for the store to assign.
+func NewAccount(firstName, lastName, email, password, currency string) (*Account, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
 	encpw, er := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if er != nil {
 		return nil, er
@@ -62,72 +145,466 @@ func NewAccount(firstName, lastName, email, password string) (*Account, error) {
 		FirstName: firstName,
 		LastName:  lastName,
 		Email:     email,
-		Status:    "Active",
+		Status:    StatusPending,
+		Role:      RoleUser,
 		Password:  string(encpw),
-		Number:    int64(rand.Intn(1000000)),
-		Balance:   0,
+		Balance:   NewMoney(0, currency),
 		CreatedAt: time.Now().UTC(),
 	}, nil
 }
 
-func NewExpense(userId int, expenseName, expensePurpose, expenseCategory string, expenseValue float32, createdAt time.Time) (*Expense, error) {
+func NewExpense(userId int, expenseName, expensePurpose string, categoryId *int, expenseValue Money, createdAt time.Time) (*Expense, error) {
+	if expenseValue.Currency == "" {
+		expenseValue.Currency = DefaultCurrency
+	}
 
 	/* 	requestDate, err := time.Parse("Mon Jan 02 15:04:05 -0700 2006", createdAt.Local().String())
 	   	if err != nil {
 	   		return nil, err
 	   	} */
 	return &Expense{
-		ID:              0,
-		UserId:          userId,
-		ExpenseName:     expenseName,
-		ExpensePurpose:  expensePurpose,
-		ExpenseCategory: expenseCategory,
-		ExpenseValue:    expenseValue,
-		CreatedAt:       createdAt,
-		UpdatedAt:       time.Now(),
+		ID:             0,
+		UserId:         userId,
+		ExpenseName:    expenseName,
+		ExpensePurpose: expensePurpose,
+		CategoryId:     categoryId,
+		ExpenseValue:   expenseValue,
+		CreatedAt:      createdAt,
+		UpdatedAt:      time.Now(),
 	}, nil
 }
 
-func UpdatedExpense(id, userId int, expenseName, expensePurpose, expenseCategory string, expenseValue float32, createdAt time.Time) (*Expense, error) {
+func UpdatedExpense(id, userId int, expenseName, expensePurpose string, categoryId *int, expenseValue Money, createdAt time.Time) (*Expense, error) {
+	if expenseValue.Currency == "" {
+		expenseValue.Currency = DefaultCurrency
+	}
 
 	/* 	requestDate, err := time.Parse("Mon Jan 02 15:04:05 -0700 2006", createdAt.Local().String())
 	   	if err != nil {
 	   		return nil, err
 	   	} */
 	return &Expense{
-		ID:              id,
-		UserId:          userId,
-		ExpenseName:     expenseName,
-		ExpensePurpose:  expensePurpose,
-		ExpenseCategory: expenseCategory,
-		ExpenseValue:    expenseValue,
-		CreatedAt:       createdAt,
-		UpdatedAt:       time.Now(),
+		ID:             id,
+		UserId:         userId,
+		ExpenseName:    expenseName,
+		ExpensePurpose: expensePurpose,
+		CategoryId:     categoryId,
+		ExpenseValue:   expenseValue,
+		CreatedAt:      createdAt,
+		UpdatedAt:      time.Now(),
 	}, nil
 }
 
 type Account struct {
 	bun.BaseModel `bun:"table:account,alias:a"  json:"-"`
-	ID            int       `bun:"id,pk,autoincrement" json:"id"`
-	FirstName     string    `bun:"first_name" json:"first_name"`
-	LastName      string    `bun:"last_name" json:"last_name"`
-	Email         string    `bun:"email,unique" json:"email"`
-	Password      string    `bun:"password" json:"password"`
-	Status        string    `bun:"status" json:"status"`
-	Number        int64     `bun:"number" json:"number"`
-	Balance       int64     `bun:"balance" json:"balance"`
-	CreatedAt     time.Time `bun:"created_at" json:"createdAt"`
+	ID            int        `bun:"id,pk,autoincrement" json:"id"`
+	FirstName     string     `bun:"first_name" json:"first_name"`
+	LastName      string     `bun:"last_name" json:"last_name"`
+	Email         string     `bun:"email,unique" json:"email"`
+	Password      string     `bun:"password" json:"-"`
+	Status        string     `bun:"status" json:"status"`
+	Role          string     `bun:"role" json:"role"`
+	Number        string     `bun:"number,unique" json:"number"`
+	Balance       Money      `bun:"embed:balance_" json:"balance"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+	DeletedAt     *time.Time `bun:"deleted_at" json:"deleted_at,omitempty"`
+
+	// PreviousStatus is the Active or Pending status the account returns to
+	// when restored after a suspension or deletion.
+	PreviousStatus string `bun:"previous_status,nullzero" json:"-"`
+}
+
+// RestoredStatus returns the status a suspended or deleted account gets back
+// when restored.
+func (a *Account) RestoredStatus() string {
+	if a.PreviousStatus == StatusPending {
+		return StatusPending
+	}
+	return StatusActive
+}
+
+// IsActive reports whether the account may sign in and use the API.
+func (a *Account) IsActive() bool {
+	return a.Status == StatusActive
 }
 
 type Expense struct {
-	bun.BaseModel   `bun:"table:expense,alias:e" json:"-"`
-	ID              int       `bun:"id,pk,autoincrement" json:"id"`
-	UserId          int       `bun:"user_id" json:"user_id"`
-	ExpenseName     string    `bun:"expense_name" json:"expense_name"`
-	ExpensePurpose  string    `bun:"expense_purpose" json:"expense_purpose"`
-	ExpenseCategory string    `bun:"expense_category" json:"expense_category"`
-	ExpenseValue    float32   `bun:"expense_value" json:"expense_value"`
-	CreatedAt       time.Time `bun:"created_at" json:"created_at"`
-	UpdatedAt       time.Time `bun:"updated_at" json:"updated_at"`
-	Account         *Account  `bun:"rel:belongs-to,join:user_id=id" json:"-"`
+	bun.BaseModel  `bun:"table:expense,alias:e" json:"-"`
+	ID             int       `bun:"id,pk,autoincrement" json:"id"`
+	UserId         int       `bun:"user_id" json:"user_id"`
+	ExpenseName    string    `bun:"expense_name" json:"expense_name"`
+	ExpensePurpose string    `bun:"expense_purpose" json:"expense_purpose"`
+	CategoryId     *int      `bun:"category_id" json:"category_id"`
+	Tags           []string  `bun:"-" json:"tags"`
+	ExpenseValue   Money     `bun:"embed:expense_value_" json:"expense_value"`
+	CreatedAt      time.Time `bun:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `bun:"updated_at" json:"updated_at"`
+	Account        *Account  `bun:"rel:belongs-to,join:user_id=id" json:"-"`
+}
+
+type TransferRequest struct {
+	FromAccount string `json:"from_account"`
+	ToAccount   string `json:"to_account"`
+	Amount      Money  `json:"amount"`
+	Description string `json:"description"`
+}
+
+type AmountRequest struct {
+	Amount      Money  `json:"amount"`
+	Description string `json:"description"`
+}
+
+// StatementEntry is one line of an account statement. Amount is signed from
+// the point of view of the account and Balance is the running balance after
+// the entry. Counterparty is the other account number, or "external" for
+// deposits and withdrawals.
+type StatementEntry struct {
+	TransactionId int       `json:"transaction_id"`
+	Type          string    `json:"type"`
+	Amount        Money     `json:"amount"`
+	Counterparty  string    `json:"counterparty"`
+	Description   string    `json:"description"`
+	Balance       Money     `json:"balance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type StatementPage struct {
+	Transactions []*StatementEntry `json:"transactions"`
+	Page         int               `json:"page"`
+	Limit        int               `json:"limit"`
+	Total        int               `json:"total"`
+}
+
+// LedgerTransaction groups the balanced entries of one money movement.
+// The amounts of its entries always sum to zero.
+type LedgerTransaction struct {
+	bun.BaseModel `bun:"table:ledger_transaction,alias:lt" json:"-"`
+	ID            int            `bun:"id,pk,autoincrement" json:"id"`
+	Kind          string         `bun:"kind" json:"kind"`
+	Description   string         `bun:"description" json:"description"`
+	CreatedAt     time.Time      `bun:"created_at" json:"created_at"`
+	Entries       []*LedgerEntry `bun:"rel:has-many,join:id=transaction_id" json:"entries"`
+}
+
+// LedgerEntry is one side of a LedgerTransaction. A negative Amount debits
+// the account, a positive Amount credits it. An AccountId of 0 stands for
+// money entering or leaving the bank and is stored as NULL.
+type LedgerEntry struct {
+	bun.BaseModel `bun:"table:ledger_entry,alias:le" json:"-"`
+	ID            int       `bun:"id,pk,autoincrement" json:"id"`
+	TransactionId int       `bun:"transaction_id" json:"transaction_id"`
+	AccountId     int       `bun:"account_id,nullzero" json:"account_id"`
+	Amount        Money     `bun:"embed:" json:"amount"`
+	CreatedAt     time.Time `bun:"created_at" json:"created_at"`
+}
+
+const (
+	LedgerTransfer   = "transfer"
+	LedgerDeposit    = "deposit"
+	LedgerWithdrawal = "withdrawal"
+	LedgerOpening    = "opening" // balances from before the ledger, see migration 0023
+
+	ExternalCounterparty = "external"
+)
+
+func NewTransfer(fromId, toId int, amount Money, description string) *LedgerTransaction {
+	return newLedgerTransaction(LedgerTransfer, fromId, toId, amount, description)
+}
+
+func NewDeposit(accountId int, amount Money, description string) *LedgerTransaction {
+	return newLedgerTransaction(LedgerDeposit, 0, accountId, amount, description)
+}
+
+func NewWithdrawal(accountId int, amount Money, description string) *LedgerTransaction {
+	return newLedgerTransaction(LedgerWithdrawal, accountId, 0, amount, description)
+}
+
+// newLedgerTransaction moves amount from one account to another, where 0 is
+// the outside world.
+func newLedgerTransaction(kind string, fromId, toId int, amount Money, description string) *LedgerTransaction {
+	now := time.Now().UTC()
+	return &LedgerTransaction{
+		Kind:        kind,
+		Description: description,
+		CreatedAt:   now,
+		Entries: []*LedgerEntry{
+			{AccountId: fromId, Amount: amount.Neg(), CreatedAt: now},
+			{AccountId: toId, Amount: amount, CreatedAt: now},
+		},
+	}
+}
+
+// ExchangeRate says that one unit of Base buys Rate units of Quote from
+// ValidFrom until the next rate for the same pair. Rate is kept as a decimal
+// string so no precision is lost on the way to and from numeric.
+type ExchangeRate struct {
+	bun.BaseModel `bun:"table:exchange_rates,alias:er" json:"-"`
+	Base          string    `bun:"base,pk" json:"base"`
+	Quote         string    `bun:"quote,pk" json:"quote"`
+	ValidFrom     time.Time `bun:"valid_from,pk" json:"valid_from"`
+	Rate          string    `bun:"rate" json:"rate"`
+}
+
+// CategoryTotal sums the expenses of one category. Uncategorized expenses
+// have a nil CategoryId and an empty name.
+type CategoryTotal struct {
+	CategoryId *int   `json:"category_id"`
+	Category   string `json:"category"`
+	Count      int    `json:"count"`
+	Total      Money  `json:"total"`
+}
+
+type MonthTotal struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+	Total Money  `json:"total"`
+}
+
+// ExpenseReport sums a user's expenses converted into the account's base
+// currency, each at the rate valid on the day the expense was made.
+type ExpenseReport struct {
+	Currency   string           `json:"currency"`
+	From       *time.Time       `json:"from,omitempty"`
+	To         *time.Time       `json:"to,omitempty"`
+	Count      int              `json:"count"`
+	Total      Money            `json:"total"`
+	Categories []*CategoryTotal `json:"categories,omitempty"`
+	Months     []*MonthTotal    `json:"months,omitempty"`
+}
+
+// RefreshToken is the server-side record of a refresh token. Only the SHA-256
+// hash of the token is stored. Tokens rotated from the same login share a
+// FamilyId so the whole chain can be revoked when an old token is replayed.
+type RefreshToken struct {
+	bun.BaseModel `bun:"table:refresh_tokens,alias:rt" json:"-"`
+	ID            int        `bun:"id,pk,autoincrement" json:"id"`
+	AccountId     int        `bun:"account_id" json:"account_id"`
+	FamilyId      string     `bun:"family_id" json:"family_id"`
+	TokenHash     string     `bun:"token_hash" json:"-"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+	ExpiresAt     time.Time  `bun:"expires_at" json:"expires_at"`
+	UsedAt        *time.Time `bun:"used_at" json:"used_at,omitempty"`
+	RevokedAt     *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Session is one login of an account. Access tokens carry the session id in
+// their sid claim and the refresh tokens of the login use it as FamilyId.
+type Session struct {
+	bun.BaseModel `bun:"table:sessions,alias:s" json:"-"`
+	ID            string     `bun:"id,pk" json:"id"`
+	AccountId     int        `bun:"account_id" json:"account_id"`
+	UserAgent     string     `bun:"user_agent" json:"user_agent"`
+	IP            string     `bun:"ip" json:"ip"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+	LastSeenAt    time.Time  `bun:"last_seen_at" json:"last_seen_at"`
+	RevokedAt     *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// Scopes an API key can be granted. Sessions are not scoped.
+const (
+	ScopeExpensesRead  = "expenses:read"
+	ScopeExpensesWrite = "expenses:write"
+	ScopeAccountsRead  = "accounts:read"
+	ScopeAccountsWrite = "accounts:write"
+	ScopeLedgerRead    = "ledger:read"
+	ScopeLedgerWrite   = "ledger:write"
+	ScopeReportsRead   = "reports:read"
+)
+
+var ApiKeyScopes = []string{
+	ScopeExpensesRead,
+	ScopeExpensesWrite,
+	ScopeAccountsRead,
+	ScopeAccountsWrite,
+	ScopeLedgerRead,
+	ScopeLedgerWrite,
+	ScopeReportsRead,
+}
+
+// ApiKey is a personal access key. Only the SHA-256 hash of the key is
+// stored; Prefix keeps its first characters so users can tell keys apart.
+type ApiKey struct {
+	bun.BaseModel `bun:"table:api_keys,alias:ak" json:"-"`
+	ID            int        `bun:"id,pk,autoincrement" json:"id"`
+	AccountId     int        `bun:"account_id" json:"account_id"`
+	Name          string     `bun:"name" json:"name"`
+	Prefix        string     `bun:"prefix" json:"prefix"`
+	KeyHash       string     `bun:"key_hash" json:"-"`
+	Scopes        []string   `bun:"scopes,array" json:"scopes"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+	ExpiresAt     *time.Time `bun:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt    *time.Time `bun:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt     *time.Time `bun:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateApiKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateApiKeyResponse is the only time the plain key is returned.
+type CreateApiKeyResponse struct {
+	*ApiKey
+	Key string `json:"key"`
+}
+
+// TwoFactor holds the TOTP secret of an account. Two-factor login is only
+// required once EnabledAt is set by confirming a first code. LastUsedStep is
+// the time step of the last accepted code, which may not be used again.
+type TwoFactor struct {
+	bun.BaseModel `bun:"table:two_factor,alias:tf" json:"-"`
+	AccountId     int        `bun:"account_id,pk" json:"account_id"`
+	Secret        string     `bun:"secret" json:"-"`
+	EnabledAt     *time.Time `bun:"enabled_at" json:"enabled_at,omitempty"`
+	LastUsedStep  int64      `bun:"last_used_step" json:"-"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+}
+
+// RecoveryCode is a one-time code that stands in for a TOTP code. Only its
+// hash is stored.
+type RecoveryCode struct {
+	bun.BaseModel `bun:"table:recovery_codes,alias:rc" json:"-"`
+	ID            int        `bun:"id,pk,autoincrement" json:"id"`
+	AccountId     int        `bun:"account_id" json:"account_id"`
+	CodeHash      string     `bun:"code_hash" json:"-"`
+	UsedAt        *time.Time `bun:"used_at" json:"used_at,omitempty"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri"`
+	RecoveryCodes   []string `json:"recovery_codes"`
+}
+
+// TwoFactorCodeRequest proves possession of the second factor with either a
+// TOTP code or a recovery code.
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorLoginRequest struct {
+	PendingToken string `json:"pending_token"`
+	TwoFactorCodeRequest
+}
+
+// TwoFactorChallenge answers a correct password of an account with
+// two-factor login enabled.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	PendingToken      string    `json:"pending_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Outcomes of a login attempt. Only failures count towards backoff and
+// lockout; a success resets the count for the email. A refused login had the
+// right password for an account that may not sign in yet.
+const (
+	LoginSuccess   = "success"
+	LoginFailure   = "failure"
+	LoginChallenge = "challenge"
+	LoginThrottled = "throttled"
+	LoginRefused   = "refused"
+
+	// Mails asked for without logging in are recorded with these outcomes,
+	// so that they can be throttled like logins.
+	LoginResetRequested     = "reset_requested"
+	LoginVerificationResent = "verification_resent"
+)
+
+// LoginAttempt is the audit record of one password or two-factor check.
+type LoginAttempt struct {
+	bun.BaseModel `bun:"table:login_attempts,alias:la" json:"-"`
+	ID            int       `bun:"id,pk,autoincrement" json:"id"`
+	Email         string    `bun:"email" json:"email"`
+	IP            string    `bun:"ip" json:"ip"`
+	Outcome       string    `bun:"outcome" json:"outcome"`
+	Reason        string    `bun:"reason" json:"reason,omitempty"`
+	CreatedAt     time.Time `bun:"created_at" json:"created_at"`
+}
+
+// LoginFailures counts recent failed attempts for an email, since its last
+// success, and for an IP address. The store's CountLoginAttempts fills it in
+// for other outcomes, with no reset on success.
+type LoginFailures struct {
+	Email       int
+	LastByEmail time.Time
+	IP          int
+	LastByIP    time.Time
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// PasswordResetToken is a single-use token mailed to reset a forgotten
+// password. Only its hash is stored.
+type PasswordResetToken struct {
+	bun.BaseModel `bun:"table:password_reset_tokens,alias:prt" json:"-"`
+	ID            int        `bun:"id,pk,autoincrement" json:"id"`
+	AccountId     int        `bun:"account_id" json:"account_id"`
+	TokenHash     string     `bun:"token_hash" json:"-"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+	ExpiresAt     time.Time  `bun:"expires_at" json:"expires_at"`
+	UsedAt        *time.Time `bun:"used_at" json:"used_at,omitempty"`
+}
+
+// EmailChangeToken is a single-use token mailed to a new address to confirm
+// it for the account. Only its hash is stored, and a newer token for the
+// account replaces it.
+type EmailChangeToken struct {
+	bun.BaseModel `bun:"table:email_change_tokens,alias:ect" json:"-"`
+	ID            int        `bun:"id,pk,autoincrement" json:"id"`
+	AccountId     int        `bun:"account_id" json:"account_id"`
+	Email         string     `bun:"email" json:"email"`
+	TokenHash     string     `bun:"token_hash" json:"-"`
+	CreatedAt     time.Time  `bun:"created_at" json:"created_at"`
+	ExpiresAt     time.Time  `bun:"expires_at" json:"expires_at"`
+	UsedAt        *time.Time `bun:"used_at" json:"used_at,omitempty"`
 }